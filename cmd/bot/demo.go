@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/revisions"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// seedDemoData заполняет хранилище примерами для демо-режима.
+func seedDemoData(s storage.Storage) {
+	ctx := context.Background()
+
+	channels := []models.Channel{
+		{TelegramID: -1001000000001, Username: "@demo_news", Title: "Demo News", IsActive: true, Timezone: "Europe/Moscow"},
+		{TelegramID: -1001000000002, Username: "@demo_promo", Title: "Demo Promo", IsActive: false, Timezone: "Europe/Moscow"},
+	}
+	for i := range channels {
+		if err := s.CreateChannel(ctx, &channels[i]); err != nil {
+			log.Printf("Error seeding demo channel: %v", err)
+		}
+	}
+
+	buttons, _ := json.Marshal([]models.Button{{Text: "Read more", URL: "https://example.com"}})
+	scheduled := time.Now().Add(24 * time.Hour)
+
+	posts := []models.Post{
+		{Content: "<b>Welcome!</b> This is a draft post.", MediaType: "text", Buttons: buttons, Status: "draft", CreatedBy: "admin"},
+		{Content: "Scheduled announcement", MediaType: "text", ScheduleTime: &scheduled, Status: "scheduled", CreatedBy: "admin"},
+	}
+	for i := range posts {
+		if err := s.CreatePost(ctx, &posts[i]); err != nil {
+			log.Printf("Error seeding demo post: %v", err)
+			continue
+		}
+		revisions.Record(ctx, s, &posts[i], posts[i].CreatedBy, "created")
+	}
+}
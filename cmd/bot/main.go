@@ -1,39 +1,108 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os/exec"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/maksekak/channelBot/cmd/config"
 	"github.com/maksekak/channelBot/cmd/internal/admin"
+	"github.com/maksekak/channelBot/cmd/internal/api"
 	"github.com/maksekak/channelBot/cmd/internal/auth"
+	"github.com/maksekak/channelBot/cmd/internal/blob"
+	"github.com/maksekak/channelBot/cmd/internal/imaging"
+	"github.com/maksekak/channelBot/cmd/internal/media"
 	"github.com/maksekak/channelBot/cmd/internal/scheduler"
 	"github.com/maksekak/channelBot/cmd/internal/storage"
 	"github.com/maksekak/channelBot/cmd/internal/telegram"
+	"github.com/maksekak/channelBot/cmd/internal/updates"
 )
 
 func main() {
+	demo := flag.Bool("demo", false, "run with in-memory storage and demo data, without a database")
+	flag.Parse()
+
 	// Загрузка конфигурации
 	cfg := config.Load()
 
+	if flag.Arg(0) == "migrate" {
+		runMigrate(cfg, flag.Args()[1:])
+		return
+	}
+
 	// Инициализация хранилища
-	db, err := storage.NewPostgresStorage(cfg.DatabaseURL)
+	var db storage.Storage
+	if *demo {
+		memory := storage.NewMemoryStorage()
+		seedDemoData(memory)
+		db = memory
+		log.Println("Demo mode: using in-memory storage, data is lost on exit")
+	} else {
+		if cfg.MigrateOnStart {
+			applyMigrations(cfg)
+		}
+
+		var err error
+		db, err = storage.Open(cfg.DatabaseURL)
+		if err != nil {
+			log.Fatal("Failed to connect to database:", err)
+		}
+	}
+
+	// Хранилище файлов медиатеки
+	files, err := newMediaStore(cfg)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		log.Fatal("Failed to configure media storage:", err)
+	}
+	library := media.New(db, files)
+	library.Limits = media.Limits{
+		Photo: int64(cfg.MaxPhotoSizeMB) << 20,
+		Other: int64(cfg.MaxMediaSizeMB) << 20,
+	}
+	library.Images = nil
+	if cfg.ImageProcessing {
+		library.Images = &imaging.Options{MaxSide: cfg.ImageMaxSide, Quality: cfg.JPEGQuality}
+	}
+	if ffmpeg, err := exec.LookPath(cfg.FFmpegPath); err == nil {
+		library.FFmpeg = ffmpeg
+	} else {
+		log.Printf("ffmpeg not found, video thumbnails are disabled: %v", err)
 	}
 
 	// Инициализация Telegram клиента
 	tgClient := telegram.NewClient(cfg.TelegramBotToken)
+	tgClient.Files = files
 
 	// Инициализация аутентификации
 	auth := auth.NewAuth(cfg.JWTSecret)
 
 	// Инициализация планировщика
 	sched := scheduler.NewScheduler(db, tgClient)
-	sched.Start()
+	sched.TrashRetention = time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour
+	sched.Media = library
+	// В демо-режиме каналы и посты выдуманные: планировщик не запускается,
+	// чтобы запланированные посты не уходили в Telegram
+	if *demo {
+		log.Println("Demo mode: scheduler is not started")
+	} else {
+		sched.Start()
+	}
+
+	// Темы форумов и комментарии в группах обсуждения приходят в
+	// обновлениях бота
+	if cfg.PollUpdates && !*demo {
+		go updates.New(db, tgClient).Run(context.Background())
+	}
 
 	// Инициализация обработчиков админ-панели
-	adminHandler := admin.NewHandler(db, tgClient, auth, cfg)
+	adminHandler := admin.NewHandler(db, tgClient, library, auth, sched, cfg)
+
+	// Инициализация JSON API
+	apiHandler := api.NewHandler(db, sched, cfg)
 
 	// Настройка Gin
 	if cfg.LogLevel == "production" {
@@ -51,20 +120,74 @@ func main() {
 
 	// Группа админ-панели с аутентификацией
 	adminGroup := router.Group("/admin")
-	adminGroup.Use(admin.AuthMiddleware(auth))
+	// Запас сверх предела файла — на остальные поля формы
+	adminGroup.Use(admin.AuthMiddleware(auth), admin.MaxBodySize(library.Limits.Max()+1<<20), admin.CSRFMiddleware(cfg.JWTSecret))
 	{
 		adminGroup.GET("/dashboard", adminHandler.Dashboard)
 		adminGroup.GET("/channels", adminHandler.Channels)
-		adminGroup.POST("/channels", adminHandler.CreateChannel)
+		adminGroup.POST("/channels", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.CreateChannel)
+		adminGroup.POST("/channels/:id/delete", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.DeleteChannel)
+		adminGroup.GET("/channels/:id/settings", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.ChannelSettings)
+		adminGroup.POST("/channels/:id/watermark", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.UpdateChannelWatermark)
+		adminGroup.POST("/channels/:id/watermark/delete", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.DeleteChannelWatermark)
+		adminGroup.POST("/channels/:id/defaults", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.UpdateChannelDefaults)
+		adminGroup.POST("/channels/:id/discussion", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.UpdateChannelDiscussion)
+		adminGroup.GET("/posts", adminHandler.Posts)
 		adminGroup.GET("/posts/create", adminHandler.CreatePostPage)
 		adminGroup.POST("/posts/create", adminHandler.CreatePost)
+		adminGroup.POST("/posts/preview", adminHandler.PreviewPost)
+		adminGroup.GET("/posts/:id/edit", adminHandler.EditPostPage)
+		adminGroup.POST("/posts/:id/edit", adminHandler.UpdatePost)
+		adminGroup.GET("/posts/:id/revisions", adminHandler.PostRevisions)
+		adminGroup.GET("/posts/:id/variants", adminHandler.PostVariants)
+		adminGroup.GET("/deliveries/:id/repost", adminHandler.RepostPage)
+		adminGroup.POST("/deliveries/:id/repost", adminHandler.Repost)
+		adminGroup.POST("/posts/:id/revisions/:rev/restore", adminHandler.RestorePostRevision)
+		adminGroup.POST("/posts/:id/delete", adminHandler.DeletePost)
+		adminGroup.GET("/trash", adminHandler.Trash)
+		adminGroup.POST("/trash/posts/:id/restore", adminHandler.RestorePost)
+		adminGroup.POST("/trash/posts/:id/purge", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.PurgePost)
+		adminGroup.POST("/trash/channels/:id/restore", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.RestoreChannel)
+		adminGroup.POST("/trash/channels/:id/purge", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.PurgeChannel)
+		adminGroup.GET("/templates", adminHandler.PostTemplates)
+		adminGroup.POST("/templates", adminHandler.CreatePostTemplate)
+		adminGroup.GET("/templates/:id", adminHandler.EditPostTemplatePage)
+		adminGroup.POST("/templates/:id", adminHandler.UpdatePostTemplate)
+		adminGroup.POST("/templates/:id/delete", adminHandler.DeletePostTemplate)
+		adminGroup.POST("/templates/:id/use", adminHandler.UsePostTemplate)
+		adminGroup.GET("/media", adminHandler.Media)
+		adminGroup.GET("/media/picker", adminHandler.MediaPicker)
+		adminGroup.GET("/media/files/*key", adminHandler.MediaFile)
+		adminGroup.GET("/media/thumbnails/*key", adminHandler.MediaThumbnail)
+		adminGroup.POST("/media", adminHandler.UploadMedia)
+		adminGroup.POST("/media/collect", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.CollectMedia)
+		adminGroup.GET("/tags", adminHandler.Tags)
+		adminGroup.POST("/tags", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.CreateTag)
+		adminGroup.POST("/tags/:id", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.UpdateTag)
+		adminGroup.POST("/tags/:id/delete", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.DeleteTag)
 		adminGroup.GET("/statistics", adminHandler.Statistics)
+		adminGroup.GET("/api-tokens", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.APITokens)
+		adminGroup.POST("/api-tokens", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.CreateAPIToken)
+		adminGroup.POST("/api-tokens/:id/revoke", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.RevokeAPIToken)
+		adminGroup.GET("/audit", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.AuditLog)
+		adminGroup.GET("/audit/export", admin.RequireRole(cfg, config.RoleAdmin), adminHandler.ExportAuditLog)
 		adminGroup.POST("/logout", adminHandler.Logout)
 	}
 
+	// JSON API с персональными токенами
+	apiGroup := router.Group(api.BasePath)
+	apiGroup.Use(api.TokenMiddleware(db))
+	apiHandler.Register(apiGroup)
+
+	// OpenAPI-спецификация всех маршрутов
+	router.GET(api.BasePath+"/openapi.json", api.SpecHandler(router, apiHandler.Routes()))
+
 	// Аутентификация
 	router.GET("/admin/login", adminHandler.LoginPage)
 	router.POST("/admin/login", adminHandler.Login)
+	if cfg.TelegramLoginEnabled() {
+		router.GET("/admin/login/telegram", adminHandler.TelegramLogin)
+	}
 
 	// Корневой маршрут
 	router.GET("/", func(c *gin.Context) {
@@ -76,3 +199,25 @@ func main() {
 		log.Fatal("Failed to start server:", err)
 	}
 }
+
+// newMediaStore создаёт хранилище файлов по MEDIA_STORAGE.
+func newMediaStore(cfg *config.Config) (blob.Store, error) {
+	switch cfg.MediaStorage {
+	case "local", "":
+		return blob.NewLocal(cfg.MediaDir), nil
+	case "s3":
+		if cfg.S3.Endpoint == "" || cfg.S3.Bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET are required")
+		}
+		return blob.NewS3(blob.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			PathStyle: cfg.S3.PathStyle,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_STORAGE %q", cfg.MediaStorage)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/maksekak/channelBot/cmd/config"
+	"github.com/maksekak/channelBot/cmd/internal/migrate"
+)
+
+const migrateUsage = `usage: bot migrate <command>
+
+commands:
+  up                 apply all pending migrations
+  down [N]           roll back the last N migrations (default 1)
+  status             list migrations and whether they are applied
+  baseline VERSION   mark migrations up to VERSION as applied without running them`
+
+// runMigrate выполняет подкоманду migrate.
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	m, err := migrate.Open(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer m.Close()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, mig := range done {
+			fmt.Printf("applied  %03d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(done) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				log.Fatalf("invalid number of steps %q", args[1])
+			}
+		}
+		done, err := m.Down(ctx, steps)
+		for _, mig := range done {
+			fmt.Printf("reverted %03d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state += " (MODIFIED)"
+			}
+			fmt.Printf("%03d_%-30s %s\n", s.Version, s.Name, state)
+		}
+	case "baseline":
+		if len(args) < 2 {
+			log.Fatal("baseline requires a version")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("invalid version %q", args[1])
+		}
+		if err := m.Baseline(ctx, version); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+// applyMigrations применяет ожидающие миграции при старте сервера.
+func applyMigrations(cfg *config.Config) {
+	m, err := migrate.Open(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer m.Close()
+
+	done, err := m.Up(context.Background())
+	if err != nil {
+		log.Fatal("Failed to apply migrations:", err)
+	}
+	for _, mig := range done {
+		log.Printf("Applied migration %03d_%s", mig.Version, mig.Name)
+	}
+}
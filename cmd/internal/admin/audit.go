@@ -0,0 +1,187 @@
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+const (
+	auditPageSize   = 50
+	auditExportPage = 500
+)
+
+// record пишет в журнал действие текущего пользователя.
+func (h *Handler) record(c *gin.Context, action, targetType string, targetID int, before, after interface{}) {
+	h.recordAs(c, c.GetString("username"), action, targetType, targetID, before, after)
+}
+
+// recordAs пишет в журнал действие от имени actor — для входа, когда
+// пользователь ещё не аутентифицирован.
+func (h *Handler) recordAs(c *gin.Context, actor, action, targetType string, targetID int, before, after interface{}) {
+	h.audit.Record(c.Request.Context(), audit.Event{
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+		IP:         c.ClientIP(),
+	})
+}
+
+// parseAuditFilter читает фильтр журнала из строки запроса. Даты
+// задаются днями, to включает весь указанный день.
+func parseAuditFilter(c *gin.Context) models.AuditFilter {
+	filter := models.AuditFilter{
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+	}
+
+	filter.TargetID, _ = strconv.Atoi(c.Query("target_id"))
+
+	if t, err := time.ParseInLocation("2006-01-02", c.Query("from"), time.Local); err == nil {
+		filter.From = &t
+	}
+	if t, err := time.ParseInLocation("2006-01-02", c.Query("to"), time.Local); err == nil {
+		t = t.AddDate(0, 0, 1)
+		filter.To = &t
+	}
+
+	return filter
+}
+
+func (h *Handler) AuditLog(c *gin.Context) {
+	filter := parseAuditFilter(c)
+
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page < 1 {
+		page = 1
+	}
+	// Запрашиваем на одну запись больше, чтобы узнать о следующей странице
+	filter.Limit = auditPageSize + 1
+	filter.Offset = (page - 1) * auditPageSize
+
+	entries, err := h.storage.ListAuditEntries(c.Request.Context(), filter)
+	if err != nil {
+		h.render(c, http.StatusOK, "audit.html", gin.H{
+			"Error":   "Failed to load audit log",
+			"Actions": audit.Actions,
+			"Query":   c.Request.URL.Query(),
+		})
+		return
+	}
+
+	pageURL := func(p int) string {
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(p))
+		return "/admin/audit?" + query.Encode()
+	}
+
+	data := gin.H{
+		"Entries": entries,
+		"Actions": audit.Actions,
+		"Query":   c.Request.URL.Query(),
+	}
+	if len(entries) > auditPageSize {
+		data["Entries"] = entries[:auditPageSize]
+		data["NextURL"] = pageURL(page + 1)
+	}
+	if page > 1 {
+		data["PrevURL"] = pageURL(page - 1)
+	}
+
+	h.render(c, http.StatusOK, "audit.html", data)
+}
+
+// ExportAuditLog выгружает журнал с теми же фильтрами, что и страница,
+// в CSV (по умолчанию) или JSON.
+func (h *Handler) ExportAuditLog(c *gin.Context) {
+	filter := parseAuditFilter(c)
+	format := c.DefaultQuery("format", "csv")
+
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	var write func(models.AuditEntry) error
+	var finish func() error
+
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"id", "created_at", "actor", "action", "target_type", "target_id", "ip", "before", "after"})
+		write = func(e models.AuditEntry) error {
+			return w.Write([]string{
+				strconv.Itoa(e.ID),
+				e.CreatedAt.Format(time.RFC3339),
+				e.Actor,
+				e.Action,
+				e.TargetType,
+				strconv.Itoa(e.TargetID),
+				e.IP,
+				string(e.Before),
+				string(e.After),
+			})
+		}
+		finish = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		c.Header("Content-Type", "application/json")
+		enc := json.NewEncoder(c.Writer)
+		c.Writer.WriteString("[")
+		first := true
+		write = func(e models.AuditEntry) error {
+			if !first {
+				c.Writer.WriteString(",")
+			}
+			first = false
+			return enc.Encode(e)
+		}
+		finish = func() error {
+			_, err := c.Writer.WriteString("]")
+			return err
+		}
+	}
+
+	// Журнал выгружается постранично, чтобы не держать его в памяти целиком.
+	// Страницы идут по курсору (created_at, id): новые записи, появившиеся во
+	// время выгрузки, не сдвигают следующие страницы
+	filter.Limit = auditExportPage
+	for {
+		entries, err := h.storage.ListAuditEntries(c.Request.Context(), filter)
+		if err != nil {
+			// Заголовки уже отправлены, остаётся оборвать выгрузку
+			c.Error(err)
+			return
+		}
+
+		for _, entry := range entries {
+			if err := write(entry); err != nil {
+				return
+			}
+		}
+
+		if len(entries) < auditExportPage {
+			break
+		}
+		last := entries[len(entries)-1]
+		filter.After = &models.PostCursor{Time: last.CreatedAt, ID: last.ID}
+	}
+
+	finish()
+}
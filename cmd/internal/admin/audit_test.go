@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/maksekak/channelBot/cmd/config"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+func TestCreateChannelRecordsAudit(t *testing.T) {
+	r, store := newTestRouter(t)
+	h := NewHandler(store, nil, nil, nil, nil, &config.Config{Timezone: "UTC"})
+	r.POST("/admin/channels", h.CreateChannel)
+
+	form := url.Values{"telegram_id": {"-1001"}, "username": {"@news"}, "title": {"News"}, csrfFormField: {"secret"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/channels", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("create channel = %d", w.Code)
+	}
+
+	ctx := context.Background()
+	channels, err := store.GetChannels(ctx)
+	if err != nil || len(channels) != 1 {
+		t.Fatalf("channels = %+v, %v", channels, err)
+	}
+	channel := channels[0]
+	if channel.Username != "news" || channel.Timezone != "UTC" || !channel.IsActive {
+		t.Errorf("channel = %+v", channel)
+	}
+
+	entries, err := store.ListAuditEntries(ctx, models.AuditFilter{TargetType: audit.TargetChannel, TargetID: channel.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != audit.ActionChannelCreate || entries[0].Actor != "admin" {
+		t.Fatalf("entries for channel %d = %+v", channel.ID, entries)
+	}
+	var after models.Channel
+	if err := json.Unmarshal(entries[0].After, &after); err != nil || after.ID != channel.ID || after.TelegramID != -1001 {
+		t.Errorf("After = %s (%v), want the stored channel", entries[0].After, err)
+	}
+}
+
+func TestExportAuditLogPages(t *testing.T) {
+	r, store := newTestRouter(t)
+	h := NewHandler(store, nil, nil, nil, nil, &config.Config{})
+	r.GET("/admin/audit/export", h.ExportAuditLog)
+
+	ctx := context.Background()
+	total := auditExportPage + 3
+	for i := 0; i < total; i++ {
+		if err := store.CreateAuditEntry(ctx, &models.AuditEntry{Actor: "admin", Action: audit.ActionPostUpdate}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit/export", nil))
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := map[string]bool{}
+	for _, row := range rows[1:] {
+		ids[row[0]] = true
+	}
+	if len(rows) != total+1 || len(ids) != total {
+		t.Errorf("export has %d rows with %d distinct entries, want %d", len(rows)-1, len(ids), total)
+	}
+}
@@ -0,0 +1,273 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/imaging"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/preview"
+)
+
+func channelSettingsPath(id int) string {
+	return fmt.Sprintf("/admin/channels/%d/settings", id)
+}
+
+// CreateChannel добавляет канал из формы на странице каналов.
+func (h *Handler) CreateChannel(c *gin.Context) {
+	telegramID, err := strconv.ParseInt(strings.TrimSpace(c.PostForm("telegram_id")), 10, 64)
+	if err != nil {
+		redirectWithError(c, "/admin/channels", "Invalid Telegram ID")
+		return
+	}
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		redirectWithError(c, "/admin/channels", "Title is required")
+		return
+	}
+
+	channel := models.Channel{
+		TelegramID: telegramID,
+		Username:   strings.TrimPrefix(strings.TrimSpace(c.PostForm("username")), "@"),
+		Title:      title,
+		IsActive:   true,
+		Timezone:   h.config.Timezone,
+	}
+	if err := h.storage.CreateChannel(c.Request.Context(), &channel); err != nil {
+		storageError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionChannelCreate, audit.TargetChannel, channel.ID, nil, channel)
+	c.Redirect(http.StatusFound, "/admin/channels")
+}
+
+// ChannelSettings показывает настройки отправки в канал.
+func (h *Handler) ChannelSettings(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	channel, err := h.storage.GetChannel(ctx, id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"Channel":   channel,
+		"Positions": imaging.Positions,
+		"Error":     c.Query("error"),
+	}
+	topics, err := h.storage.GetForumTopics(ctx, channel.TelegramID)
+	if err != nil {
+		data["Error"] = "Failed to load forum topics"
+	}
+	data["Topics"] = topics
+	// Сведения о чате запрашиваются у Telegram только по кнопке проверки
+	if c.Query("check") != "" {
+		chat, err := h.telegram.GetChat(channel.TelegramID)
+		if err != nil {
+			data["ChatError"] = err.Error()
+		} else {
+			data["Chat"] = chat
+		}
+	}
+	if channel.WatermarkPath != "" {
+		data["WatermarkURL"] = mediaURL(channel.WatermarkPath)
+	}
+	var buttons []models.Button
+	json.Unmarshal(channel.Buttons, &buttons)
+	data["Buttons"] = buttons
+	if channel.Footer != "" || len(buttons) > 0 {
+		data["Preview"] = preview.Post{Content: preview.HTML(channel.Footer), Buttons: buttons}
+	}
+	h.render(c, http.StatusOK, "channel_settings.html", data)
+}
+
+// UpdateChannelDefaults сохраняет подпись, кнопки и настройки отправки
+// канала, которые добавляются к каждому посту.
+func (h *Handler) UpdateChannelDefaults(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	before, err := h.storage.GetChannel(ctx, id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	channel := *before
+	channel.Footer = strings.TrimSpace(c.PostForm("footer"))
+	channel.Buttons = parseButtons(c)
+	channel.DisableNotification = c.PostForm("disable_notification") == "true"
+	channel.DisableLinkPreview = c.PostForm("disable_link_preview") == "true"
+	channel.ProtectContent = c.PostForm("protect_content") == "true"
+	channel.LinkPreviewAbove = c.PostForm("link_preview_above") == "true"
+	switch size := c.PostForm("link_preview_size"); size {
+	case models.LinkPreviewSmall, models.LinkPreviewLarge:
+		channel.LinkPreviewSize = size
+	default:
+		channel.LinkPreviewSize = ""
+	}
+
+	if err := h.storage.UpdateChannel(ctx, &channel); err != nil {
+		storageError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionChannelUpdate, audit.TargetChannel, id, before, channel)
+	c.Redirect(http.StatusFound, channelSettingsPath(id))
+}
+
+// UpdateChannelDiscussion сохраняет тему форума, в которую отправляются
+// посты, и комментарий к постам в группе обсуждения канала.
+func (h *Handler) UpdateChannelDiscussion(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	before, err := h.storage.GetChannel(ctx, id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	channel := *before
+	channel.MessageThreadID = 0
+	if value := strings.TrimSpace(c.PostForm("message_thread_id")); value != "" {
+		threadID, err := strconv.Atoi(value)
+		if err != nil || threadID < 0 {
+			redirectWithError(c, channelSettingsPath(id), "Topic ID must be a positive number")
+			return
+		}
+		channel.MessageThreadID = threadID
+	}
+	channel.DiscussionComment = strings.TrimSpace(c.PostForm("discussion_comment"))
+
+	if err := h.storage.UpdateChannel(ctx, &channel); err != nil {
+		storageError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionChannelUpdate, audit.TargetChannel, id, before, channel)
+	c.Redirect(http.StatusFound, channelSettingsPath(id))
+}
+
+// UpdateChannelWatermark задаёт положение водяного знака канала и, если
+// загружен файл, заменяет логотип.
+func (h *Handler) UpdateChannelWatermark(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	before, err := h.storage.GetChannel(ctx, id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	position := c.PostForm("position")
+	if !slices.Contains(imaging.Positions, position) {
+		redirectWithError(c, channelSettingsPath(id), "Unknown watermark position")
+		return
+	}
+
+	channel := *before
+	channel.WatermarkPosition = position
+	if header, err := c.FormFile("watermark"); err == nil {
+		file, err := header.Open()
+		if err != nil {
+			redirectWithError(c, channelSettingsPath(id), "Failed to read file")
+			return
+		}
+		defer file.Close()
+
+		key, err := h.media.SaveWatermark(ctx, file, header.Filename)
+		if err != nil {
+			redirectWithError(c, channelSettingsPath(id), mediaError(err))
+			return
+		}
+		channel.WatermarkPath = key
+	}
+	if channel.WatermarkPath == "" {
+		redirectWithError(c, channelSettingsPath(id), "Upload a logo image")
+		return
+	}
+
+	if err := h.storage.UpdateChannel(ctx, &channel); err != nil {
+		storageError(c, err)
+		return
+	}
+	h.releaseWatermark(c, before.WatermarkPath, channel.WatermarkPath)
+
+	h.record(c, audit.ActionChannelUpdate, audit.TargetChannel, id, before, channel)
+	c.Redirect(http.StatusFound, channelSettingsPath(id))
+}
+
+// DeleteChannelWatermark отключает водяной знак канала.
+func (h *Handler) DeleteChannelWatermark(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	before, err := h.storage.GetChannel(ctx, id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	channel := *before
+	channel.WatermarkPath = ""
+	channel.WatermarkPosition = ""
+	if err := h.storage.UpdateChannel(ctx, &channel); err != nil {
+		storageError(c, err)
+		return
+	}
+	h.releaseWatermark(c, before.WatermarkPath, "")
+
+	h.record(c, audit.ActionChannelUpdate, audit.TargetChannel, id, before, channel)
+	c.Redirect(http.StatusFound, channelSettingsPath(id))
+}
+
+// releaseWatermark удаляет прежний логотип канала, если он заменён и им не
+// пользуются другие каналы, в том числе удалённые в корзину.
+func (h *Handler) releaseWatermark(c *gin.Context, old, current string) {
+	if old == "" || old == current {
+		return
+	}
+
+	ctx := c.Request.Context()
+	active, err := h.storage.GetChannels(ctx)
+	if err != nil {
+		return
+	}
+	deleted, err := h.storage.GetDeletedChannels(ctx)
+	if err != nil {
+		return
+	}
+	if slices.ContainsFunc(append(active, deleted...), func(channel models.Channel) bool {
+		return channel.WatermarkPath == old
+	}) {
+		return
+	}
+	h.media.DeleteWatermark(ctx, old)
+}
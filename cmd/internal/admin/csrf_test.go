@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newCSRFRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(CSRFMiddleware("secret"))
+	r.GET("/admin/form", func(c *gin.Context) { c.String(http.StatusOK, csrfToken(c)) })
+	r.POST("/admin/action", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return r
+}
+
+// csrfSession открывает форму и возвращает cookie сессии и токен из неё.
+func csrfSession(t *testing.T, r *gin.Engine) (*http.Cookie, string) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/form", nil))
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == csrfCookieName {
+			return cookie, w.Body.String()
+		}
+	}
+	t.Fatal("GET did not set the CSRF cookie")
+	return nil, ""
+}
+
+func postAction(r *gin.Engine, cookie *http.Cookie, form url.Values, header http.Header) int {
+	req := httptest.NewRequest(http.MethodPost, "/admin/action", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for key, values := range header {
+		req.Header.Set(key, values[0])
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	r := newCSRFRouter(t)
+	cookie, token := csrfSession(t, r)
+	otherCookie, otherToken := csrfSession(t, r)
+	if token == "" || token == otherToken {
+		t.Fatalf("sessions got tokens %q and %q, want distinct tokens", token, otherToken)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		cookie *http.Cookie
+		form   url.Values
+		header http.Header
+		want   int
+	}{
+		{"valid form token", cookie, url.Values{csrfFormField: {token}}, nil, http.StatusNoContent},
+		{"valid header token", cookie, nil, http.Header{csrfHeaderName: {token}}, http.StatusNoContent},
+		{"missing token", cookie, url.Values{"title": {"x"}}, nil, http.StatusForbidden},
+		{"token of another session", cookie, url.Values{csrfFormField: {otherToken}}, nil, http.StatusForbidden},
+		{"token without its session", otherCookie, url.Values{csrfFormField: {token}}, nil, http.StatusForbidden},
+		{"no session cookie", nil, url.Values{csrfFormField: {token}}, nil, http.StatusForbidden},
+		{"bearer token without session", nil, nil, http.Header{"Authorization": {"Bearer cbt_test"}}, http.StatusForbidden},
+		{"bearer token with session", cookie, nil, http.Header{"Authorization": {"Bearer cbt_test"}}, http.StatusForbidden},
+		{"basic auth", nil, nil, http.Header{"Authorization": {"Basic YWRtaW46YWRtaW4="}}, http.StatusForbidden},
+	} {
+		if got := postAction(r, tc.cookie, tc.form, tc.header); got != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCSRFTokenDependsOnSecret(t *testing.T) {
+	if signCSRF("secret", "session") == signCSRF("other", "session") {
+		t.Error("tokens signed with different secrets match")
+	}
+}
@@ -1,40 +1,69 @@
 package admin
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/maksekak/channelBot/cmd/config"
+	"github.com/maksekak/channelBot/cmd/internal/api"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
 	"github.com/maksekak/channelBot/cmd/internal/auth"
+	"github.com/maksekak/channelBot/cmd/internal/media"
 	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/revisions"
+	"github.com/maksekak/channelBot/cmd/internal/scheduler"
 	"github.com/maksekak/channelBot/cmd/internal/storage"
 	"github.com/maksekak/channelBot/cmd/internal/telegram"
 )
 
+// Данные Telegram Login Widget старше этого срока не принимаются. Виджет
+// перенаправляет сразу после входа, больший срок только продлевает жизнь
+// перехваченной ссылки.
+const telegramLoginMaxAge = 5 * time.Minute
+
 type Handler struct {
 	storage  storage.Storage
 	telegram *telegram.Client
 	auth     *auth.Auth
+	audit    *audit.Logger
+	media    *media.Library
 	config   *config.Config
+	// scheduler отправляет посты «сейчас» так же, как по расписанию
+	scheduler *scheduler.Scheduler
 }
 
-func NewHandler(storage storage.Storage, telegram *telegram.Client, auth *auth.Auth, config *config.Config) *Handler {
+func NewHandler(storage storage.Storage, telegram *telegram.Client, library *media.Library, auth *auth.Auth, scheduler *scheduler.Scheduler, config *config.Config) *Handler {
 	return &Handler{
-		storage:  storage,
-		telegram: telegram,
-		auth:     auth,
-		config:   config,
+		storage:   storage,
+		telegram:  telegram,
+		auth:      auth,
+		audit:     audit.New(storage),
+		media:     library,
+		config:    config,
+		scheduler: scheduler,
 	}
 }
 
+// render отдаёт HTML-шаблон, добавляя в данные CSRF-токен текущей сессии.
+func (h *Handler) render(c *gin.Context, code int, name string, data gin.H) {
+	data["CSRFToken"] = csrfToken(c)
+	c.HTML(code, name, data)
+}
+
 func (h *Handler) LoginPage(c *gin.Context) {
-	c.HTML(http.StatusOK, "login.html", gin.H{})
+	c.HTML(http.StatusOK, "login.html", gin.H{
+		"TelegramBotUsername": h.telegramLoginBot(),
+	})
 }
 
 func (h *Handler) Login(c *gin.Context) {
@@ -42,6 +71,7 @@ func (h *Handler) Login(c *gin.Context) {
 	password := c.PostForm("password")
 
 	if username != h.config.AdminUsername || password != h.config.AdminPassword {
+		h.recordAs(c, username, audit.ActionLoginFailed, "", 0, nil, nil)
 		c.HTML(http.StatusOK, "login.html", gin.H{
 			"Error": "Invalid credentials",
 		})
@@ -57,101 +87,163 @@ func (h *Handler) Login(c *gin.Context) {
 	}
 
 	h.auth.SetTokenCookie(c.Writer, token)
+	clearCSRFCookie(c)
+	h.recordAs(c, username, audit.ActionLogin, "", 0, nil, gin.H{"method": "password"})
+	c.Redirect(http.StatusFound, "/admin/dashboard")
+}
+
+// telegramLoginBot возвращает имя бота для виджета входа или пустую
+// строку, если вход через Telegram не настроен.
+func (h *Handler) telegramLoginBot() string {
+	if !h.config.TelegramLoginEnabled() {
+		return ""
+	}
+	return h.config.TelegramBotUsername
+}
+
+// TelegramLogin принимает редирект от Telegram Login Widget и выдаёт ту же
+// сессионную cookie, что и вход по паролю.
+func (h *Handler) TelegramLogin(c *gin.Context) {
+	user, err := telegram.VerifyLogin(h.config.TelegramBotToken, c.Request.URL.Query(), telegramLoginMaxAge)
+	if err != nil {
+		c.HTML(http.StatusOK, "login.html", gin.H{
+			"Error":               "Telegram authorization failed",
+			"TelegramBotUsername": h.telegramLoginBot(),
+		})
+		return
+	}
+
+	admin, ok := h.config.FindTelegramAdmin(user.ID)
+	if !ok {
+		h.recordAs(c, user.Username, audit.ActionLoginFailed, "", 0, nil, gin.H{"method": "telegram", "telegram_id": user.ID})
+		c.HTML(http.StatusOK, "login.html", gin.H{
+			"Error":               "This Telegram account has no access",
+			"TelegramBotUsername": h.telegramLoginBot(),
+		})
+		return
+	}
+
+	token, err := h.auth.GenerateToken(admin.Username)
+	if err != nil {
+		c.HTML(http.StatusOK, "login.html", gin.H{
+			"Error":               "Failed to create session",
+			"TelegramBotUsername": h.telegramLoginBot(),
+		})
+		return
+	}
+
+	h.auth.SetTokenCookie(c.Writer, token)
+	clearCSRFCookie(c)
+	h.recordAs(c, admin.Username, audit.ActionLogin, "", 0, nil, gin.H{"method": "telegram", "telegram_id": user.ID})
 	c.Redirect(http.StatusFound, "/admin/dashboard")
 }
 
 func (h *Handler) Logout(c *gin.Context) {
+	h.record(c, audit.ActionLogout, "", 0, nil, nil)
 	h.auth.ClearTokenCookie(c.Writer)
+	clearCSRFCookie(c)
 	c.Redirect(http.StatusFound, "/admin/login")
 }
 
 func (h *Handler) Dashboard(c *gin.Context) {
-	stats, err := h.storage.GetStatistics(7)
+	stats, err := h.storage.GetStatistics(c.Request.Context(), 7)
 	if err != nil {
-		c.HTML(http.StatusOK, "dashboard.html", gin.H{
+		h.render(c, http.StatusOK, "dashboard.html", gin.H{
 			"Error": "Failed to load statistics",
 		})
 		return
 	}
 
-	posts, err := h.storage.GetPosts(10, 0)
+	posts, err := h.storage.GetPosts(c.Request.Context(), 10, 0)
 	if err != nil {
-		c.HTML(http.StatusOK, "dashboard.html", gin.H{
+		h.render(c, http.StatusOK, "dashboard.html", gin.H{
 			"Error": "Failed to load posts",
 		})
 		return
 	}
 
-	c.HTML(http.StatusOK, "dashboard.html", gin.H{
+	h.render(c, http.StatusOK, "dashboard.html", gin.H{
 		"Stats": stats,
-		"Posts": posts,
+		"Posts": postItems(posts),
 	})
 }
 
-func (h *Handler) CreatePost(c *gin.Context) {
-	content := c.PostForm("content")
-	mediaType := c.PostForm("media_type")
-	scheduleTimeStr := c.PostForm("schedule_time")
-	sendNow := c.PostForm("send_now") == "true"
-
-	var scheduleTime *time.Time
-	if scheduleTimeStr != "" {
-		t, err := time.Parse("2006-01-02T15:04", scheduleTimeStr)
-		if err == nil {
-			scheduleTime = &t
-		}
+// CreatePostPage показывает форму нового поста. Скрытое поле с ключом
+// идемпотентности не даёт повторной отправке формы создать второй пост.
+func (h *Handler) CreatePostPage(c *gin.Context) {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Обработка кнопок
-	var buttons []models.Button
-	buttonTexts := c.PostFormArray("button_text")
-	buttonURLs := c.PostFormArray("button_url")
+	data := h.postFormData(c, &models.Post{MediaType: "text", Status: "draft"}, c.Query("error"))
+	data["Creating"] = true
+	data["IdempotencyKey"] = key
+	data["MediaTypes"] = mediaTypes
+	h.render(c, http.StatusOK, "post_edit.html", data)
+}
 
-	for i := range buttonTexts {
-		if buttonTexts[i] != "" && buttonURLs[i] != "" {
-			buttons = append(buttons, models.Button{
-				Text: buttonTexts[i],
-				URL:  buttonURLs[i],
-			})
-		}
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
 
-	buttonsJSON, _ := json.Marshal(buttons)
+func (h *Handler) CreatePost(c *gin.Context) {
+	content := c.PostForm("content")
+	mediaType := c.PostForm("media_type")
+	scheduleTime := parseScheduleTime(c.PostForm("schedule_time"))
+	sendNow := c.PostForm("send_now") == "true"
 
 	post := models.Post{
-		Content:      content,
-		MediaType:    mediaType,
-		Buttons:      buttonsJSON,
-		ScheduleTime: scheduleTime,
-		Status:       "draft",
-		CreatedBy:    c.MustGet("username").(string),
-		CreatedAt:    time.Now(),
+		Content:        content,
+		MediaType:      mediaType,
+		Buttons:        parseButtons(c),
+		Variables:      parseVariables(c.PostForm("variables")),
+		ScheduleTime:   scheduleTime,
+		Status:         "draft",
+		CreatedBy:      c.MustGet("username").(string),
+		CreatedAt:      time.Now(),
+		IdempotencyKey: c.PostForm("idempotency_key"),
 	}
 
-	// Обработка загрузки медиа
-	file, header, err := c.Request.FormFile("media")
-	if err == nil {
-		defer file.Close()
-
-		uploadDir := "web/assets/uploads"
-		os.MkdirAll(uploadDir, 0755)
-
-		filename := fmt.Sprintf("%d_%s", time.Now().Unix(), header.Filename)
-		filepath := filepath.Join(uploadDir, filename)
+	variantList, err := parseVariants(c)
+	if err != nil {
+		redirectWithError(c, "/admin/posts/create", err.Error())
+		return
+	}
+	post.Variants = variantList
 
-		dst, err := os.Create(filepath)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-			return
-		}
-		defer dst.Close()
+	post.Options, err = parseSendOptions(c)
+	if err != nil {
+		redirectWithError(c, "/admin/posts/create", err.Error())
+		return
+	}
 
-		if _, err := io.Copy(dst, file); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-			return
+	// Медиа: новый файл или выбранный в медиатеке. К текстовому посту файл
+	// прикрепляется с типом по содержимому
+	if post.MediaType == "text" {
+		post.MediaType = ""
+	}
+	file, err := h.postMedia(c, post.MediaType)
+	if err != nil {
+		redirectWithError(c, "/admin/posts/create", mediaError(err))
+		return
+	}
+	switch {
+	case file != nil:
+		post.MediaPath = file.Path
+		if post.MediaType == "" {
+			post.MediaType = media.MediaType(file.MimeType)
 		}
-
-		post.MediaPath = filepath
+	case post.MediaType == "":
+		post.MediaType = "text"
+	default:
+		redirectWithError(c, "/admin/posts/create", "Attach a file or pick one from the media library")
+		return
 	}
 
 	if sendNow {
@@ -162,49 +254,224 @@ func (h *Handler) CreatePost(c *gin.Context) {
 		post.Status = "draft"
 	}
 
-	if err := h.storage.CreatePost(&post); err != nil {
+	if err := h.storage.CreatePost(c.Request.Context(), &post); err != nil {
+		// Повторная отправка той же формы: пост уже создан и, если нужно,
+		// уже отправляется — второй раз не рассылаем
+		if errors.Is(err, storage.ErrDuplicateKey) {
+			c.Redirect(http.StatusFound, "/admin/dashboard")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := revisions.Record(c.Request.Context(), h.storage, &post, post.CreatedBy, "created"); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Теги задаются до отправки: от них зависят каналы и хэштеги
+	if err := h.storage.SetPostTags(c.Request.Context(), post.ID, parseIDs(c, "tags")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.record(c, audit.ActionPostCreate, audit.TargetPost, post.ID, nil, post)
+
 	// Немедленная отправка
 	if sendNow {
-		go h.sendPostToChannels(post)
+		// Отправка идёт после ответа, поэтому контекст запроса не используется
+		go h.scheduler.Publish(context.Background(), post)
 	}
 
 	c.Redirect(http.StatusFound, "/admin/dashboard")
 }
 
-func (h *Handler) sendPostToChannels(post models.Post) {
-	channels, err := h.storage.GetActiveChannels()
+// parseScheduleTime разбирает значение поля datetime-local; пустое или
+// некорректное значение означает отсутствие расписания.
+func parseScheduleTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse("2006-01-02T15:04", value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// parseSendOptions читает переопределения настроек отправки канала.
+func parseSendOptions(c *gin.Context) (models.SendOptions, error) {
+	options := models.SendOptions{
+		DisableNotification: parseOverride(c.PostForm("disable_notification")),
+		DisableLinkPreview:  parseOverride(c.PostForm("disable_link_preview")),
+		ProtectContent:      parseOverride(c.PostForm("protect_content")),
+		LinkPreviewURL:      strings.TrimSpace(c.PostForm("link_preview_url")),
+		LinkPreviewAbove:    parseOverride(c.PostForm("link_preview_above")),
+		LinkPreviewSize:     c.PostForm("link_preview_size"),
+		NoFooter:            c.PostForm("no_footer") == "true",
+		NoChannelButtons:    c.PostForm("no_channel_buttons") == "true",
+	}
+	if err := options.Validate(); err != nil {
+		return options, fmt.Errorf("Invalid send options: %v", err)
+	}
+	return options, nil
+}
+
+// sendOptionsForm — значения полей шаблона "send_options".
+type sendOptionsForm struct {
+	DisableNotification string
+	DisableLinkPreview  string
+	ProtectContent      string
+	LinkPreviewURL      string
+	LinkPreviewAbove    string
+	LinkPreviewSize     string
+	NoFooter            bool
+	NoChannelButtons    bool
+}
+
+func newSendOptionsForm(options models.SendOptions) sendOptionsForm {
+	return sendOptionsForm{
+		DisableNotification: overrideValue(options.DisableNotification),
+		DisableLinkPreview:  overrideValue(options.DisableLinkPreview),
+		ProtectContent:      overrideValue(options.ProtectContent),
+		LinkPreviewURL:      options.LinkPreviewURL,
+		LinkPreviewAbove:    overrideValue(options.LinkPreviewAbove),
+		LinkPreviewSize:     options.LinkPreviewSize,
+		NoFooter:            options.NoFooter,
+		NoChannelButtons:    options.NoChannelButtons,
+	}
+}
+
+// parseOverride разбирает значение select «как у канала / да / нет»:
+// пустое значение оставляет настройку канала.
+func parseOverride(value string) *bool {
+	switch value {
+	case "true":
+		v := true
+		return &v
+	case "false":
+		v := false
+		return &v
+	}
+	return nil
+}
+
+// overrideValue — значение select для переопределения настройки канала.
+func overrideValue(option *bool) string {
+	if option == nil {
+		return ""
+	}
+	return strconv.FormatBool(*option)
+}
+
+// parseButtons собирает кнопки из парных полей button_text/button_url,
+// пропуская незаполненные.
+func parseButtons(c *gin.Context) json.RawMessage {
+	buttonsJSON, _ := json.Marshal(parseButtonFields(c, "button_text", "button_url"))
+	return buttonsJSON
+}
+
+// parseButtonFields собирает кнопки из парных полей textField/urlField.
+func parseButtonFields(c *gin.Context, textField, urlField string) []models.Button {
+	var buttons []models.Button
+	buttonTexts := c.PostFormArray(textField)
+	buttonURLs := c.PostFormArray(urlField)
+
+	for i := range buttonTexts {
+		if i < len(buttonURLs) && buttonTexts[i] != "" && buttonURLs[i] != "" {
+			buttons = append(buttons, models.Button{
+				Text: buttonTexts[i],
+				URL:  buttonURLs[i],
+			})
+		}
+	}
+	return buttons
+}
+
+func (h *Handler) APITokens(c *gin.Context) {
+	tokens, err := h.storage.GetAPITokens(c.Request.Context())
 	if err != nil {
+		h.render(c, http.StatusOK, "api_tokens.html", gin.H{
+			"Error": "Failed to load API tokens",
+		})
 		return
 	}
 
-	for _, channel := range channels {
-		messageID, err := h.telegram.SendMessage(channel.TelegramID, post)
+	h.render(c, http.StatusOK, "api_tokens.html", gin.H{
+		"Tokens": tokens,
+		"Scopes": api.Scopes,
+	})
+}
 
-		status := "sent"
-		errorMsg := ""
-		if err != nil {
-			status = "error"
-			errorMsg = err.Error()
+func (h *Handler) CreateAPIToken(c *gin.Context) {
+	name := c.PostForm("name")
+	scopes := c.PostFormArray("scopes")
+
+	if name == "" || len(scopes) == 0 {
+		h.render(c, http.StatusOK, "api_tokens.html", gin.H{
+			"Error":  "Name and at least one scope are required",
+			"Scopes": api.Scopes,
+		})
+		return
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(api.Scopes, scope) {
+			h.render(c, http.StatusBadRequest, "api_tokens.html", gin.H{
+				"Error":  fmt.Sprintf("Unknown scope %q", scope),
+				"Scopes": api.Scopes,
+			})
+			return
 		}
+	}
+
+	plain, hash, err := api.GenerateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := models.APIToken{
+		Name:      name,
+		TokenHash: hash,
+		Scopes:    scopes,
+		CreatedBy: c.MustGet("username").(string),
+	}
+
+	if err := h.storage.CreateAPIToken(c.Request.Context(), &token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.record(c, audit.ActionAPITokenCreate, audit.TargetAPIToken, token.ID, nil, token)
+
+	tokens, _ := h.storage.GetAPITokens(c.Request.Context())
+
+	// Токен в открытом виде показывается только один раз
+	h.render(c, http.StatusOK, "api_tokens.html", gin.H{
+		"Tokens":   tokens,
+		"Scopes":   api.Scopes,
+		"NewToken": plain,
+	})
+}
+
+func (h *Handler) RevokeAPIToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
 
-		postChannel := models.PostChannel{
-			PostID:    post.ID,
-			ChannelID: channel.ID,
-			MessageID: messageID,
-			Status:    status,
-			Error:     errorMsg,
-			SentAt:    time.Now(),
+	if err := h.storage.RevokeAPIToken(c.Request.Context(), id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
 		}
-		h.storage.CreatePostChannel(&postChannel)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Обновление статуса поста
-	post.Status = "sent"
-	now := time.Now()
-	post.SentAt = &now
-	h.storage.UpdatePost(&post)
+	h.record(c, audit.ActionAPITokenRevoke, audit.TargetAPIToken, id, nil, nil)
+
+	c.Redirect(http.StatusFound, "/admin/api-tokens")
 }
@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/config"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// newTestRouter возвращает админ-панель на хранилище в памяти с
+// пользователем admin, уже прошедшим вход.
+func newTestRouter(t *testing.T) (*gin.Engine, *storage.MemoryStorage) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store := storage.NewMemoryStorage()
+	h := NewHandler(store, nil, nil, nil, nil, &config.Config{})
+
+	r := gin.New()
+	r.LoadHTMLGlob("../../../web/templates/*")
+	r.Use(func(c *gin.Context) { c.Set("username", "admin") })
+	r.GET("/admin/posts/create", h.CreatePostPage)
+	r.POST("/admin/posts/create", h.CreatePost)
+	r.POST("/admin/posts/:id/edit", h.UpdatePost)
+	return r, store
+}
+
+func TestCreatePostPageRendersIdempotencyKey(t *testing.T) {
+	r, _ := newTestRouter(t)
+
+	keys := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/posts/create", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET create page = %d", w.Code)
+		}
+		match := regexp.MustCompile(`name="idempotency_key" value="([^"]+)"`).FindStringSubmatch(w.Body.String())
+		if match == nil {
+			t.Fatal("create page has no idempotency_key field")
+		}
+		keys[match[1]] = true
+	}
+	if len(keys) != 2 {
+		t.Error("create page renders the same idempotency key twice")
+	}
+}
+
+func TestCreatePostSameKeyTwice(t *testing.T) {
+	r, store := newTestRouter(t)
+
+	form := url.Values{
+		"content":         {"Hello"},
+		"media_type":      {"text"},
+		"idempotency_key": {"form-1"},
+	}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/admin/posts/create", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/dashboard" {
+			t.Fatalf("submit %d = %d %s, want redirect to dashboard", i+1, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	posts, err := store.GetPosts(context.Background(), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("posts = %d, want 1 for the same idempotency key", len(posts))
+	}
+}
+
+func TestCreateAPITokenRejectsUnknownScope(t *testing.T) {
+	r, store := newTestRouter(t)
+	h := NewHandler(store, nil, nil, nil, nil, &config.Config{})
+	r.POST("/admin/api-tokens", h.CreateAPIToken)
+
+	form := url.Values{"name": {"ci"}, "scopes": {"posts:read", "admin:all"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/api-tokens", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("create token with unknown scope = %d, want 400", w.Code)
+	}
+
+	tokens, err := store.GetAPITokens(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 0 {
+		t.Errorf("tokens = %d, want none stored", len(tokens))
+	}
+}
+
+func TestUpdatePostStatusConflict(t *testing.T) {
+	r, store := newTestRouter(t)
+	ctx := context.Background()
+
+	// Форму открыли с черновиком, а планировщик уже отправил пост
+	post := models.Post{Content: "Hello", MediaType: "text", Status: "sent"}
+	if err := store.CreatePost(ctx, &post); err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"content": {"Edited"}, "status": {"draft"}}
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/posts/%d/edit", post.ID), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "while you were editing") {
+		t.Fatalf("edit with a stale status = %d, want 409 with a conflict error", w.Code)
+	}
+
+	got, err := store.GetPost(ctx, post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "sent" || got.Content != "Hello" {
+		t.Errorf("post = %q %q, want it unchanged", got.Status, got.Content)
+	}
+}
+
+func TestLoginPageTelegramWidget(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		name   string
+		config config.Config
+		widget bool
+	}{
+		{"configured", config.Config{TelegramBotToken: "123:abc", TelegramBotUsername: "news_bot"}, true},
+		{"without bot token", config.Config{TelegramBotUsername: "news_bot"}, false},
+	} {
+		h := NewHandler(storage.NewMemoryStorage(), nil, nil, nil, nil, &tc.config)
+		r := gin.New()
+		r.LoadHTMLGlob("../../../web/templates/*")
+		r.GET("/admin/login", h.LoginPage)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/login", nil))
+		if got := strings.Contains(w.Body.String(), `data-telegram-login="news_bot"`); got != tc.widget {
+			t.Errorf("%s: widget rendered = %v, want %v", tc.name, got, tc.widget)
+		}
+	}
+}
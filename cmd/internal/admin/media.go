@@ -0,0 +1,254 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/blob"
+	"github.com/maksekak/channelBot/cmd/internal/media"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+const (
+	mediaPageSize   = 24
+	mediaPickerSize = 20
+)
+
+// Фильтр медиатеки по типу файла.
+var mediaKinds = []struct {
+	Value string
+	Label string
+}{
+	{"image/", "Images"},
+	{"video/", "Videos"},
+	{"application/", "Documents"},
+}
+
+// mediaFile — файл медиатеки с адресами файла и миниатюры.
+type mediaFile struct {
+	models.Media
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	MediaType    string `json:"media_type"`
+}
+
+func newMediaFile(m models.Media) mediaFile {
+	return mediaFile{
+		Media:        m,
+		URL:          mediaURL(m.Path),
+		ThumbnailURL: thumbnailURL(m.Path),
+		MediaType:    media.MediaType(m.MimeType),
+	}
+}
+
+// mediaURL возвращает адрес файла хранилища в админ-панели.
+func mediaURL(key string) string {
+	return "/admin/media/files/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+// thumbnailURL возвращает адрес миниатюры файла хранилища.
+func thumbnailURL(key string) string {
+	return "/admin/media/thumbnails/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+func parseMediaFilter(c *gin.Context, limit int) models.MediaFilter {
+	filter := models.MediaFilter{
+		Query:      c.Query("q"),
+		MimePrefix: c.Query("type"),
+		Limit:      limit,
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 1 {
+		filter.Offset = (page - 1) * limit
+	}
+	return filter
+}
+
+// Media показывает медиатеку с поиском по имени и фильтром по типу.
+func (h *Handler) Media(c *gin.Context) {
+	data := gin.H{
+		"Query": c.Request.URL.Query(),
+		"Kinds": mediaKinds,
+		"Error": c.Query("error"),
+	}
+
+	filter := parseMediaFilter(c, mediaPageSize+1)
+	list, err := h.storage.ListMedia(c.Request.Context(), filter)
+	if err != nil {
+		data["Error"] = "Failed to load media"
+	}
+
+	page := filter.Offset/mediaPageSize + 1
+	if len(list) > mediaPageSize {
+		list = list[:mediaPageSize]
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		data["NextURL"] = "/admin/media?" + query.Encode()
+	}
+	if page > 1 {
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(page-1))
+		data["PrevURL"] = "/admin/media?" + query.Encode()
+	}
+
+	files := make([]mediaFile, len(list))
+	for i, m := range list {
+		files[i] = newMediaFile(m)
+	}
+	data["Files"] = files
+	h.render(c, http.StatusOK, "media.html", data)
+}
+
+// MediaPicker отдаёт файлы медиатеки для выбора в форме поста.
+func (h *Handler) MediaPicker(c *gin.Context) {
+	list, err := h.storage.ListMedia(c.Request.Context(), parseMediaFilter(c, mediaPickerSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	files := make([]mediaFile, len(list))
+	for i, m := range list {
+		files[i] = newMediaFile(m)
+	}
+	c.JSON(http.StatusOK, gin.H{"media": files})
+}
+
+// MediaFile отдаёт файл из хранилища медиа. Всё, кроме изображений и
+// видео, отдаётся как вложение, чтобы загруженный HTML не исполнялся в
+// админ-панели.
+func (h *Handler) MediaFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	file, err := h.media.Open(c.Request.Context(), key)
+	if errors.Is(err, blob.ErrNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if media.MediaType(contentType) == "document" {
+		c.Header("Content-Disposition", "attachment")
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, contentType, file, nil)
+}
+
+// UploadMedia добавляет файл в медиатеку без создания поста.
+func (h *Handler) UploadMedia(c *gin.Context) {
+	file, err := h.postMedia(c, c.PostForm("media_type"))
+	if err != nil {
+		redirectWithError(c, "/admin/media", mediaError(err))
+		return
+	}
+	if file == nil {
+		redirectWithError(c, "/admin/media", "Choose a file to upload")
+		return
+	}
+	c.Redirect(http.StatusFound, "/admin/media")
+}
+
+// CollectMedia сразу удаляет неиспользуемые файлы, не дожидаясь
+// ежедневной очистки.
+func (h *Handler) CollectMedia(c *gin.Context) {
+	before := time.Now().Add(-media.GracePeriod)
+	n, err := h.media.GC(c.Request.Context(), before)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.record(c, audit.ActionMediaCollect, audit.TargetMedia, 0, nil, gin.H{"removed": n, "uploaded_before": before})
+	c.Redirect(http.StatusFound, "/admin/media")
+}
+
+// postMedia возвращает файл из поля media, сохраняя его в медиатеку, или
+// выбранный в медиатеке по media_id. Без файла возвращается nil. Файл
+// проверяется на соответствие mediaType; пустой тип определяется по
+// содержимому.
+func (h *Handler) postMedia(c *gin.Context, mediaType string) (*models.Media, error) {
+	if upload, header, err := c.Request.FormFile("media"); err == nil {
+		defer upload.Close()
+
+		file, err := h.media.Save(c.Request.Context(), upload, header.Filename, mediaType, c.MustGet("username").(string))
+		if err != nil {
+			return nil, err
+		}
+		h.record(c, audit.ActionMediaUpload, audit.TargetMedia, file.ID, nil, file)
+		return file, nil
+	}
+
+	value := c.PostForm("media_id")
+	if value == "" {
+		return nil, nil
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, &media.ValidationError{Message: "Invalid media library file"}
+	}
+	file, err := h.storage.GetMedia(c.Request.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, &media.ValidationError{Message: "The selected file is no longer in the media library"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if mediaType == "" {
+		mediaType = media.MediaType(file.MimeType)
+	}
+	if err := h.media.Limits.Check(file, mediaType); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// mediaError возвращает текст ошибки загрузки для формы.
+func mediaError(err error) string {
+	var invalid *media.ValidationError
+	if errors.As(err, &invalid) {
+		return invalid.Message
+	}
+	return "Failed to save file"
+}
+
+// MaxBodySize ограничивает размер запросов к админ-панели. Запрос с заранее
+// известной длиной сверх предела возвращается на страницу формы с ошибкой,
+// не дожидаясь загрузки тела.
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			back := "/admin/dashboard"
+			if referer, err := url.Parse(c.Request.Referer()); err == nil && strings.HasPrefix(referer.Path, "/admin/") {
+				back = referer.Path
+			}
+			c.Abort()
+			redirectWithError(c, back, fmt.Sprintf("The upload is too large: the limit is %s", megabytes(limit)))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+func megabytes(size int64) string {
+	return fmt.Sprintf("%d MB", size>>20)
+}
@@ -0,0 +1,321 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/media"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/preview"
+	"github.com/maksekak/channelBot/cmd/internal/revisions"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+	"github.com/maksekak/channelBot/cmd/internal/templates"
+)
+
+// variableField — поле формы для значения пользовательской переменной.
+type variableField struct {
+	Name  string
+	Value string
+}
+
+type postTemplateRow struct {
+	models.PostTemplate
+	Fields  []variableField
+	Preview preview.Post
+}
+
+// builtinVariables показываются в подсказке формы шаблона.
+var builtinVariables = []string{templates.VarDate, templates.VarChannelTitle, templates.VarChannelUsername}
+
+// parseVariables разбирает строки «имя=значение». Строки без имени
+// пропускаются.
+func parseVariables(value string) map[string]string {
+	vars := make(map[string]string)
+	for _, line := range strings.Split(value, "\n") {
+		name, val, _ := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if name == "" || templates.Builtin(name) {
+			continue
+		}
+		vars[name] = strings.TrimSpace(val)
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	return vars
+}
+
+// formatVariables превращает переменные в строки «имя=значение» для
+// текстового поля формы.
+func formatVariables(vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = name + "=" + vars[name]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// variableFields возвращает поля для пользовательских переменных шаблона:
+// упомянутых в тексте и кнопках и заданных по умолчанию.
+func variableFields(tpl models.PostTemplate) []variableField {
+	texts := []string{tpl.Content}
+	var buttons []models.Button
+	json.Unmarshal(tpl.Buttons, &buttons)
+	for _, b := range buttons {
+		texts = append(texts, b.Text, b.URL)
+	}
+
+	names := templates.Variables(texts...)
+	for name := range tpl.Variables {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	fields := make([]variableField, len(names))
+	for i, name := range names {
+		fields[i] = variableField{Name: name, Value: tpl.Variables[name]}
+	}
+	return fields
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// PostTemplates показывает шаблоны постов и форму нового шаблона.
+func (h *Handler) PostTemplates(c *gin.Context) {
+	data := gin.H{
+		"MediaTypes": mediaTypes,
+		"Builtin":    builtinVariables,
+		"Error":      c.Query("error"),
+	}
+
+	list, err := h.storage.GetPostTemplates(c.Request.Context())
+	if err != nil {
+		data["Error"] = "Failed to load templates"
+	}
+
+	rows := make([]postTemplateRow, len(list))
+	for i, tpl := range list {
+		rows[i] = postTemplateRow{
+			PostTemplate: tpl,
+			Fields:       variableFields(tpl),
+			Preview: postPreview(models.Post{
+				Content:   tpl.Content,
+				MediaType: tpl.MediaType,
+				MediaPath: tpl.MediaPath,
+				Buttons:   tpl.Buttons,
+			}),
+		}
+	}
+	data["Templates"] = rows
+	h.render(c, http.StatusOK, "post_templates.html", data)
+}
+
+// EditPostTemplatePage показывает форму правки шаблона.
+func (h *Handler) EditPostTemplatePage(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	tpl, err := h.storage.GetPostTemplate(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	var buttons []models.Button
+	json.Unmarshal(tpl.Buttons, &buttons)
+
+	data := gin.H{
+		"Template":   tpl,
+		"Buttons":    buttons,
+		"Variables":  formatVariables(tpl.Variables),
+		"MediaTypes": mediaTypes,
+		"Builtin":    builtinVariables,
+		"Error":      c.Query("error"),
+	}
+	if tpl.MediaPath != "" {
+		data["Preview"] = postPreview(models.Post{MediaType: tpl.MediaType, MediaPath: tpl.MediaPath})
+	}
+	h.render(c, http.StatusOK, "post_template_edit.html", data)
+}
+
+// parsePostTemplate читает шаблон из формы. Файл загружается в медиатеку
+// или выбирается в ней; без нового файла сохраняется прежний.
+func (h *Handler) parsePostTemplate(c *gin.Context, tpl *models.PostTemplate) error {
+	tpl.Name = strings.TrimSpace(c.PostForm("name"))
+	tpl.Content = c.PostForm("content")
+	tpl.Buttons = parseButtons(c)
+	tpl.Variables = parseVariables(c.PostForm("variables"))
+	if tpl.Name == "" {
+		return &media.ValidationError{Message: "Template name is required"}
+	}
+
+	if c.PostForm("remove_media") == "true" {
+		tpl.MediaType = "text"
+		tpl.MediaPath = ""
+		return nil
+	}
+
+	// Как и у поста: к текстовому шаблону файл прикрепляется с типом по
+	// содержимому, без нового файла остаётся прежний
+	mediaType := c.PostForm("media_type")
+	if mediaType == "text" {
+		mediaType = ""
+	}
+	file, err := h.postMedia(c, mediaType)
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		tpl.MediaPath = file.Path
+		tpl.MediaType = media.MediaType(file.MimeType)
+	}
+	switch {
+	case tpl.MediaPath == "":
+		tpl.MediaType = "text"
+	case mediaType != "":
+		tpl.MediaType = mediaType
+	}
+	return nil
+}
+
+// postTemplateError возвращает текст ошибки сохранения шаблона.
+func postTemplateError(err error) string {
+	if errors.Is(err, storage.ErrDuplicateName) {
+		return "A template with this name already exists"
+	}
+	return mediaError(err)
+}
+
+func (h *Handler) CreatePostTemplate(c *gin.Context) {
+	tpl := models.PostTemplate{CreatedBy: c.MustGet("username").(string)}
+	if err := h.parsePostTemplate(c, &tpl); err != nil {
+		redirectWithError(c, "/admin/templates", postTemplateError(err))
+		return
+	}
+
+	if err := h.storage.CreatePostTemplate(c.Request.Context(), &tpl); err != nil {
+		redirectWithError(c, "/admin/templates", postTemplateError(err))
+		return
+	}
+
+	h.record(c, audit.ActionTemplateCreate, audit.TargetTemplate, tpl.ID, nil, tpl)
+	c.Redirect(http.StatusFound, "/admin/templates")
+}
+
+func (h *Handler) UpdatePostTemplate(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	editPath := fmt.Sprintf("/admin/templates/%d", id)
+
+	before, err := h.storage.GetPostTemplate(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	tpl := *before
+	if err := h.parsePostTemplate(c, &tpl); err != nil {
+		redirectWithError(c, editPath, postTemplateError(err))
+		return
+	}
+
+	if err := h.storage.UpdatePostTemplate(c.Request.Context(), &tpl); err != nil {
+		redirectWithError(c, editPath, postTemplateError(err))
+		return
+	}
+
+	h.record(c, audit.ActionTemplateUpdate, audit.TargetTemplate, id, before, tpl)
+	c.Redirect(http.StatusFound, "/admin/templates")
+}
+
+func (h *Handler) DeletePostTemplate(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	tpl, err := h.storage.GetPostTemplate(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if err := h.storage.DeletePostTemplate(c.Request.Context(), id); err != nil {
+		storageError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionTemplateDelete, audit.TargetTemplate, id, tpl, nil)
+	c.Redirect(http.StatusFound, "/admin/templates")
+}
+
+// UsePostTemplate создаёт из шаблона черновик со значениями переменных из
+// формы и открывает его на редактирование.
+func (h *Handler) UsePostTemplate(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	tpl, err := h.storage.GetPostTemplate(ctx, id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	vars := make(map[string]string)
+	for _, field := range variableFields(*tpl) {
+		vars[field.Name] = c.PostForm("var_" + field.Name)
+	}
+	if len(vars) == 0 {
+		vars = nil
+	}
+
+	post := models.Post{
+		Content:   tpl.Content,
+		MediaType: tpl.MediaType,
+		MediaPath: tpl.MediaPath,
+		Buttons:   tpl.Buttons,
+		Variables: vars,
+		Status:    "draft",
+		CreatedBy: c.MustGet("username").(string),
+		CreatedAt: time.Now(),
+	}
+	if err := h.storage.CreatePost(ctx, &post); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := revisions.Record(ctx, h.storage, &post, post.CreatedBy, "created from template "+tpl.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.record(c, audit.ActionPostCreate, audit.TargetPost, post.ID, nil, post)
+	c.Redirect(http.StatusFound, fmt.Sprintf("/admin/posts/%d/edit", post.ID))
+}
@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+const postsPageSize = 20
+
+// Варианты сортировки для формы фильтров.
+var postSorts = []struct {
+	Value string
+	Label string
+}{
+	{models.PostSortCreated, "Created"},
+	{models.PostSortScheduled, "Scheduled"},
+	{models.PostSortSent, "Sent"},
+}
+
+var postStatuses = []string{"draft", "scheduled", "sending", "sent"}
+
+var mediaTypes = []string{"text", "photo", "video", "document"}
+
+// parsePostFilter читает фильтр списка постов из строки запроса. Даты
+// задаются днями и ограничивают поле сортировки, to включает весь день.
+func parsePostFilter(c *gin.Context) (models.PostFilter, error) {
+	filter := models.PostFilter{
+		Status:    c.Query("status"),
+		Author:    c.Query("author"),
+		MediaType: c.Query("media_type"),
+		Query:     c.Query("q"),
+		Sort:      c.DefaultQuery("sort", models.PostSortCreated),
+		Asc:       c.Query("order") == "asc",
+		Limit:     postsPageSize + 1,
+	}
+
+	filter.ChannelID, _ = strconv.Atoi(c.Query("channel"))
+	filter.TagID, _ = strconv.Atoi(c.Query("tag"))
+
+	if t, err := time.ParseInLocation("2006-01-02", c.Query("from"), time.Local); err == nil {
+		filter.From = &t
+	}
+	if t, err := time.ParseInLocation("2006-01-02", c.Query("to"), time.Local); err == nil {
+		t = t.AddDate(0, 0, 1)
+		filter.To = &t
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := storage.ParsePostCursor(cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = after
+	}
+
+	return filter, nil
+}
+
+// Posts показывает список постов с фильтрами, поиском и постраничной
+// навигацией по курсору.
+func (h *Handler) Posts(c *gin.Context) {
+	data := gin.H{
+		"Query":      c.Request.URL.Query(),
+		"Sorts":      postSorts,
+		"Statuses":   postStatuses,
+		"MediaTypes": mediaTypes,
+	}
+
+	channels, err := h.storage.GetChannels(c.Request.Context())
+	if err != nil {
+		data["Error"] = "Failed to load channels"
+	}
+	data["Channels"] = channels
+
+	tags, err := h.storage.GetTags(c.Request.Context())
+	if err != nil {
+		data["Error"] = "Failed to load tags"
+	}
+	data["Tags"] = tags
+
+	filter, err := parsePostFilter(c)
+	if err != nil {
+		data["Error"] = "Invalid page cursor"
+		h.render(c, http.StatusBadRequest, "posts.html", data)
+		return
+	}
+
+	posts, err := h.storage.ListPosts(c.Request.Context(), filter)
+	if err != nil {
+		data["Error"] = "Failed to load posts"
+		h.render(c, http.StatusOK, "posts.html", data)
+		return
+	}
+
+	// Запрашиваем на один пост больше, чтобы узнать о следующей странице
+	if len(posts) > postsPageSize {
+		posts = posts[:postsPageSize]
+		query := c.Request.URL.Query()
+		query.Set("cursor", storage.NextPostCursor(posts[len(posts)-1], filter.Sort))
+		data["NextURL"] = "/admin/posts?" + query.Encode()
+	}
+	if filter.After != nil {
+		query := c.Request.URL.Query()
+		query.Del("cursor")
+		data["FirstURL"] = "/admin/posts?" + query.Encode()
+	}
+
+	postTags := make(map[int][]models.Tag, len(posts))
+	for _, post := range posts {
+		if postTags[post.ID], err = h.storage.GetPostTags(c.Request.Context(), post.ID); err != nil {
+			data["Error"] = "Failed to load post tags"
+			break
+		}
+	}
+
+	data["Posts"] = postItems(posts)
+	data["PostTags"] = postTags
+	h.render(c, http.StatusOK, "posts.html", data)
+}
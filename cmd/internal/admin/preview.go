@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/blob"
+	"github.com/maksekak/channelBot/cmd/internal/media"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/preview"
+)
+
+// postItem — пост в списке вместе с его превью.
+type postItem struct {
+	models.Post
+	Preview preview.Post
+}
+
+func postItems(posts []models.Post) []postItem {
+	items := make([]postItem, len(posts))
+	for i, post := range posts {
+		items[i] = postItem{Post: post, Preview: postPreview(post)}
+	}
+	return items
+}
+
+// postPreview готовит пост к показу в шаблоне "post_preview".
+func postPreview(post models.Post) preview.Post {
+	p := preview.New(post)
+	if post.MediaPath != "" {
+		p.MediaURL = mediaURL(post.MediaPath)
+		p.ThumbnailURL = thumbnailURL(post.MediaPath)
+		p.Filename = path.Base(post.MediaPath)
+	}
+	return p
+}
+
+// PreviewPost отрисовывает превью поста по полям формы создания или
+// правки. Файл берётся из медиатеки (media_id) или из сохранённого поста
+// (post_id): новый файл превью не загружает.
+func (h *Handler) PreviewPost(c *gin.Context) {
+	post := models.Post{
+		Content:   c.PostForm("content"),
+		MediaType: c.PostForm("media_type"),
+		Buttons:   parseButtons(c),
+	}
+
+	ctx := c.Request.Context()
+	if id, err := strconv.Atoi(c.PostForm("media_id")); err == nil {
+		if file, err := h.storage.GetMedia(ctx, id); err == nil {
+			post.MediaPath = file.Path
+			if post.MediaType == "" || post.MediaType == "text" {
+				post.MediaType = media.MediaType(file.MimeType)
+			}
+		}
+	} else if id, err := strconv.Atoi(c.PostForm("post_id")); err == nil {
+		if stored, err := h.storage.GetPost(ctx, id); err == nil {
+			post.MediaPath = stored.MediaPath
+			post.MediaType = stored.MediaType
+		}
+	}
+
+	c.HTML(http.StatusOK, "post_preview", postPreview(post))
+}
+
+// MediaThumbnail отдаёт JPEG-миниатюру файла хранилища.
+func (h *Handler) MediaThumbnail(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	thumb, err := h.media.Thumbnail(c.Request.Context(), key)
+	if errors.Is(err, blob.ErrNotExist) || errors.Is(err, media.ErrNoThumbnail) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no thumbnail"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer thumb.Close()
+
+	// Файлы хранилища не меняются после загрузки
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, "image/jpeg", thumb, nil)
+}
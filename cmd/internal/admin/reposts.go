@@ -0,0 +1,114 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/delivery"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/reposts"
+)
+
+func repostPath(id int) string {
+	return fmt.Sprintf("/admin/deliveries/%d/repost", id)
+}
+
+// RepostPage показывает форму повтора доставленного сообщения в других
+// каналах.
+func (h *Handler) RepostPage(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	source, err := h.storage.GetPostChannel(ctx, id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	data := gin.H{
+		"Delivery": source,
+		"Error":    c.Query("error"),
+	}
+	channels, err := h.storage.GetChannels(ctx)
+	if err != nil {
+		data["Error"] = "Failed to load channels"
+	}
+	var targets []option
+	for _, channel := range channels {
+		if channel.ID == source.ChannelID {
+			data["Source"] = channel
+			continue
+		}
+		targets = append(targets, option{ID: channel.ID, Title: channel.Title})
+	}
+	data["Channels"] = targets
+
+	h.render(c, http.StatusOK, "repost.html", data)
+}
+
+// Repost повторяет сообщение в выбранных каналах сразу или в указанное
+// время.
+func (h *Handler) Repost(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	source, err := h.storage.GetPostChannel(ctx, id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	channelIDs := parseIDs(c, "channels")
+	mode := c.PostForm("mode")
+	if err := reposts.Validate(*source, channelIDs, mode); err != nil {
+		redirectWithError(c, repostPath(id), err.Error())
+		return
+	}
+	scheduleTime := parseScheduleTime(c.PostForm("schedule_time"))
+
+	list, err := reposts.Create(ctx, h.storage, *source, channelIDs, mode, scheduleTime, c.GetString("username"))
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+	h.record(c, audit.ActionPostRepost, audit.TargetPost, source.PostID, nil, list)
+
+	if scheduleTime == nil {
+		// Отправка идёт после ответа, поэтому контекст запроса не используется
+		go h.repost(context.Background(), c.GetString("username"), source.PostID, list)
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprintf("/admin/posts/%d/variants", source.PostID))
+}
+
+// repost отправляет повторы сообщения поста postID и записывает отправку
+// от имени пользователя actor, запросившего повтор.
+func (h *Handler) repost(ctx context.Context, actor string, postID int, list []models.Repost) {
+	sender := delivery.Sender{Telegram: h.telegram, Media: h.media}
+
+	var deliveries []models.PostChannel
+	for _, repost := range list {
+		postChannel, err := reposts.Publish(ctx, h.storage, sender, repost)
+		if err != nil {
+			continue
+		}
+		deliveries = append(deliveries, *postChannel)
+	}
+
+	h.audit.Record(ctx, audit.Event{
+		Actor:      actor,
+		Action:     audit.ActionPostReposted,
+		TargetType: audit.TargetPost,
+		TargetID:   postID,
+		After:      deliveries,
+	})
+}
@@ -0,0 +1,337 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/delivery"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/revisions"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+	"github.com/maksekak/channelBot/cmd/internal/tags"
+	"github.com/maksekak/channelBot/cmd/internal/variants"
+)
+
+// Верхняя граница числа доставок одного поста, которые правятся при
+// редактировании опубликованного поста.
+const maxPostDeliveries = 1000
+
+// loadPost загружает пост из параметра :id, отвечая ошибкой при неудаче.
+func (h *Handler) loadPost(c *gin.Context) (*models.Post, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return nil, false
+	}
+
+	post, err := h.storage.GetPost(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return post, true
+}
+
+func (h *Handler) EditPostPage(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	h.renderEditPost(c, post, "")
+}
+
+func (h *Handler) renderEditPost(c *gin.Context, post *models.Post, errorMsg string) {
+	h.render(c, http.StatusOK, "post_edit.html", h.postFormData(c, post, errorMsg))
+}
+
+// postFormData собирает данные формы поста для post_edit.html.
+func (h *Handler) postFormData(c *gin.Context, post *models.Post, errorMsg string) gin.H {
+	var buttons []models.Button
+	json.Unmarshal(post.Buttons, &buttons)
+
+	tagOptions, err := h.tagOptions(c.Request.Context(), post.ID)
+	if err != nil && errorMsg == "" {
+		errorMsg = "Failed to load tags"
+	}
+
+	variantForms, err := h.variantForms(c.Request.Context(), post)
+	if err != nil && errorMsg == "" {
+		errorMsg = "Failed to load channels"
+	}
+
+	return gin.H{
+		"Post":      post,
+		"Buttons":   buttons,
+		"Variables": formatVariables(post.Variables),
+		"Variants":  variantForms,
+		"Options":   newSendOptionsForm(post.Options),
+		"Tags":      tagOptions,
+		"Published": post.Status == "sent",
+		"Error":     errorMsg,
+	}
+}
+
+// UpdatePost сохраняет правку поста. Опубликованный пост можно
+// редактировать: изменения текста и кнопок переносятся в сообщения каналов.
+func (h *Handler) UpdatePost(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	if post.Status == "sending" {
+		h.renderEditPost(c, post, "Post is being sent, try again later")
+		return
+	}
+	// Форма хранит статус, с которым её открыли: за это время пост мог
+	// уйти в отправку по расписанию
+	if status := c.PostForm("status"); status != "" && status != post.Status {
+		h.renderPostConflict(c, post)
+		return
+	}
+
+	before := *post
+	post.Content = c.PostForm("content")
+	post.Buttons = parseButtons(c)
+	post.Variables = parseVariables(c.PostForm("variables"))
+	variantList, err := parseVariants(c)
+	if err != nil {
+		h.renderEditPost(c, &before, err.Error())
+		return
+	}
+	post.Variants = variantList
+	post.Options, err = parseSendOptions(c)
+	if err != nil {
+		h.renderEditPost(c, &before, err.Error())
+		return
+	}
+
+	if post.Status != "sent" {
+		post.ScheduleTime = parseScheduleTime(c.PostForm("schedule_time"))
+		if post.ScheduleTime != nil {
+			post.Status = "scheduled"
+		} else {
+			post.Status = "draft"
+		}
+	}
+
+	// Теги сохраняются до правки сообщений, чтобы в них попали новые хэштеги
+	if err := h.storage.SetPostTags(c.Request.Context(), post.ID, parseIDs(c, "tags")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	failed, err := h.savePost(c.Request.Context(), post, before.Status, c.MustGet("username").(string), c.PostForm("reason"))
+	if errors.Is(err, errPostChanged) {
+		h.renderPostConflict(c, &before)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.record(c, audit.ActionPostUpdate, audit.TargetPost, post.ID, before, post)
+	if len(failed) > 0 {
+		h.renderEditPost(c, post, "Saved, but failed to edit messages: "+fmt.Sprint(failed))
+		return
+	}
+
+	c.Redirect(http.StatusFound, fmt.Sprintf("/admin/posts/%d/revisions", post.ID))
+}
+
+// PostRevisions показывает историю поста и сравнение двух ревизий:
+// from и to — номера ревизий, по умолчанию предпоследняя и последняя.
+func (h *Handler) PostRevisions(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	revs, err := h.storage.GetPostRevisions(c.Request.Context(), post.ID)
+	if err != nil {
+		h.render(c, http.StatusOK, "post_revisions.html", gin.H{
+			"Post":  post,
+			"Error": "Failed to load revisions",
+		})
+		return
+	}
+
+	data := gin.H{
+		"Post":      post,
+		"Revisions": revs,
+		"Error":     c.Query("error"),
+	}
+
+	if len(revs) > 0 {
+		to := findRevision(revs, c.Query("to"), revs[0])
+		from := to
+		if len(revs) > 1 {
+			from = findRevision(revs, c.Query("from"), revs[1])
+		}
+
+		data["From"] = from
+		data["To"] = to
+		data["Changes"] = revisions.Diff(from, to)
+	}
+
+	h.render(c, http.StatusOK, "post_revisions.html", data)
+}
+
+// findRevision ищет ревизию по номеру из строки запроса.
+func findRevision(revs []models.PostRevision, number string, fallback models.PostRevision) models.PostRevision {
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return fallback
+	}
+	for _, rev := range revs {
+		if rev.Number == n {
+			return rev
+		}
+	}
+	return fallback
+}
+
+// RestorePostRevision возвращает пост к содержимому ревизии, записывая
+// восстановление новой ревизией.
+func (h *Handler) RestorePostRevision(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	revID, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision id"})
+		return
+	}
+
+	rev, err := h.storage.GetPostRevision(c.Request.Context(), revID)
+	if err != nil || rev.PostID != post.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "revision not found"})
+		return
+	}
+
+	redirect := fmt.Sprintf("/admin/posts/%d/revisions", post.ID)
+
+	if post.Status == "sending" {
+		c.Redirect(http.StatusFound, redirect+"?error=Post+is+being+sent")
+		return
+	}
+	if post.Status == "sent" && (rev.MediaType != post.MediaType || rev.MediaPath != post.MediaPath) {
+		c.Redirect(http.StatusFound, redirect+"?error=Media+of+a+published+post+cannot+be+changed")
+		return
+	}
+
+	before := *post
+	revisions.Apply(post, rev)
+
+	reason := fmt.Sprintf("restored revision #%d", rev.Number)
+	failed, err := h.savePost(c.Request.Context(), post, before.Status, c.MustGet("username").(string), reason)
+	if errors.Is(err, errPostChanged) {
+		c.Redirect(http.StatusFound, redirect+"?error=Post+status+changed,+try+again")
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.record(c, audit.ActionPostRevisionRestore, audit.TargetPost, post.ID, before, post)
+	if len(failed) > 0 {
+		c.Redirect(http.StatusFound, redirect+"?error=Failed+to+edit+messages+in+some+channels")
+		return
+	}
+
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// errPostChanged — статус поста изменился после того, как его загрузили
+// для правки.
+var errPostChanged = errors.New("post status changed")
+
+// renderPostConflict показывает форму с текущим состоянием поста, статус
+// которого изменился во время правки.
+func (h *Handler) renderPostConflict(c *gin.Context, post *models.Post) {
+	if current, err := h.storage.GetPost(c.Request.Context(), post.ID); err == nil {
+		post = current
+	}
+	h.render(c, http.StatusConflict, "post_edit.html", h.postFormData(c, post,
+		"The post became "+post.Status+" while you were editing it, review it and save again"))
+}
+
+// savePost сохраняет пост и записывает ревизию, если статус поста в базе
+// всё ещё status. У опубликованного поста правятся отправленные
+// сообщения; каналы, где это не удалось, возвращаются списком.
+func (h *Handler) savePost(ctx context.Context, post *models.Post, status, author, reason string) ([]string, error) {
+	updated, err := h.storage.UpdatePostIfStatus(ctx, post, status)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errPostChanged
+	}
+
+	if _, err := revisions.Record(ctx, h.storage, post, author, reason); err != nil {
+		return nil, err
+	}
+
+	if post.Status != "sent" {
+		return nil, nil
+	}
+
+	return h.editPublished(ctx, post)
+}
+
+// editPublished переносит текст и кнопки поста в сообщения, уже
+// отправленные в каналы. Повторы сообщений не меняются.
+func (h *Handler) editPublished(ctx context.Context, post *models.Post) ([]string, error) {
+	deliveries, err := h.storage.GetPostChannels(ctx, models.DeliveryFilter{
+		PostID: post.ID,
+		Status: "sent",
+		Limit:  maxPostDeliveries,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Варианты и хэштеги тегов применяются так же, как при отправке
+	postTags, err := h.storage.GetPostTags(ctx, post.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []string
+	sender := delivery.Sender{Telegram: h.telegram, Media: h.media}
+	for _, pc := range deliveries {
+		// Пересланное сообщение изменить нельзя, а копия остаётся такой,
+		// какой её повторили
+		if pc.RepostOf != 0 {
+			continue
+		}
+		channel, err := h.storage.GetChannel(ctx, pc.ChannelID)
+		if err != nil {
+			failed = append(failed, strconv.Itoa(pc.ChannelID))
+			continue
+		}
+
+		published := tags.Decorate(variants.Apply(*post, pc.Variant), postTags)
+		if err := sender.Edit(ctx, *channel, pc.MessageID, published, pc.SentAt); err != nil {
+			failed = append(failed, channel.Title)
+		}
+	}
+
+	return failed, nil
+}
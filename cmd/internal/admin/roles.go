@@ -0,0 +1,26 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/config"
+)
+
+// RequireRole пропускает только пользователей с одной из указанных ролей.
+// Должен подключаться после AuthMiddleware, который кладёт username в контекст.
+func RequireRole(cfg *config.Config, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := cfg.RoleOf(c.GetString("username"))
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Set("role", role)
+				c.Next()
+				return
+			}
+		}
+
+		c.String(http.StatusForbidden, "access denied")
+		c.Abort()
+	}
+}
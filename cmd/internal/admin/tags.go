@@ -0,0 +1,216 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// Статистика тегов по умолчанию считается за этот период.
+const tagStatisticsDays = 30
+
+// option — пункт списка с флажком в форме.
+type option struct {
+	ID       int
+	Title    string
+	Selected bool
+}
+
+type tagRow struct {
+	models.Tag
+	Channels []option
+	Stats    models.TagStatistics
+}
+
+// parseIDs читает список ID из повторяющегося поля формы.
+func parseIDs(c *gin.Context, field string) []int {
+	var ids []int
+	for _, value := range c.PostFormArray(field) {
+		if id, err := strconv.Atoi(value); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// tagOptions возвращает все теги с отметкой тегов поста.
+func (h *Handler) tagOptions(ctx context.Context, postID int) ([]option, error) {
+	all, err := h.storage.GetTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []models.Tag
+	if postID != 0 {
+		selected, err = h.storage.GetPostTags(ctx, postID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options := make([]option, len(all))
+	for i, tag := range all {
+		options[i] = option{ID: tag.ID, Title: tag.Name}
+		for _, s := range selected {
+			if s.ID == tag.ID {
+				options[i].Selected = true
+			}
+		}
+	}
+	return options, nil
+}
+
+// Tags показывает теги с их правилами и статистикой.
+func (h *Handler) Tags(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days <= 0 {
+		days = tagStatisticsDays
+	}
+
+	data := gin.H{
+		"Days":  days,
+		"Error": c.Query("error"),
+	}
+
+	tags, err := h.storage.GetTags(ctx)
+	if err != nil {
+		data["Error"] = "Failed to load tags"
+		h.render(c, http.StatusOK, "tags.html", data)
+		return
+	}
+	channels, err := h.storage.GetChannels(ctx)
+	if err != nil {
+		data["Error"] = "Failed to load channels"
+	}
+	stats, err := h.storage.GetTagStatistics(ctx, days)
+	if err != nil {
+		data["Error"] = "Failed to load tag statistics"
+	}
+
+	byTag := make(map[int]models.TagStatistics)
+	for _, st := range stats {
+		byTag[st.TagID] = st
+	}
+
+	rows := make([]tagRow, len(tags))
+	for i, tag := range tags {
+		rows[i] = tagRow{Tag: tag, Stats: byTag[tag.ID]}
+		for _, channel := range channels {
+			rows[i].Channels = append(rows[i].Channels, option{
+				ID:       channel.ID,
+				Title:    channel.Title,
+				Selected: containsID(tag.ChannelIDs, channel.ID),
+			})
+		}
+	}
+
+	data["Tags"] = rows
+	data["Channels"] = channels
+	h.render(c, http.StatusOK, "tags.html", data)
+}
+
+// parseTag читает тег из формы.
+func parseTag(c *gin.Context) models.Tag {
+	return models.Tag{
+		Name:       strings.TrimSpace(c.PostForm("name")),
+		Hashtag:    c.PostForm("hashtag") == "on",
+		ChannelIDs: parseIDs(c, "channels"),
+	}
+}
+
+func (h *Handler) CreateTag(c *gin.Context) {
+	tag := parseTag(c)
+	if tag.Name == "" {
+		redirectWithError(c, "/admin/tags", "Tag name is required")
+		return
+	}
+
+	if err := h.storage.CreateTag(c.Request.Context(), &tag); err != nil {
+		h.tagError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionTagCreate, audit.TargetTag, tag.ID, nil, tag)
+	c.Redirect(http.StatusFound, "/admin/tags")
+}
+
+func (h *Handler) UpdateTag(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	before, err := h.storage.GetTag(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	tag := parseTag(c)
+	tag.ID = id
+	if tag.Name == "" {
+		redirectWithError(c, "/admin/tags", "Tag name is required")
+		return
+	}
+
+	if err := h.storage.UpdateTag(c.Request.Context(), &tag); err != nil {
+		h.tagError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionTagUpdate, audit.TargetTag, id, before, tag)
+	c.Redirect(http.StatusFound, "/admin/tags")
+}
+
+func (h *Handler) DeleteTag(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	tag, err := h.storage.GetTag(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if err := h.storage.DeleteTag(c.Request.Context(), id); err != nil {
+		storageError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionTagDelete, audit.TargetTag, id, tag, nil)
+	c.Redirect(http.StatusFound, "/admin/tags")
+}
+
+func (h *Handler) tagError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrDuplicateName) {
+		redirectWithError(c, "/admin/tags", "A tag with this name already exists")
+		return
+	}
+	storageError(c, err)
+}
+
+// redirectWithError возвращает на страницу с сообщением об ошибке.
+func redirectWithError(c *gin.Context, path, message string) {
+	c.Redirect(http.StatusFound, path+"?error="+url.QueryEscape(message))
+}
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// DeletePost переносит пост в корзину.
+func (h *Handler) DeletePost(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	if post.Status == "sending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "post is being sent"})
+		return
+	}
+
+	if !h.trashAction(c, h.storage.DeletePost, post.ID) {
+		return
+	}
+
+	h.record(c, audit.ActionPostDelete, audit.TargetPost, post.ID, post, nil)
+	c.Redirect(http.StatusFound, "/admin/dashboard")
+}
+
+// DeleteChannel переносит канал в корзину. Доставки в него остаются в
+// статистике.
+func (h *Handler) DeleteChannel(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	channel, err := h.storage.GetChannel(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if !h.trashAction(c, h.storage.DeleteChannel, id) {
+		return
+	}
+
+	h.record(c, audit.ActionChannelDelete, audit.TargetChannel, id, channel, nil)
+	c.Redirect(http.StatusFound, "/admin/channels")
+}
+
+// Trash показывает удалённые посты и каналы.
+func (h *Handler) Trash(c *gin.Context) {
+	data := gin.H{
+		"RetentionDays": h.config.TrashRetentionDays,
+	}
+
+	posts, err := h.storage.GetDeletedPosts(c.Request.Context())
+	if err != nil {
+		data["Error"] = "Failed to load deleted posts"
+	}
+	channels, err := h.storage.GetDeletedChannels(c.Request.Context())
+	if err != nil {
+		data["Error"] = "Failed to load deleted channels"
+	}
+
+	data["Posts"] = posts
+	data["Channels"] = channels
+	h.render(c, http.StatusOK, "trash.html", data)
+}
+
+func (h *Handler) RestorePost(c *gin.Context) {
+	h.trashRedirect(c, h.storage.RestorePost, audit.ActionPostRestore, audit.TargetPost)
+}
+
+func (h *Handler) PurgePost(c *gin.Context) {
+	h.trashRedirect(c, h.storage.PurgePost, audit.ActionPostPurge, audit.TargetPost)
+}
+
+func (h *Handler) RestoreChannel(c *gin.Context) {
+	h.trashRedirect(c, h.storage.RestoreChannel, audit.ActionChannelRestore, audit.TargetChannel)
+}
+
+func (h *Handler) PurgeChannel(c *gin.Context) {
+	h.trashRedirect(c, h.storage.PurgeChannel, audit.ActionChannelPurge, audit.TargetChannel)
+}
+
+// trashRedirect выполняет действие над записью корзины из :id, пишет его
+// в журнал и возвращает на страницу корзины.
+func (h *Handler) trashRedirect(c *gin.Context, action func(ctx context.Context, id int) error, auditAction, targetType string) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	if !h.trashAction(c, action, id) {
+		return
+	}
+
+	h.record(c, auditAction, targetType, id, nil, nil)
+	c.Redirect(http.StatusFound, "/admin/trash")
+}
+
+func (h *Handler) trashAction(c *gin.Context, action func(ctx context.Context, id int) error, id int) bool {
+	if err := action(c.Request.Context(), id); err != nil {
+		storageError(c, err)
+		return false
+	}
+	return true
+}
+
+// paramID читает ID из параметра :id, отвечая 400 при ошибке.
+func paramID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// storageError отвечает ошибкой хранилища: 404 для отсутствующей записи.
+func storageError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,169 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/preview"
+	"github.com/maksekak/channelBot/cmd/internal/variants"
+)
+
+// maxVariantName — длина колонки post_channels.variant.
+const maxVariantName = 50
+
+// variantForm — вариант поста в форме правки.
+type variantForm struct {
+	Name     string
+	Content  string
+	Buttons  []models.Button
+	Channels []option
+}
+
+// variantForms возвращает варианты поста для формы и пустой вариант для
+// добавления нового.
+func (h *Handler) variantForms(ctx context.Context, post *models.Post) ([]variantForm, error) {
+	channels, err := h.storage.GetChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := append(append([]models.PostVariant(nil), post.Variants...), models.PostVariant{})
+	forms := make([]variantForm, len(list))
+	for i, v := range list {
+		forms[i] = variantForm{Name: v.Name, Content: v.Content}
+		json.Unmarshal(v.Buttons, &forms[i].Buttons)
+		for _, channel := range channels {
+			forms[i].Channels = append(forms[i].Channels, option{
+				ID:       channel.ID,
+				Title:    channel.Title,
+				Selected: containsID(v.ChannelIDs, channel.ID),
+			})
+		}
+	}
+	return forms, nil
+}
+
+// parseVariants читает варианты из полей variant_name и variant_content;
+// каналы и кнопки i-го варианта — из variant_channels_i и
+// variant_button_text_i/variant_button_url_i. Полностью пустые варианты
+// пропускаются.
+func parseVariants(c *gin.Context) ([]models.PostVariant, error) {
+	names := c.PostFormArray("variant_name")
+	contents := c.PostFormArray("variant_content")
+
+	var list []models.PostVariant
+	seen := make(map[string]bool)
+	pinned := make(map[int]bool)
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		content := ""
+		if i < len(contents) {
+			content = contents[i]
+		}
+		if name == "" && strings.TrimSpace(content) == "" {
+			continue
+		}
+
+		switch {
+		case name == "":
+			return nil, errors.New("Variant name is required")
+		case len(name) > maxVariantName:
+			return nil, fmt.Errorf("Variant name %q is too long", name)
+		case seen[name]:
+			return nil, fmt.Errorf("Duplicate variant name %q", name)
+		case strings.TrimSpace(content) == "":
+			return nil, fmt.Errorf("Variant %q has no text", name)
+		}
+		seen[name] = true
+
+		v := models.PostVariant{
+			Name:       name,
+			Content:    content,
+			ChannelIDs: parseIDs(c, fmt.Sprintf("variant_channels_%d", i)),
+		}
+		for _, id := range v.ChannelIDs {
+			if pinned[id] {
+				return nil, errors.New("A channel can belong to one variant only")
+			}
+			pinned[id] = true
+		}
+		buttons := parseButtonFields(c, fmt.Sprintf("variant_button_text_%d", i), fmt.Sprintf("variant_button_url_%d", i))
+		if len(buttons) > 0 {
+			v.Buttons, _ = json.Marshal(buttons)
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// variantRow — строка сравнения вариантов поста.
+type variantRow struct {
+	models.VariantStatistics
+	Preview preview.Post
+	// SuccessRate — доля успешных доставок в процентах
+	SuccessRate float64
+}
+
+// PostVariants сравнивает варианты отправленного поста: в сколько каналов
+// ушёл каждый вариант и сколько доставок удалось.
+func (h *Handler) PostVariants(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	data := gin.H{"Post": post}
+
+	stats, err := h.storage.GetVariantStatistics(ctx, post.ID)
+	if err != nil {
+		data["Error"] = "Failed to load statistics"
+		h.render(c, http.StatusOK, "post_variants.html", data)
+		return
+	}
+
+	rows := make([]variantRow, len(stats))
+	for i, st := range stats {
+		rows[i] = variantRow{
+			VariantStatistics: st,
+			Preview:           postPreview(variants.Apply(*post, st.Variant)),
+		}
+		if total := st.Successful + st.Failed; total > 0 {
+			rows[i].SuccessRate = float64(st.Successful) * 100 / float64(total)
+		}
+	}
+	data["Variants"] = rows
+
+	deliveries, err := h.storage.GetPostChannels(ctx, models.DeliveryFilter{PostID: post.ID, Limit: maxPostDeliveries})
+	if err != nil {
+		data["Error"] = "Failed to load deliveries"
+	}
+	titles := make(map[int]string)
+	if channels, err := h.storage.GetChannels(ctx); err == nil {
+		for _, channel := range channels {
+			titles[channel.ID] = channel.Title
+		}
+	}
+	// Канал доставки по её ID — для исходных сообщений повторов
+	sources := make(map[int]int, len(deliveries))
+	for _, pc := range deliveries {
+		sources[pc.ID] = pc.ChannelID
+	}
+	data["Deliveries"] = deliveries
+	data["DeliveryChannels"] = sources
+	data["ChannelTitles"] = titles
+
+	list, err := h.storage.GetPostReposts(ctx, post.ID)
+	if err != nil {
+		data["Error"] = "Failed to load reposts"
+	}
+	data["Reposts"] = list
+
+	h.render(c, http.StatusOK, "post_variants.html", data)
+}
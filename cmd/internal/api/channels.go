@@ -0,0 +1,155 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+type channelRequest struct {
+	TelegramID int64  `json:"telegram_id" binding:"required"`
+	Username   string `json:"username"`
+	Title      string `json:"title" binding:"required"`
+	IsActive   *bool  `json:"is_active"`
+	Timezone   string `json:"timezone"`
+}
+
+func (r channelRequest) apply(channel *models.Channel, defaultTimezone string) {
+	channel.TelegramID = r.TelegramID
+	channel.Username = r.Username
+	channel.Title = r.Title
+	channel.IsActive = true
+	if r.IsActive != nil {
+		channel.IsActive = *r.IsActive
+	}
+	channel.Timezone = r.Timezone
+	if channel.Timezone == "" {
+		channel.Timezone = defaultTimezone
+	}
+}
+
+func (h *Handler) ListChannels(c *gin.Context) {
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	var active *bool
+	if v := c.Query("active"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			badRequest(c, "invalid active")
+			return
+		}
+		active = &b
+	}
+
+	channels, err := h.storage.GetChannels(c.Request.Context())
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	filtered := make([]models.Channel, 0, len(channels))
+	for _, channel := range channels {
+		if active == nil || channel.IsActive == *active {
+			filtered = append(filtered, channel)
+		}
+	}
+
+	start := min(offset, len(filtered))
+	end := min(start+limit, len(filtered))
+
+	c.JSON(http.StatusOK, ListResponse{Data: filtered[start:end], Limit: limit, Offset: offset})
+}
+
+func (h *Handler) GetChannel(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	channel, err := h.storage.GetChannel(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+func (h *Handler) CreateChannel(c *gin.Context) {
+	var req channelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	var channel models.Channel
+	req.apply(&channel, h.config.Timezone)
+
+	if err := h.storage.CreateChannel(c.Request.Context(), &channel); err != nil {
+		storageError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionChannelCreate, audit.TargetChannel, channel.ID, nil, channel)
+
+	c.JSON(http.StatusCreated, channel)
+}
+
+func (h *Handler) UpdateChannel(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var req channelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	channel, err := h.storage.GetChannel(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	before := *channel
+	req.apply(channel, h.config.Timezone)
+
+	if err := h.storage.UpdateChannel(c.Request.Context(), channel); err != nil {
+		storageError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionChannelUpdate, audit.TargetChannel, channel.ID, before, channel)
+
+	c.JSON(http.StatusOK, channel)
+}
+
+func (h *Handler) DeleteChannel(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	channel, err := h.storage.GetChannel(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if err := h.storage.DeleteChannel(c.Request.Context(), id); err != nil {
+		storageError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionChannelDelete, audit.TargetChannel, id, channel, nil)
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/reposts"
+)
+
+// repostRequest — каналы и способ повтора доставленного сообщения. Без
+// schedule_time сообщение повторяется сразу.
+type repostRequest struct {
+	ChannelIDs   []int      `json:"channel_ids" binding:"required"`
+	Mode         string     `json:"mode" binding:"required"`
+	ScheduleTime *time.Time `json:"schedule_time"`
+}
+
+func (h *Handler) ListDeliveries(c *gin.Context) {
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	postID, ok := queryInt(c, "post_id")
+	if !ok {
+		return
+	}
+	channelID, ok := queryInt(c, "channel_id")
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.storage.GetPostChannels(c.Request.Context(), models.DeliveryFilter{
+		PostID:    postID,
+		ChannelID: channelID,
+		Status:    c.Query("status"),
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []models.PostChannel{}
+	}
+
+	c.JSON(http.StatusOK, ListResponse{Data: deliveries, Limit: limit, Offset: offset})
+}
+
+func (h *Handler) GetDelivery(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.storage.GetPostChannel(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// RepostDelivery повторяет доставленное сообщение в других каналах через
+// copyMessage или forwardMessage. Новые сообщения появляются доставками
+// поста с repost_of.
+func (h *Handler) RepostDelivery(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var req repostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	if req.ScheduleTime != nil && !req.ScheduleTime.After(time.Now()) {
+		abortWithError(c, http.StatusUnprocessableEntity, "validation_error", "schedule_time must be in the future")
+		return
+	}
+
+	ctx := c.Request.Context()
+	source, err := h.storage.GetPostChannel(ctx, id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+	if err := reposts.Validate(*source, req.ChannelIDs, req.Mode); err != nil {
+		abortWithError(c, http.StatusUnprocessableEntity, "validation_error", err.Error())
+		return
+	}
+
+	list, err := reposts.Create(ctx, h.storage, *source, req.ChannelIDs, req.Mode, req.ScheduleTime, c.GetString("username"))
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+	h.record(c, audit.ActionPostRepost, audit.TargetPost, source.PostID, nil, list)
+
+	if req.ScheduleTime == nil {
+		// Отправка переживает запрос, поэтому контекст запроса не передаётся
+		go h.scheduler.Repost(context.Background(), list)
+	}
+
+	c.JSON(http.StatusAccepted, list)
+}
+
+func (h *Handler) Statistics(c *gin.Context) {
+	days := 7
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			badRequest(c, "invalid days")
+			return
+		}
+		days = n
+	}
+
+	stats, err := h.storage.GetStatistics(c.Request.Context(), days)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/config"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/scheduler"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// Handler обслуживает JSON API /api/v1 поверх того же хранилища,
+// что и HTML-админка.
+type Handler struct {
+	storage   storage.Storage
+	scheduler *scheduler.Scheduler
+	audit     *audit.Logger
+	config    *config.Config
+}
+
+func NewHandler(storage storage.Storage, scheduler *scheduler.Scheduler, config *config.Config) *Handler {
+	return &Handler{
+		storage:   storage,
+		scheduler: scheduler,
+		audit:     audit.New(storage),
+		config:    config,
+	}
+}
+
+// record пишет в журнал действие владельца API-токена.
+func (h *Handler) record(c *gin.Context, action, targetType string, targetID int, before, after interface{}) {
+	h.audit.Record(c.Request.Context(), audit.Event{
+		Actor:      c.GetString("username"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+		IP:         c.ClientIP(),
+	})
+}
+
+// ErrorBody — формат ошибок API.
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ListResponse — формат ответов со списками.
+type ListResponse struct {
+	Data   interface{} `json:"data"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+func abortWithError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, ErrorBody{Error: ErrorDetail{Code: code, Message: message}})
+}
+
+func badRequest(c *gin.Context, message string) {
+	abortWithError(c, http.StatusBadRequest, "bad_request", message)
+}
+
+// storageError переводит ошибку хранилища в ответ API.
+func storageError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		abortWithError(c, http.StatusNotFound, "not_found", "resource not found")
+		return
+	}
+	abortWithError(c, http.StatusInternalServerError, "internal_error", err.Error())
+}
+
+func pathID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		badRequest(c, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+// pagination читает limit и offset из query-параметров.
+func pagination(c *gin.Context) (limit, offset int, ok bool) {
+	limit = defaultLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			badRequest(c, "invalid limit")
+			return 0, 0, false
+		}
+		limit = min(n, maxLimit)
+	}
+
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			badRequest(c, "invalid offset")
+			return 0, 0, false
+		}
+		offset = n
+	}
+
+	return limit, offset, true
+}
+
+func queryInt(c *gin.Context, name string) (int, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		badRequest(c, "invalid "+name)
+		return 0, false
+	}
+	return n, true
+}
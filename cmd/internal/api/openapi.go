@@ -0,0 +1,233 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BasePath — префикс, под которым регистрируются маршруты Routes.
+const BasePath = "/api/v1"
+
+// Document — OpenAPI 3 документ.
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// BuildSpec строит документ по фактически зарегистрированным в gin маршрутам.
+// Маршруты API описываются по таблице Routes, остальные (HTML-админка,
+// вход) — как страницы и формы без схемы тела.
+func BuildSpec(registered gin.RoutesInfo, routes []Route) *Document {
+	registry := newSchemaRegistry()
+
+	byKey := make(map[string]Route, len(routes))
+	for _, route := range routes {
+		byKey[route.Method+" "+BasePath+route.Path] = route
+	}
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: "Telegram Channel Manager", Version: "1.0.0"},
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: registry.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerToken": {Type: "http", Scheme: "bearer", BearerFormat: "cbt_<hex>"},
+			},
+		},
+	}
+
+	errorSchema := registry.schemaOf(ErrorBody{})
+
+	for _, info := range registered {
+		path, pathParams := openAPIPath(info.Path)
+
+		op := &Operation{
+			OperationID: operationID(info.Method, info.Path),
+			Parameters:  pathParams,
+			Responses:   make(map[string]Response),
+		}
+
+		if route, ok := byKey[info.Method+" "+info.Path]; ok {
+			op.Summary = route.Summary
+			op.Tags = []string{route.Tag}
+			op.Security = []map[string][]string{{"bearerToken": {route.Scope}}}
+
+			for _, q := range route.Query {
+				op.Parameters = append(op.Parameters, Parameter{
+					Name: q.Name, In: "query", Description: q.Description, Schema: &Schema{Type: q.Type},
+				})
+			}
+
+			if route.Idempotent {
+				op.Parameters = append(op.Parameters, Parameter{
+					Name:        idempotencyHeader,
+					In:          "header",
+					Description: "Retries with the same key return the original result",
+					Schema:      &Schema{Type: "string"},
+				})
+			}
+
+			if route.Request != nil {
+				op.RequestBody = &RequestBody{
+					Required: true,
+					Content:  map[string]MediaType{"application/json": {Schema: registry.schemaOf(route.Request)}},
+				}
+			}
+
+			success := Response{Description: http.StatusText(route.Status)}
+			if route.Response != nil {
+				success.Content = map[string]MediaType{"application/json": {Schema: registry.schemaOf(route.Response)}}
+			}
+			op.Responses[strconv.Itoa(route.Status)] = success
+			op.Responses["default"] = Response{
+				Description: "Error",
+				Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+			}
+		} else {
+			describeWebRoute(op, info)
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(PathItem)
+		}
+		doc.Paths[path][strings.ToLower(info.Method)] = op
+	}
+
+	return doc
+}
+
+// describeWebRoute описывает маршрут HTML-интерфейса.
+func describeWebRoute(op *Operation, info gin.RouteInfo) {
+	op.Tags = []string{"web"}
+	op.Security = []map[string][]string{}
+
+	if strings.HasPrefix(info.Path, "/admin/") && !strings.HasPrefix(info.Path, "/admin/login") {
+		// Админка аутентифицируется сессионной cookie и CSRF-токеном формы
+		op.Tags = []string{"admin"}
+	}
+
+	switch info.Method {
+	case http.MethodGet:
+		op.Responses["200"] = Response{
+			Description: "HTML page",
+			Content:     map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}},
+		}
+	default:
+		op.RequestBody = &RequestBody{
+			Content: map[string]MediaType{
+				"application/x-www-form-urlencoded": {Schema: &Schema{Type: "object"}},
+				"multipart/form-data":               {Schema: &Schema{Type: "object"}},
+			},
+		}
+		op.Responses["302"] = Response{Description: "Redirect after the form is processed"}
+	}
+	op.Responses["403"] = Response{Description: "Forbidden"}
+}
+
+// openAPIPath переводит путь gin (/posts/:id) в нотацию OpenAPI (/posts/{id}).
+func openAPIPath(path string) (string, []Parameter) {
+	var params []Parameter
+	segments := strings.Split(path, "/")
+
+	for i, segment := range segments {
+		if len(segment) < 2 || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+
+		name := segment[1:]
+		schema := &Schema{Type: "string"}
+		if name == "id" {
+			schema = &Schema{Type: "integer"}
+		}
+
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+		segments[i] = "{" + name + "}"
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '*' || r == '-' || r == '.' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// SpecHandler отдаёт OpenAPI-документ. Документ строится при первом
+// запросе, когда все маршруты роутера уже зарегистрированы.
+func SpecHandler(router *gin.Engine, routes []Route) gin.HandlerFunc {
+	var (
+		once sync.Once
+		doc  *Document
+	)
+
+	return func(c *gin.Context) {
+		once.Do(func() {
+			doc = BuildSpec(router.Routes(), routes)
+		})
+		c.JSON(http.StatusOK, doc)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/config"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+func TestBuildSpecDescribesEveryRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	h := NewHandler(storage.NewMemoryStorage(), nil, &config.Config{})
+	r := gin.New()
+	h.Register(r.Group(BasePath))
+	r.GET("/admin/dashboard", func(c *gin.Context) {})
+
+	routes := h.Routes()
+	doc := BuildSpec(r.Routes(), routes)
+
+	for _, route := range routes {
+		name := route.Method + " " + route.Path
+		if !slices.Contains(Scopes, route.Scope) {
+			t.Errorf("%s: unknown scope %q", name, route.Scope)
+		}
+
+		path, _ := openAPIPath(BasePath + route.Path)
+		op := doc.Paths[path][strings.ToLower(route.Method)]
+		if op == nil {
+			t.Errorf("%s: missing from the spec", name)
+			continue
+		}
+		want := []map[string][]string{{"bearerToken": {route.Scope}}}
+		if !reflect.DeepEqual(op.Security, want) {
+			t.Errorf("%s: security = %v, want %v", name, op.Security, want)
+		}
+		if _, ok := op.Responses[strconv.Itoa(route.Status)]; !ok {
+			t.Errorf("%s: no %d response", name, route.Status)
+		}
+		if (op.RequestBody != nil) != (route.Request != nil) {
+			t.Errorf("%s: request body = %v, want %v", name, op.RequestBody != nil, route.Request != nil)
+		}
+	}
+
+	web := doc.Paths["/admin/dashboard"][strings.ToLower(http.MethodGet)]
+	if web == nil || len(web.Security) != 0 {
+		t.Errorf("web route = %+v, want it described without bearer security", web)
+	}
+}
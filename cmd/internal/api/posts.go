@@ -0,0 +1,355 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/revisions"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	replayedHeader    = "Idempotent-Replayed"
+)
+
+type postRequest struct {
+	Content      string          `json:"content"`
+	MediaType    string          `json:"media_type"`
+	Buttons      []models.Button `json:"buttons"`
+	ScheduleTime *time.Time      `json:"schedule_time"`
+	// Options переопределяют настройки отправки каналов
+	Options models.SendOptions `json:"options"`
+	// Reason — комментарий к правке, сохраняется в ревизии
+	Reason string `json:"reason"`
+}
+
+type scheduleRequest struct {
+	ScheduleTime time.Time `json:"schedule_time" binding:"required"`
+}
+
+// recordRevision сохраняет ревизию поста после изменения через API.
+func (h *Handler) recordRevision(c *gin.Context, post *models.Post, reason string) bool {
+	if _, err := revisions.Record(c.Request.Context(), h.storage, post, c.GetString("username"), reason); err != nil {
+		storageError(c, err)
+		return false
+	}
+	return true
+}
+
+// apply переносит поля запроса в пост. Загрузка медиа через API пока
+// не поддерживается: новые посты только текстовые, а пост с медиа
+// сохраняет свой файл, если media_type не указан или не изменён.
+func (r postRequest) apply(c *gin.Context, post *models.Post) bool {
+	if r.MediaType == "" {
+		r.MediaType = post.MediaType
+	}
+	if r.MediaType == "" {
+		r.MediaType = "text"
+	}
+	if r.MediaType != "text" && (r.MediaType != post.MediaType || post.MediaPath == "") {
+		abortWithError(c, http.StatusUnprocessableEntity, "unsupported_media_type", "media can't be uploaded via API, only text posts can be created")
+		return false
+	}
+	if r.MediaType == "text" && r.Content == "" {
+		abortWithError(c, http.StatusUnprocessableEntity, "validation_error", "content is required")
+		return false
+	}
+
+	for _, button := range r.Buttons {
+		if button.Text == "" || button.URL == "" {
+			abortWithError(c, http.StatusUnprocessableEntity, "validation_error", "buttons require text and url")
+			return false
+		}
+	}
+
+	if err := r.Options.Validate(); err != nil {
+		abortWithError(c, http.StatusUnprocessableEntity, "validation_error", err.Error())
+		return false
+	}
+
+	buttonsJSON, _ := json.Marshal(r.Buttons)
+
+	post.Content = r.Content
+	if r.MediaType == "text" {
+		post.MediaPath = ""
+	}
+	post.MediaType = r.MediaType
+	post.Buttons = buttonsJSON
+	post.ScheduleTime = r.ScheduleTime
+	post.Options = r.Options
+
+	if r.ScheduleTime != nil {
+		post.Status = "scheduled"
+	} else {
+		post.Status = "draft"
+	}
+
+	return true
+}
+
+// isEditable сообщает, можно ли ещё менять пост: отправленные и
+// отправляемые посты неизменяемы.
+func isEditable(post *models.Post) bool {
+	return post.Status == "draft" || post.Status == "scheduled"
+}
+
+func (h *Handler) ListPosts(c *gin.Context) {
+	limit, offset, ok := pagination(c)
+	if !ok {
+		return
+	}
+
+	posts, err := h.storage.ListPosts(c.Request.Context(), models.PostFilter{
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if posts == nil {
+		posts = []models.Post{}
+	}
+
+	c.JSON(http.StatusOK, ListResponse{Data: posts, Limit: limit, Offset: offset})
+}
+
+func (h *Handler) GetPost(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	post, err := h.storage.GetPost(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+func (h *Handler) CreatePost(c *gin.Context) {
+	var req postRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	post := models.Post{
+		CreatedBy:      c.GetString("username"),
+		CreatedAt:      time.Now(),
+		IdempotencyKey: c.GetHeader(idempotencyHeader),
+	}
+	if !req.apply(c, &post) {
+		return
+	}
+
+	if err := h.storage.CreatePost(c.Request.Context(), &post); err != nil {
+		if errors.Is(err, storage.ErrDuplicateKey) {
+			h.replayCreatedPost(c, post.CreatedBy, post.IdempotencyKey)
+			return
+		}
+		storageError(c, err)
+		return
+	}
+
+	if !h.recordRevision(c, &post, "created") {
+		return
+	}
+
+	h.record(c, audit.ActionPostCreate, audit.TargetPost, post.ID, nil, post)
+
+	c.JSON(http.StatusCreated, post)
+}
+
+func (h *Handler) UpdatePost(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var req postRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	post, err := h.storage.GetPost(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if !isEditable(post) {
+		abortWithError(c, http.StatusConflict, "conflict", "post is already "+post.Status)
+		return
+	}
+
+	before := *post
+	if !req.apply(c, post) {
+		return
+	}
+
+	if !h.savePost(c, post, before.Status) {
+		return
+	}
+
+	if !h.recordRevision(c, post, req.Reason) {
+		return
+	}
+
+	h.record(c, audit.ActionPostUpdate, audit.TargetPost, post.ID, before, post)
+
+	c.JSON(http.StatusOK, post)
+}
+
+// savePost сохраняет пост, только если его статус всё ещё status: между
+// чтением и записью пост мог забрать на отправку планировщик или SendPost.
+func (h *Handler) savePost(c *gin.Context, post *models.Post, status string) bool {
+	updated, err := h.storage.UpdatePostIfStatus(c.Request.Context(), post, status)
+	if err != nil {
+		storageError(c, err)
+		return false
+	}
+	if !updated {
+		abortWithError(c, http.StatusConflict, "conflict", "post status changed, try again")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) DeletePost(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	post, err := h.storage.GetPost(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if post.Status == "sending" {
+		abortWithError(c, http.StatusConflict, "conflict", "post is being sent")
+		return
+	}
+
+	if err := h.storage.DeletePost(c.Request.Context(), id); err != nil {
+		storageError(c, err)
+		return
+	}
+
+	h.record(c, audit.ActionPostDelete, audit.TargetPost, id, post, nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) SchedulePost(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	var req scheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	if !req.ScheduleTime.After(time.Now()) {
+		abortWithError(c, http.StatusUnprocessableEntity, "validation_error", "schedule_time must be in the future")
+		return
+	}
+
+	post, err := h.storage.GetPost(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if !isEditable(post) {
+		abortWithError(c, http.StatusConflict, "conflict", "post is already "+post.Status)
+		return
+	}
+
+	before := *post
+	post.ScheduleTime = &req.ScheduleTime
+	post.Status = "scheduled"
+
+	if !h.savePost(c, post, before.Status) {
+		return
+	}
+
+	if !h.recordRevision(c, post, "scheduled") {
+		return
+	}
+
+	h.record(c, audit.ActionPostSchedule, audit.TargetPost, post.ID, before, post)
+
+	c.JSON(http.StatusOK, post)
+}
+
+// SendPost ставит пост на немедленную отправку. Отправка идёт в фоне,
+// поэтому ответ — 202 с постом в статусе sending.
+func (h *Handler) SendPost(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+
+	key := c.GetHeader(idempotencyHeader)
+
+	claimed, err := h.storage.ClaimPostForSending(c.Request.Context(), id, key)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	post, err := h.storage.GetPost(c.Request.Context(), id)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	if !claimed {
+		// Повтор запроса с тем же ключом возвращает исходный результат
+		if key != "" && post.SendIdempotencyKey == key {
+			c.Header(replayedHeader, "true")
+			c.JSON(http.StatusAccepted, post)
+			return
+		}
+		abortWithError(c, http.StatusConflict, "conflict", "post is already "+post.Status)
+		return
+	}
+
+	h.record(c, audit.ActionPostSend, audit.TargetPost, post.ID, nil, nil)
+
+	// Отправка переживает запрос, поэтому контекст запроса не передаётся
+	go h.scheduler.Publish(context.Background(), *post)
+
+	c.JSON(http.StatusAccepted, post)
+}
+
+// replayCreatedPost отвечает на повторное создание поста с тем же ключом
+// идемпотентности ранее созданным постом.
+func (h *Handler) replayCreatedPost(c *gin.Context, createdBy, key string) {
+	post, err := h.storage.GetPostByIdempotencyKey(c.Request.Context(), createdBy, key)
+	if err != nil {
+		storageError(c, err)
+		return
+	}
+
+	c.Header(replayedHeader, "true")
+	c.JSON(http.StatusCreated, post)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/config"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+func TestPostRequestApplyKeepsMedia(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		name      string
+		post      models.Post
+		req       postRequest
+		ok        bool
+		mediaType string
+		mediaPath string
+	}{
+		{"new text post", models.Post{}, postRequest{Content: "hi"}, true, "text", ""},
+		{"new photo post", models.Post{}, postRequest{Content: "hi", MediaType: "photo"}, false, "", ""},
+		{"new post without content", models.Post{}, postRequest{}, false, "", ""},
+		{"photo post, media type omitted", models.Post{MediaType: "photo", MediaPath: "a.jpg"}, postRequest{Content: "caption"}, true, "photo", "a.jpg"},
+		{"photo post without caption", models.Post{MediaType: "photo", MediaPath: "a.jpg"}, postRequest{}, true, "photo", "a.jpg"},
+		{"photo post, same media type", models.Post{MediaType: "photo", MediaPath: "a.jpg"}, postRequest{MediaType: "photo"}, true, "photo", "a.jpg"},
+		{"photo post turned into video", models.Post{MediaType: "photo", MediaPath: "a.jpg"}, postRequest{MediaType: "video"}, false, "", ""},
+		{"photo post turned into text", models.Post{MediaType: "photo", MediaPath: "a.jpg"}, postRequest{Content: "hi", MediaType: "text"}, true, "text", ""},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		post := tc.post
+		ok := tc.req.apply(c, &post)
+		if ok != tc.ok {
+			t.Errorf("%s: apply = %v, want %v", tc.name, ok, tc.ok)
+			continue
+		}
+		if !ok {
+			if c.Writer.Status() != http.StatusUnprocessableEntity {
+				t.Errorf("%s: status = %d, want 422", tc.name, c.Writer.Status())
+			}
+			continue
+		}
+		if post.MediaType != tc.mediaType || post.MediaPath != tc.mediaPath {
+			t.Errorf("%s: media = %q %q, want %q %q", tc.name, post.MediaType, post.MediaPath, tc.mediaType, tc.mediaPath)
+		}
+	}
+}
+
+// claimingStorage отдаёт пост и сразу забирает его на отправку, как
+// планировщик, успевший между чтением и записью API.
+type claimingStorage struct {
+	storage.Storage
+}
+
+func (s claimingStorage) GetPost(ctx context.Context, id int) (*models.Post, error) {
+	post, err := s.Storage.GetPost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Storage.ClaimPostForSending(ctx, id, ""); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func TestPostUpdateConflictsWithSending(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPut, "/posts/:id", `{"content": "edited"}`},
+		{http.MethodPost, "/posts/:id/schedule", `{"schedule_time": "2100-01-01T10:00:00Z"}`},
+	} {
+		store := storage.NewMemoryStorage()
+		post := models.Post{Content: "hello", MediaType: "text", Status: "draft"}
+		if err := store.CreatePost(context.Background(), &post); err != nil {
+			t.Fatal(err)
+		}
+
+		h := NewHandler(claimingStorage{store}, nil, &config.Config{})
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("username", "ci") })
+		handler := h.UpdatePost
+		if strings.HasSuffix(tc.path, "/schedule") {
+			handler = h.SchedulePost
+		}
+		r.Handle(tc.method, tc.path, handler)
+
+		path := strings.Replace(tc.path, ":id", "1", 1)
+		req := httptest.NewRequest(tc.method, path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("%s %s = %d, want 409", tc.method, path, w.Code)
+		}
+
+		got, _ := store.GetPost(context.Background(), post.ID)
+		if got.Status != "sending" || got.Content != "hello" {
+			t.Errorf("%s %s: post = %q %q, want it left sending", tc.method, path, got.Status, got.Content)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+// Route описывает маршрут API. Из этой же таблицы маршруты регистрируются
+// в gin и строится OpenAPI-документ, поэтому контракт не расходится с кодом.
+type Route struct {
+	Method  string
+	Path    string // относительно /api/v1, в нотации gin
+	Summary string
+	Tag     string
+	Scope   string
+	Query   []QueryParam
+	// Idempotent — маршрут принимает заголовок Idempotency-Key
+	Idempotent bool
+	Request    interface{} // образец тела запроса, nil — без тела
+	Response   interface{} // образец тела ответа, nil — без тела
+	Status     int
+	Handler    gin.HandlerFunc
+}
+
+type QueryParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// listOf помечает ответ-список ListResponse с элементами типа item.
+type listOf struct {
+	item interface{}
+}
+
+var paginationParams = []QueryParam{
+	{Name: "limit", Type: "integer", Description: "Page size, at most 100"},
+	{Name: "offset", Type: "integer", Description: "Number of items to skip"},
+}
+
+func (h *Handler) Routes() []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: "/channels", Summary: "List channels", Tag: "channels", Scope: ScopeChannelsRead,
+			Query:    append([]QueryParam{{Name: "active", Type: "boolean", Description: "Filter by activity"}}, paginationParams...),
+			Response: listOf{models.Channel{}}, Status: http.StatusOK, Handler: h.ListChannels},
+		{Method: http.MethodPost, Path: "/channels", Summary: "Create channel", Tag: "channels", Scope: ScopeChannelsWrite,
+			Request: channelRequest{}, Response: models.Channel{}, Status: http.StatusCreated, Handler: h.CreateChannel},
+		{Method: http.MethodGet, Path: "/channels/:id", Summary: "Get channel", Tag: "channels", Scope: ScopeChannelsRead,
+			Response: models.Channel{}, Status: http.StatusOK, Handler: h.GetChannel},
+		{Method: http.MethodPut, Path: "/channels/:id", Summary: "Update channel", Tag: "channels", Scope: ScopeChannelsWrite,
+			Request: channelRequest{}, Response: models.Channel{}, Status: http.StatusOK, Handler: h.UpdateChannel},
+		{Method: http.MethodDelete, Path: "/channels/:id", Summary: "Move channel to trash", Tag: "channels", Scope: ScopeChannelsWrite,
+			Status: http.StatusNoContent, Handler: h.DeleteChannel},
+
+		{Method: http.MethodGet, Path: "/posts", Summary: "List posts", Tag: "posts", Scope: ScopePostsRead,
+			Query:    append([]QueryParam{{Name: "status", Type: "string", Description: "Filter by status"}}, paginationParams...),
+			Response: listOf{models.Post{}}, Status: http.StatusOK, Handler: h.ListPosts},
+		{Method: http.MethodPost, Path: "/posts", Summary: "Create post", Tag: "posts", Scope: ScopePostsWrite,
+			Request: postRequest{}, Response: models.Post{}, Status: http.StatusCreated, Idempotent: true, Handler: h.CreatePost},
+		{Method: http.MethodGet, Path: "/posts/:id", Summary: "Get post", Tag: "posts", Scope: ScopePostsRead,
+			Response: models.Post{}, Status: http.StatusOK, Handler: h.GetPost},
+		{Method: http.MethodPut, Path: "/posts/:id", Summary: "Update post", Tag: "posts", Scope: ScopePostsWrite,
+			Request: postRequest{}, Response: models.Post{}, Status: http.StatusOK, Handler: h.UpdatePost},
+		{Method: http.MethodDelete, Path: "/posts/:id", Summary: "Move post to trash", Tag: "posts", Scope: ScopePostsWrite,
+			Status: http.StatusNoContent, Handler: h.DeletePost},
+		{Method: http.MethodPost, Path: "/posts/:id/schedule", Summary: "Schedule post", Tag: "posts", Scope: ScopePostsWrite,
+			Request: scheduleRequest{}, Response: models.Post{}, Status: http.StatusOK, Handler: h.SchedulePost},
+		{Method: http.MethodPost, Path: "/posts/:id/send", Summary: "Send post now", Tag: "posts", Scope: ScopePostsSend,
+			Response: models.Post{}, Status: http.StatusAccepted, Idempotent: true, Handler: h.SendPost},
+
+		{Method: http.MethodGet, Path: "/deliveries", Summary: "List deliveries", Tag: "deliveries", Scope: ScopeDeliveriesRead,
+			Query: append([]QueryParam{
+				{Name: "post_id", Type: "integer", Description: "Filter by post"},
+				{Name: "channel_id", Type: "integer", Description: "Filter by channel"},
+				{Name: "status", Type: "string", Description: "Filter by delivery status"},
+			}, paginationParams...),
+			Response: listOf{models.PostChannel{}}, Status: http.StatusOK, Handler: h.ListDeliveries},
+		{Method: http.MethodGet, Path: "/deliveries/:id", Summary: "Get delivery", Tag: "deliveries", Scope: ScopeDeliveriesRead,
+			Response: models.PostChannel{}, Status: http.StatusOK, Handler: h.GetDelivery},
+		{Method: http.MethodPost, Path: "/deliveries/:id/repost", Summary: "Repost delivered message", Tag: "deliveries", Scope: ScopePostsSend,
+			Request: repostRequest{}, Response: []models.Repost{}, Status: http.StatusAccepted, Handler: h.RepostDelivery},
+
+		{Method: http.MethodGet, Path: "/statistics", Summary: "Get statistics", Tag: "statistics", Scope: ScopeStatisticsRead,
+			Query:    []QueryParam{{Name: "days", Type: "integer", Description: "Period in days"}},
+			Response: models.Statistics{}, Status: http.StatusOK, Handler: h.Statistics},
+	}
+}
+
+// Register регистрирует маршруты API в группе. Тела запросов проверяются
+// по тем же схемам, что публикуются в OpenAPI-документе.
+func (h *Handler) Register(group *gin.RouterGroup) {
+	registry := newSchemaRegistry()
+
+	for _, route := range h.Routes() {
+		handlers := []gin.HandlerFunc{RequireScope(route.Scope)}
+		if route.Request != nil {
+			handlers = append(handlers, validateBody(registry, registry.schemaOf(route.Request)))
+		}
+		handlers = append(handlers, route.Handler)
+
+		group.Handle(route.Method, route.Path, handlers...)
+	}
+}
+
+// maxRequestBody — предел размера JSON-тела запроса.
+const maxRequestBody = 1 << 20
+
+// validateBody проверяет JSON-тело запроса по схеме и возвращает тело
+// обратно в запрос для последующего биндинга. Тело больше maxRequestBody
+// отклоняется с 413.
+func validateBody(registry *schemaRegistry, schema *Schema) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.ContentType(), "application/json") {
+			abortWithError(c, http.StatusUnsupportedMediaType, "unsupported_media_type", "request body must be application/json")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBody))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abortWithError(c, http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit))
+			return
+		}
+		if err != nil {
+			badRequest(c, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			badRequest(c, "invalid JSON: "+err.Error())
+			return
+		}
+
+		if errs := registry.validate(schema, value, ""); len(errs) > 0 {
+			abortWithError(c, http.StatusBadRequest, "validation_error", strings.Join(errs, "; "))
+			return
+		}
+
+		c.Next()
+	}
+}
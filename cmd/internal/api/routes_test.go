@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveBody прогоняет тело через validateBody для схемы образца sample и
+// возвращает код ответа и код ошибки.
+func serveBody(t *testing.T, sample interface{}, contentType, body string) (int, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	registry := newSchemaRegistry()
+	r := gin.New()
+	r.POST("/", validateBody(registry, registry.schemaOf(sample)), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp ErrorBody
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp.Error.Code
+}
+
+func TestValidateBodyTooLarge(t *testing.T) {
+	body := `{"content":"` + strings.Repeat("a", maxRequestBody) + `"}`
+	code, errCode := serveBody(t, postRequest{}, "application/json", body)
+	if code != http.StatusRequestEntityTooLarge || errCode != "request_too_large" {
+		t.Errorf("oversized body = %d %q, want 413 request_too_large", code, errCode)
+	}
+
+	code, _ = serveBody(t, postRequest{}, "application/json", `{"content":"hi"}`)
+	if code != http.StatusNoContent {
+		t.Errorf("small body = %d, want it to pass", code)
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		code        int
+		errCode     string
+	}{
+		{"valid", "application/json", `{"telegram_id": -1001, "title": "News"}`, http.StatusNoContent, ""},
+		{"charset", "application/json; charset=utf-8", `{"telegram_id": -1001, "title": "News"}`, http.StatusNoContent, ""},
+		{"form", "application/x-www-form-urlencoded", `telegram_id=1&title=News`, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+		{"invalid JSON", "application/json", `{"telegram_id": `, http.StatusBadRequest, "bad_request"},
+		{"missing required", "application/json", `{"telegram_id": -1001}`, http.StatusBadRequest, "validation_error"},
+		{"wrong type", "application/json", `{"telegram_id": "-1001", "title": "News"}`, http.StatusBadRequest, "validation_error"},
+		{"unknown field", "application/json", `{"telegram_id": -1001, "title": "News", "active": true}`, http.StatusBadRequest, "validation_error"},
+	} {
+		code, errCode := serveBody(t, channelRequest{}, tc.contentType, tc.body)
+		if code != tc.code || errCode != tc.errCode {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, code, errCode, tc.code, tc.errCode)
+		}
+	}
+}
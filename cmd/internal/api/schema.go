@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema — подмножество JSON Schema, используемое OpenAPI 3.0.
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Nullable   bool               `json:"nullable,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+	// AdditionalProperties: false — поля вне Properties запрещены
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaRegistry строит схемы из Go-структур через reflection и хранит
+// именованные схемы для components/schemas.
+type schemaRegistry struct {
+	schemas map[string]*Schema
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]*Schema)}
+}
+
+// schemaOf возвращает схему для значения-образца v.
+func (r *schemaRegistry) schemaOf(v interface{}) *Schema {
+	if list, ok := v.(listOf); ok {
+		return &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"data":   {Type: "array", Items: r.schemaFor(reflect.TypeOf(list.item))},
+				"limit":  {Type: "integer"},
+				"offset": {Type: "integer"},
+			},
+			Required: []string{"data", "limit", "offset"},
+		}
+	}
+	return r.schemaFor(reflect.TypeOf(v))
+}
+
+func (r *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{Nullable: true}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := *r.schemaFor(t.Elem())
+		if s.Ref != "" {
+			// В OpenAPI 3.0 nullable рядом с $ref игнорируется
+			return &s
+		}
+		s.Nullable = true
+		return &s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.schemaFor(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := r.schemas[name]; !ok {
+			// Заглушка защищает от бесконечной рекурсии на циклических типах
+			r.schemas[name] = &Schema{}
+			*r.schemas[name] = *r.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	closed := false
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: &closed}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		s.Properties[name] = r.schemaFor(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
+
+func schemaName(t reflect.Type) string {
+	runes := []rune(t.Name())
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// resolve разворачивает $ref в именованную схему.
+func (r *schemaRegistry) resolve(s *Schema) *Schema {
+	for s.Ref != "" {
+		s = r.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// validate проверяет декодированное JSON-значение по схеме и возвращает
+// список нарушений.
+func (r *schemaRegistry) validate(s *Schema, value interface{}, path string) []string {
+	s = r.resolve(s)
+
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return []string{path + ": must not be null"}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{path + ": must be an object"}
+		}
+
+		var errs []string
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, joinPath(path, name)+": is required")
+			}
+		}
+
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if v, ok := obj[name]; ok {
+				errs = append(errs, r.validate(s.Properties[name], v, joinPath(path, name))...)
+			}
+		}
+
+		if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+			var unknown []string
+			for name := range obj {
+				if _, ok := s.Properties[name]; !ok {
+					unknown = append(unknown, name)
+				}
+			}
+			sort.Strings(unknown)
+			for _, name := range unknown {
+				errs = append(errs, joinPath(path, name)+": unknown field")
+			}
+		}
+		return errs
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return []string{path + ": must be an array"}
+		}
+
+		var errs []string
+		for i, item := range arr {
+			errs = append(errs, r.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{path + ": must be a string"}
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return []string{path + ": must be an RFC 3339 date-time"}
+			}
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return []string{path + ": must be one of " + strings.Join(s.Enum, ", ")}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return []string{path + ": must be an integer"}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{path + ": must be a number"}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{path + ": must be a boolean"}
+		}
+	}
+
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
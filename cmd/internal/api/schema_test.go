@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaOf(t *testing.T) {
+	registry := newSchemaRegistry()
+	schema := registry.resolve(registry.schemaOf(channelRequest{}))
+
+	if schema.Type != "object" || schema.AdditionalProperties == nil || *schema.AdditionalProperties {
+		t.Fatalf("channelRequest schema = %+v, want a closed object", schema)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"telegram_id", "title"}) {
+		t.Errorf("required = %v, want telegram_id and title", schema.Required)
+	}
+	for name, want := range map[string]Schema{
+		"telegram_id": {Type: "integer", Format: "int64"},
+		"username":    {Type: "string"},
+		"is_active":   {Type: "boolean", Nullable: true},
+	} {
+		if got := schema.Properties[name]; got == nil || !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s = %+v, want %+v", name, got, want)
+		}
+	}
+
+	post := registry.resolve(registry.schemaOf(postRequest{}))
+	if got := post.Properties["schedule_time"]; got.Format != "date-time" || !got.Nullable {
+		t.Errorf("schedule_time = %+v, want nullable date-time", got)
+	}
+	if buttons := post.Properties["buttons"]; buttons.Type != "array" || registry.resolve(buttons.Items).Properties["url"] == nil {
+		t.Errorf("buttons = %+v, want an array of Button", buttons)
+	}
+	if _, ok := registry.schemas["Button"]; !ok {
+		t.Error("Button is not registered in components")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	registry := newSchemaRegistry()
+
+	for _, tc := range []struct {
+		name   string
+		sample interface{}
+		body   string
+		errs   []string
+	}{
+		{"valid channel", channelRequest{}, `{"telegram_id": -1001, "title": "News", "is_active": null}`, nil},
+		{"missing required", channelRequest{}, `{"username": "news"}`,
+			[]string{"telegram_id: is required", "title: is required"}},
+		{"null required", channelRequest{}, `{"telegram_id": null, "title": "News"}`,
+			[]string{"telegram_id: must not be null"}},
+		{"not an object", channelRequest{}, `[1, 2]`, []string{": must be an object"}},
+		{"string for integer", channelRequest{}, `{"telegram_id": "-1001", "title": "News"}`,
+			[]string{"telegram_id: must be an integer"}},
+		{"fraction for integer", channelRequest{}, `{"telegram_id": 1.5, "title": "News"}`,
+			[]string{"telegram_id: must be an integer"}},
+		{"number for string", channelRequest{}, `{"telegram_id": 1, "title": 5}`,
+			[]string{"title: must be a string"}},
+		{"string for boolean", channelRequest{}, `{"telegram_id": 1, "title": "News", "is_active": "yes"}`,
+			[]string{"is_active: must be a boolean"}},
+		{"unknown fields", channelRequest{}, `{"telegram_id": 1, "title": "News", "tilte": "x", "id": 3}`,
+			[]string{"id: unknown field", "tilte: unknown field"}},
+		{"bad date-time", scheduleRequest{}, `{"schedule_time": "tomorrow"}`,
+			[]string{"schedule_time: must be an RFC 3339 date-time"}},
+		{"valid date-time", scheduleRequest{}, `{"schedule_time": "2026-10-19T10:00:00Z"}`, nil},
+		{"object for array", repostRequest{}, `{"channel_ids": {}, "mode": "copy"}`,
+			[]string{"channel_ids: must be an array"}},
+		{"array item type", repostRequest{}, `{"channel_ids": [1, "2"], "mode": "copy"}`,
+			[]string{"channel_ids[1]: must be an integer"}},
+		{"nested object", postRequest{}, `{"buttons": [{"text": "Open", "url": 1}], "options": {"protect_content": "no"}}`,
+			[]string{"buttons[0].url: must be a string", "options.protect_content: must be a boolean"}},
+		{"nested unknown field", postRequest{}, `{"buttons": [{"text": "Open", "link": "https://example.com"}]}`,
+			[]string{"buttons[0].link: unknown field"}},
+	} {
+		var value interface{}
+		if err := json.Unmarshal([]byte(tc.body), &value); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		errs := registry.validate(registry.schemaOf(tc.sample), value, "")
+		if !reflect.DeepEqual(errs, tc.errs) {
+			t.Errorf("%s: validate = %q, want %q", tc.name, errs, tc.errs)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// Области доступа персональных API-токенов.
+const (
+	ScopeChannelsRead   = "channels:read"
+	ScopeChannelsWrite  = "channels:write"
+	ScopePostsRead      = "posts:read"
+	ScopePostsWrite     = "posts:write"
+	ScopePostsSend      = "posts:send"
+	ScopeDeliveriesRead = "deliveries:read"
+	ScopeStatisticsRead = "statistics:read"
+)
+
+// Scopes — все допустимые области доступа.
+var Scopes = []string{
+	ScopeChannelsRead,
+	ScopeChannelsWrite,
+	ScopePostsRead,
+	ScopePostsWrite,
+	ScopePostsSend,
+	ScopeDeliveriesRead,
+	ScopeStatisticsRead,
+}
+
+const tokenPrefix = "cbt_"
+
+// GenerateToken создаёт новый токен. Клиенту отдаётся plain, в базе
+// хранится только hash.
+func GenerateToken() (plain, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	plain = tokenPrefix + hex.EncodeToString(b)
+	return plain, HashToken(plain), nil
+}
+
+func HashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenMiddleware аутентифицирует запрос по заголовку Authorization: Bearer.
+func TokenMiddleware(store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		plain, found := strings.CutPrefix(header, "Bearer ")
+		if !found || plain == "" {
+			abortWithError(c, http.StatusUnauthorized, "unauthorized", "missing bearer token")
+			return
+		}
+
+		token, err := store.GetAPITokenByHash(c.Request.Context(), HashToken(plain))
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				abortWithError(c, http.StatusUnauthorized, "unauthorized", "invalid token")
+				return
+			}
+			abortWithError(c, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+
+		if token.RevokedAt != nil {
+			abortWithError(c, http.StatusUnauthorized, "unauthorized", "token revoked")
+			return
+		}
+
+		store.TouchAPIToken(c.Request.Context(), token.ID)
+
+		c.Set("api_token", token)
+		c.Set("username", token.CreatedBy)
+		c.Next()
+	}
+}
+
+// RequireScope пропускает запрос, только если у токена есть нужная область.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := c.MustGet("api_token").(*models.APIToken)
+		if !ok || !token.HasScope(scope) {
+			abortWithError(c, http.StatusForbidden, "forbidden", "token lacks scope "+scope)
+			return
+		}
+		c.Next()
+	}
+}
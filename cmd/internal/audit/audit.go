@@ -0,0 +1,142 @@
+// Package audit пишет журнал действий пользователей, планировщика и бота.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// Служебные исполнители действий, не связанные с пользователем.
+const (
+	ActorScheduler = "scheduler"
+	ActorBot       = "bot"
+)
+
+// Действия. Формат — <объект>.<глагол>.
+const (
+	ActionPostCreate   = "post.create"
+	ActionPostUpdate   = "post.update"
+	ActionPostSchedule = "post.schedule"
+	ActionPostSend     = "post.send"
+	ActionPostSent     = "post.sent"
+	// Репост доставленного сообщения в другие каналы: запрос и отправка
+	ActionPostRepost   = "post.repost"
+	ActionPostReposted = "post.reposted"
+	// Комментарий бота к посту в группе обсуждения канала
+	ActionPostCommented = "post.commented"
+	// Откат к ревизии, в отличие от восстановления из корзины
+	ActionPostRevisionRestore = "post.restore_revision"
+	ActionPostDelete          = "post.delete"
+	ActionPostRestore         = "post.restore"
+	ActionPostPurge           = "post.purge"
+
+	ActionChannelCreate  = "channel.create"
+	ActionChannelUpdate  = "channel.update"
+	ActionChannelDelete  = "channel.delete"
+	ActionChannelRestore = "channel.restore"
+	ActionChannelPurge   = "channel.purge"
+
+	ActionTagCreate = "tag.create"
+	ActionTagUpdate = "tag.update"
+	ActionTagDelete = "tag.delete"
+
+	ActionTemplateCreate = "template.create"
+	ActionTemplateUpdate = "template.update"
+	ActionTemplateDelete = "template.delete"
+
+	ActionMediaUpload = "media.upload"
+	// Удаление файлов, на которые не ссылаются посты
+	ActionMediaCollect = "media.collect"
+
+	// Очистка корзины по сроку хранения
+	ActionTrashPurge = "trash.purge"
+
+	// Тема форума, которую бот узнал из обновлений или заметил переименование
+	ActionForumTopicSave = "forum_topic.save"
+
+	ActionAPITokenCreate = "api_token.create"
+	ActionAPITokenRevoke = "api_token.revoke"
+
+	ActionLogin       = "auth.login"
+	ActionLoginFailed = "auth.login_failed"
+	ActionLogout      = "auth.logout"
+)
+
+// Типы объектов.
+const (
+	TargetPost     = "post"
+	TargetChannel  = "channel"
+	TargetAPIToken = "api_token"
+	TargetTag      = "tag"
+	TargetMedia    = "media"
+	TargetTemplate = "template"
+	// Тема форума; ID объекта — ID темы в чате, чат указан в снимке
+	TargetForumTopic = "forum_topic"
+)
+
+// Actions перечисляет все действия для фильтра на странице журнала.
+var Actions = []string{
+	ActionPostCreate, ActionPostUpdate, ActionPostSchedule, ActionPostSend, ActionPostSent,
+	ActionPostRepost, ActionPostReposted, ActionPostCommented,
+	ActionPostRevisionRestore, ActionPostDelete, ActionPostRestore, ActionPostPurge,
+	ActionChannelCreate, ActionChannelUpdate, ActionChannelDelete, ActionChannelRestore, ActionChannelPurge,
+	ActionTagCreate, ActionTagUpdate, ActionTagDelete,
+	ActionTemplateCreate, ActionTemplateUpdate, ActionTemplateDelete,
+	ActionMediaUpload, ActionMediaCollect,
+	ActionTrashPurge,
+	ActionForumTopicSave,
+	ActionAPITokenCreate, ActionAPITokenRevoke,
+	ActionLogin, ActionLoginFailed, ActionLogout,
+}
+
+// Event описывает действие. Before и After сериализуются в JSON как есть.
+type Event struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   int
+	Before     interface{}
+	After      interface{}
+	IP         string
+}
+
+type Logger struct {
+	storage storage.Storage
+}
+
+func New(storage storage.Storage) *Logger {
+	return &Logger{storage: storage}
+}
+
+// Record сохраняет событие. Ошибка записи журнала не должна отменять уже
+// выполненное действие, поэтому она только логируется.
+func (l *Logger) Record(ctx context.Context, e Event) {
+	entry := models.AuditEntry{
+		Actor:      e.Actor,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Before:     snapshot(e.Before),
+		After:      snapshot(e.After),
+		IP:         e.IP,
+	}
+
+	if err := l.storage.CreateAuditEntry(ctx, &entry); err != nil {
+		log.Printf("Error writing audit entry %s %s: %v", e.Actor, e.Action, err)
+	}
+}
+
+func snapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
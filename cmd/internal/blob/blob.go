@@ -0,0 +1,23 @@
+// Package blob хранит файлы медиатеки по ключу: на локальном диске или в
+// S3-совместимом хранилище. Ключи — относительные пути через «/».
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotExist возвращается, если файла с ключом нет.
+var ErrNotExist = errors.New("blob: file does not exist")
+
+// Store — хранилище файлов.
+type Store interface {
+	// Put сохраняет size байт из r под ключом key, заменяя прежний файл.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open открывает файл на чтение; вызывающий закрывает его.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete удаляет файл; отсутствие файла ошибкой не считается.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,275 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config описывает S3-совместимое хранилище (AWS S3, MinIO и т. п.).
+type S3Config struct {
+	// Endpoint — адрес сервиса со схемой: https://s3.eu-central-1.amazonaws.com
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle адресует бакет путём (endpoint/bucket/key), а не поддоменом;
+	// нужен MinIO и большинству самостоятельно развёрнутых хранилищ
+	PathStyle bool
+}
+
+// S3 хранит файлы в бакете S3-совместимого хранилища. Запросы подписываются
+// AWS Signature Version 4, тело загрузки передаётся потоком без подписи.
+type S3 struct {
+	config S3Config
+	Client *http.Client
+	now    func() time.Time
+}
+
+var _ Store = (*S3)(nil)
+
+func NewS3(config S3Config) *S3 {
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &S3{config: config, Client: http.DefaultClient, now: time.Now}
+}
+
+// emptyPayloadHash — SHA-256 пустого тела.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	endpoint, err := url.Parse(strings.TrimRight(s.config.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	escaped := "/" + escapePath(key)
+	if s.config.PathStyle {
+		escaped = "/" + escapePath(s.config.Bucket) + escaped
+	} else {
+		endpoint.Host = s.config.Bucket + "." + endpoint.Host
+	}
+	return url.Parse(endpoint.Scheme + "://" + endpoint.Host + endpoint.EscapedPath() + escaped)
+}
+
+func (s *S3) request(ctx context.Context, method, key string, body io.Reader, payloadHash string) (*http.Request, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	return req, nil
+}
+
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, s.now())
+	return s.Client.Do(req)
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := s.request(ctx, http.MethodPut, key, io.NopCloser(r), "UNSIGNED-PAYLOAD")
+	if err != nil {
+		return err
+	}
+	// S3 не принимает загрузку без Content-Length
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError("put", key, resp)
+	}
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotExist
+	default:
+		defer resp.Body.Close()
+		return nil, responseError("get", key, resp)
+	}
+}
+
+func (s *S3) Exists(ctx context.Context, key string) (bool, error) {
+	req, err := s.request(ctx, http.MethodHead, key, nil, emptyPayloadHash)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, responseError("head", key, resp)
+	}
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return responseError("delete", key, resp)
+	}
+}
+
+// responseError превращает ответ S3 с ошибкой в error с началом XML-тела.
+func responseError(op, key string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("blob: s3 %s %q: %s: %s", op, key, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// sign добавляет к запросу заголовки x-amz-date и Authorization по схеме
+// AWS Signature Version 4. Заголовок x-amz-content-sha256 должен быть задан.
+func (s *S3) sign(req *http.Request, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+
+	// Подписываются host, content-type, range и все x-amz-* заголовки
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("x-amz-content-sha256"),
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.config.AccessKey+"/"+scope+
+		",SignedHeaders="+signedHeaders+",Signature="+signature)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, escape(k, true)+"="+escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath кодирует ключ по правилам S3, оставляя «/».
+func escapePath(key string) string {
+	return escape(key, false)
+}
+
+// escape кодирует всё, кроме A-Z a-z 0-9 - _ . ~; «/» кодируется, только
+// если escapeSlash.
+func escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hexSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
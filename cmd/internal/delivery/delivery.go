@@ -0,0 +1,112 @@
+// Package delivery отправляет пост в конкретный канал с учётом его
+// настроек. Через него проходят немедленная отправка из админ-панели и
+// отправка по расписанию.
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/media"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/telegram"
+	"github.com/maksekak/channelBot/cmd/internal/templates"
+)
+
+// Sender отправляет посты в каналы. Без Media водяные знаки не
+// накладываются.
+type Sender struct {
+	Telegram *telegram.Client
+	Media    *media.Library
+}
+
+// Send отправляет пост в канал и возвращает ID сообщения. К посту
+// добавляются подпись и кнопки канала, в текст и кнопки подставляются
+// переменные канала, фото получает водяной знак канала, если он задан.
+func (s Sender) Send(ctx context.Context, channel models.Channel, post models.Post) (int, error) {
+	post = templates.Render(WithChannel(post, channel), channel, time.Now())
+
+	if post.MediaType == "photo" && channel.WatermarkPath != "" && s.Media != nil {
+		photo, err := s.Media.Watermark(ctx, post, channel)
+		if err != nil {
+			return 0, fmt.Errorf("watermark: %w", err)
+		}
+		return s.Telegram.SendPhoto(channel.TelegramID, post, bytes.NewReader(photo))
+	}
+	return s.Telegram.SendMessage(channel.TelegramID, post)
+}
+
+// Edit переносит текст и кнопки поста в сообщение, отправленное в канал в
+// момент sentAt: подпись, кнопки и переменные канала подставляются так же,
+// как при отправке, дата остаётся датой отправки.
+func (s Sender) Edit(ctx context.Context, channel models.Channel, messageID int, post models.Post, sentAt time.Time) error {
+	post = templates.Render(WithChannel(post, channel), channel, sentAt)
+	return s.Telegram.EditMessage(channel.TelegramID, messageID, post)
+}
+
+// Repost копирует (models.RepostCopy) или пересылает (models.RepostForward)
+// сообщение messageID из канала source в канал target и возвращает ID
+// нового сообщения. Подпись и кнопки target не добавляются: сообщение
+// уходит как есть, с тихой отправкой, защитой и темой форума target.
+func (s Sender) Repost(ctx context.Context, source models.Channel, messageID int, target models.Channel, mode string) (int, error) {
+	options := withChannelOptions(models.SendOptions{}, target)
+	switch mode {
+	case models.RepostCopy:
+		return s.Telegram.CopyMessage(target.TelegramID, source.TelegramID, messageID, options)
+	case models.RepostForward:
+		return s.Telegram.ForwardMessage(target.TelegramID, source.TelegramID, messageID, options)
+	default:
+		return 0, fmt.Errorf("unknown repost mode: %s", mode)
+	}
+}
+
+// WithChannel применяет к посту настройки канала: добавляет подпись в
+// конец текста и кнопки канала после кнопок поста, а незаданные в посте
+// настройки отправки берёт из канала. Тема форума всегда берётся из канала.
+func WithChannel(post models.Post, channel models.Channel) models.Post {
+	if channel.Footer != "" && !post.Options.NoFooter {
+		if post.Content == "" {
+			post.Content = channel.Footer
+		} else {
+			post.Content += "\n\n" + channel.Footer
+		}
+	}
+
+	if !post.Options.NoChannelButtons {
+		var channelButtons []models.Button
+		json.Unmarshal(channel.Buttons, &channelButtons)
+		if len(channelButtons) > 0 {
+			var buttons []models.Button
+			json.Unmarshal(post.Buttons, &buttons)
+			post.Buttons, _ = json.Marshal(append(buttons, channelButtons...))
+		}
+	}
+
+	post.Options = withChannelOptions(post.Options, channel)
+	return post
+}
+
+// withChannelOptions дополняет незаданные настройки отправки настройками
+// канала и задаёт тему форума канала.
+func withChannelOptions(options models.SendOptions, channel models.Channel) models.SendOptions {
+	if options.DisableNotification == nil {
+		options.DisableNotification = &channel.DisableNotification
+	}
+	if options.DisableLinkPreview == nil {
+		options.DisableLinkPreview = &channel.DisableLinkPreview
+	}
+	if options.ProtectContent == nil {
+		options.ProtectContent = &channel.ProtectContent
+	}
+	if options.LinkPreviewAbove == nil {
+		options.LinkPreviewAbove = &channel.LinkPreviewAbove
+	}
+	if options.LinkPreviewSize == "" {
+		options.LinkPreviewSize = channel.LinkPreviewSize
+	}
+	options.MessageThreadID = channel.MessageThreadID
+	return options
+}
@@ -0,0 +1,91 @@
+package delivery
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+func TestWithChannel(t *testing.T) {
+	channel := models.Channel{
+		Footer:              `<a href="https://t.me/news">Subscribe</a>`,
+		Buttons:             json.RawMessage(`[{"text":"Subscribe","url":"https://t.me/news"}]`),
+		DisableNotification: true,
+		ProtectContent:      true,
+		LinkPreviewSize:     models.LinkPreviewLarge,
+		MessageThreadID:     42,
+	}
+	post := models.Post{
+		Content: "Hello",
+		Buttons: json.RawMessage(`[{"text":"Read","url":"https://example.com"}]`),
+	}
+
+	got := WithChannel(post, channel)
+	if want := "Hello\n\n" + channel.Footer; got.Content != want {
+		t.Errorf("Content = %q, want %q", got.Content, want)
+	}
+	var buttons []models.Button
+	json.Unmarshal(got.Buttons, &buttons)
+	if len(buttons) != 2 || buttons[0].Text != "Read" || buttons[1].Text != "Subscribe" {
+		t.Errorf("Buttons = %+v", buttons)
+	}
+	if got.Options.DisableNotification == nil || !*got.Options.DisableNotification {
+		t.Errorf("DisableNotification = %v, want channel default true", got.Options.DisableNotification)
+	}
+	if got.Options.DisableLinkPreview == nil || *got.Options.DisableLinkPreview {
+		t.Errorf("DisableLinkPreview = %v, want channel default false", got.Options.DisableLinkPreview)
+	}
+	if got.Options.ProtectContent == nil || !*got.Options.ProtectContent {
+		t.Errorf("ProtectContent = %v, want channel default true", got.Options.ProtectContent)
+	}
+	if got.Options.LinkPreviewAbove == nil || *got.Options.LinkPreviewAbove || got.Options.LinkPreviewSize != models.LinkPreviewLarge {
+		t.Errorf("link preview = %v %q, want channel defaults", got.Options.LinkPreviewAbove, got.Options.LinkPreviewSize)
+	}
+	if got.Options.MessageThreadID != 42 {
+		t.Errorf("MessageThreadID = %d, want channel topic 42", got.Options.MessageThreadID)
+	}
+	if post.Options.DisableNotification != nil {
+		t.Error("WithChannel changed the original post")
+	}
+}
+
+func TestWithChannelOverrides(t *testing.T) {
+	channel := models.Channel{
+		Footer:              "Footer",
+		Buttons:             json.RawMessage(`[{"text":"Subscribe","url":"https://t.me/news"}]`),
+		DisableNotification: true,
+		ProtectContent:      true,
+		LinkPreviewSize:     models.LinkPreviewLarge,
+	}
+	loud := false
+	post := models.Post{
+		Content: "Hello",
+		Options: models.SendOptions{
+			DisableNotification: &loud,
+			ProtectContent:      &loud,
+			LinkPreviewSize:     models.LinkPreviewSmall,
+			NoFooter:            true,
+			NoChannelButtons:    true,
+		},
+	}
+
+	got := WithChannel(post, channel)
+	if got.Content != "Hello" {
+		t.Errorf("Content = %q, want footer skipped", got.Content)
+	}
+	if len(got.Buttons) != 0 {
+		t.Errorf("Buttons = %s, want channel buttons skipped", got.Buttons)
+	}
+	if *got.Options.DisableNotification {
+		t.Error("post override of DisableNotification is ignored")
+	}
+	if *got.Options.ProtectContent || got.Options.LinkPreviewSize != models.LinkPreviewSmall {
+		t.Error("post overrides of ProtectContent and LinkPreviewSize are ignored")
+	}
+
+	got = WithChannel(models.Post{}, models.Channel{Footer: "Footer"})
+	if got.Content != "Footer" {
+		t.Errorf("Content of empty post = %q, want footer only", got.Content)
+	}
+}
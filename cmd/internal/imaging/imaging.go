@@ -0,0 +1,110 @@
+// Package imaging готовит фотографии к отправке в Telegram: исправляет
+// ориентацию по EXIF, удаляет метаданные, уменьшает и пережимает в JPEG, а
+// также накладывает водяной знак канала.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Options задаёт обработку фото при загрузке.
+type Options struct {
+	// MaxSide — наибольшая сторона результата; Telegram всё равно
+	// уменьшает фото до 2560 точек
+	MaxSide int
+	// Quality — качество JPEG, 1–100
+	Quality int
+}
+
+var DefaultOptions = Options{MaxSide: 2560, Quality: 85}
+
+// maxPixels защищает от изображений, распаковка которых займёт слишком
+// много памяти.
+const maxPixels = 100_000_000
+
+var ErrTooManyPixels = errors.New("imaging: image is too large to process")
+
+// Image — результат обработки в формате JPEG.
+type Image struct {
+	Data          []byte
+	Width, Height int
+}
+
+// decode читает изображение с учётом EXIF-ориентации.
+func decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return orient(img, jpegOrientation(data)), nil
+}
+
+// Normalize исправляет ориентацию, уменьшает изображение до opts.MaxSide и
+// кодирует его в JPEG. Метаданные исходного файла в результат не попадают,
+// прозрачность заменяется белым фоном.
+func Normalize(r io.Reader, opts Options) (*Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	w, h := fit(b.Dx(), b.Dy(), opts.MaxSide)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	if w == b.Dx() && h == b.Dy() {
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	} else {
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	}
+
+	encoded, err := encode(dst, opts.Quality)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Data: encoded, Width: w, Height: h}, nil
+}
+
+// fit уменьшает размеры с сохранением пропорций, чтобы большая сторона не
+// превышала maxSide.
+func fit(w, h, maxSide int) (int, int) {
+	if maxSide <= 0 || (w <= maxSide && h <= maxSide) {
+		return w, h
+	}
+	if w >= h {
+		return maxSide, max(1, h*maxSide/w)
+	}
+	return max(1, w*maxSide/h), maxSide
+}
+
+func encode(img image.Image, quality int) ([]byte, error) {
+	if quality <= 0 || quality > 100 {
+		quality = DefaultOptions.Quality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
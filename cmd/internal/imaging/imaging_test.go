@@ -0,0 +1,164 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testImage — белое изображение с красным пикселем в левом верхнем углу.
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	img.Set(0, 0, color.NRGBA{R: 0xFF, A: 0xFF})
+	return img
+}
+
+// withOrientation добавляет в JPEG сегмент EXIF с тегом Orientation.
+func withOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], orientationTag)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	header := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(header[2:], uint16(len(segment)+2))
+
+	data := buf.Bytes()
+	return append(append(append([]byte{0xFF, 0xD8}, header...), segment...), data[2:]...)
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xC000 && g < 0x6000 && b < 0x6000
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	data := withOrientation(t, testImage(4, 2), 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Errorf("jpegOrientation = %d, want 6", got)
+	}
+
+	var plain bytes.Buffer
+	jpeg.Encode(&plain, testImage(4, 2), nil)
+	if got := jpegOrientation(plain.Bytes()); got != 1 {
+		t.Errorf("jpegOrientation(no exif) = %d, want 1", got)
+	}
+	if got := jpegOrientation([]byte("not a jpeg")); got != 1 {
+		t.Errorf("jpegOrientation(garbage) = %d, want 1", got)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// Красный пиксель в (0, 0) исходника 4×2 после поворота
+	tests := []struct {
+		orientation int
+		w, h        int
+		x, y        int
+	}{
+		{1, 4, 2, 0, 0},
+		{2, 4, 2, 3, 0},
+		{3, 4, 2, 3, 1},
+		{4, 4, 2, 0, 1},
+		{5, 2, 4, 0, 0},
+		{6, 2, 4, 1, 0},
+		{7, 2, 4, 1, 3},
+		{8, 2, 4, 0, 3},
+	}
+	for _, tt := range tests {
+		img := orient(testImage(4, 2), tt.orientation)
+		b := img.Bounds()
+		if b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("orient(%d) size = %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+		if !isRed(img.At(tt.x, tt.y)) {
+			t.Errorf("orient(%d): red pixel is not at (%d, %d)", tt.orientation, tt.x, tt.y)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	data := withOrientation(t, testImage(400, 100), 6)
+
+	result, err := Normalize(bytes.NewReader(data), Options{MaxSide: 200, Quality: 90})
+	if err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if result.Width != 50 || result.Height != 200 {
+		t.Errorf("Normalize size = %dx%d, want 50x200", result.Width, result.Height)
+	}
+	if jpegOrientation(result.Data) != 1 || bytes.Contains(result.Data, []byte("Exif")) {
+		t.Error("Normalize kept EXIF metadata")
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(result.Data))
+	if err != nil || format != "jpeg" || cfg.Width != 50 || cfg.Height != 200 {
+		t.Errorf("decoded result = %s %dx%d, %v", format, cfg.Width, cfg.Height, err)
+	}
+
+	// PNG с прозрачностью кладётся на белый фон
+	var transparent bytes.Buffer
+	png.Encode(&transparent, image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+	result, err = Normalize(&transparent, DefaultOptions)
+	if err != nil {
+		t.Fatalf("Normalize(png): %v", err)
+	}
+	img, _ := jpeg.Decode(bytes.NewReader(result.Data))
+	if r, g, b, _ := img.At(5, 5).RGBA(); r < 0xF000 || g < 0xF000 || b < 0xF000 {
+		t.Errorf("transparent pixel = %v, want white", img.At(5, 5))
+	}
+
+	if _, err := Normalize(bytes.NewReader([]byte("not an image")), DefaultOptions); err == nil {
+		t.Error("Normalize(garbage) error = nil")
+	}
+}
+
+func TestWatermark(t *testing.T) {
+	var photo bytes.Buffer
+	white := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for i := range white.Pix {
+		white.Pix[i] = 0xFF
+	}
+	jpeg.Encode(&photo, white, &jpeg.Options{Quality: 100})
+
+	logo := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	for i := 0; i < len(logo.Pix); i += 4 {
+		logo.Pix[i], logo.Pix[i+3] = 0xFF, 0xFF
+	}
+	var logoPNG bytes.Buffer
+	png.Encode(&logoPNG, logo)
+
+	data, err := Watermark(bytes.NewReader(photo.Bytes()), &logoPNG, PositionBottomRight, 95)
+	if err != nil {
+		t.Fatalf("Watermark: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Логотип уменьшен до 20% ширины (40×40) и стоит в правом нижнем углу
+	if !isRed(img.At(170, 70)) {
+		t.Errorf("pixel in the watermark = %v, want red", img.At(170, 70))
+	}
+	if isRed(img.At(20, 20)) || isRed(img.At(150, 50)) {
+		t.Error("watermark drawn outside the bottom-right corner")
+	}
+}
@@ -0,0 +1,81 @@
+package imaging
+
+import (
+	"image"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+// Положение водяного знака на фото.
+const (
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+	PositionCenter      = "center"
+)
+
+var Positions = []string{PositionBottomRight, PositionBottomLeft, PositionTopRight, PositionTopLeft, PositionCenter}
+
+// Водяной знак занимает не больше этой доли ширины фото и отступает от
+// краёв на долю меньшей стороны.
+const (
+	watermarkScale  = 0.2
+	watermarkMargin = 0.03
+)
+
+// Watermark накладывает logo на photo в положении position и возвращает
+// JPEG. Прозрачность логотипа сохраняется.
+func Watermark(photo, logo io.Reader, position string, quality int) ([]byte, error) {
+	photoData, err := io.ReadAll(photo)
+	if err != nil {
+		return nil, err
+	}
+	base, err := decode(photoData)
+	if err != nil {
+		return nil, err
+	}
+	logoData, err := io.ReadAll(logo)
+	if err != nil {
+		return nil, err
+	}
+	mark, err := decode(logoData)
+	if err != nil {
+		return nil, err
+	}
+
+	b := base.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), base, b.Min, draw.Src)
+
+	mb := mark.Bounds()
+	mw, mh := fit(mb.Dx(), mb.Dy(), int(float64(b.Dx())*watermarkScale))
+	if mw > mb.Dx() || mh > mb.Dy() {
+		mw, mh = mb.Dx(), mb.Dy()
+	}
+	rect := image.Rect(0, 0, mw, mh).Add(watermarkOrigin(dst.Bounds(), mw, mh, position))
+	draw.CatmullRom.Scale(dst, rect, mark, mb, draw.Over, nil)
+
+	return encode(dst, quality)
+}
+
+// watermarkOrigin возвращает левый верхний угол водяного знака.
+func watermarkOrigin(r image.Rectangle, w, h int, position string) image.Point {
+	margin := int(float64(min(r.Dx(), r.Dy())) * watermarkMargin)
+	left, top := margin, margin
+	right, bottom := r.Dx()-w-margin, r.Dy()-h-margin
+
+	switch position {
+	case PositionTopLeft:
+		return image.Pt(left, top)
+	case PositionTopRight:
+		return image.Pt(right, top)
+	case PositionBottomLeft:
+		return image.Pt(left, bottom)
+	case PositionCenter:
+		return image.Pt((r.Dx()-w)/2, (r.Dy()-h)/2)
+	default:
+		return image.Pt(right, bottom)
+	}
+}
@@ -0,0 +1,248 @@
+// Package media ведёт медиатеку: сохраняет загруженные файлы один раз по
+// хэшу содержимого, определяет их тип и размеры и удаляет файлы, на которые
+// больше не ссылаются посты.
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/blob"
+	"github.com/maksekak/channelBot/cmd/internal/imaging"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// GracePeriod защищает от сборки мусора только что загруженные файлы, пост
+// для которых ещё не сохранён.
+const GracePeriod = 24 * time.Hour
+
+// Library хранит файлы в blob.Store под ключом <2 символа хэша>/<хэш>/<имя>.
+// Каталог на хэш сохраняет исходное имя, с которым документ уйдёт в Telegram.
+type Library struct {
+	storage storage.Storage
+	files   blob.Store
+	Limits  Limits
+	// Images — обработка фото при загрузке; nil сохраняет фото как есть
+	Images *imaging.Options
+	// FFmpeg — путь к ffmpeg для миниатюр видео; пустой отключает их
+	FFmpeg string
+}
+
+func New(storage storage.Storage, files blob.Store) *Library {
+	images := imaging.DefaultOptions
+	return &Library{storage: storage, files: files, Limits: DefaultLimits, Images: &images}
+}
+
+// content — подготовленное к сохранению содержимое файла.
+type content struct {
+	io.ReaderAt
+	size int64
+}
+
+// Save проверяет и сохраняет файл в медиатеку. mediaType — тип поста, для
+// которого загружен файл; пустой тип определяется по содержимому. Фото
+// проходят обработку Images, хэш же считается по исходному файлу, поэтому
+// повторная загрузка того же файла возвращает существующую запись без
+// новой копии. Неподходящий файл — *ValidationError.
+func (l *Library) Save(ctx context.Context, r io.Reader, filename, mediaType, uploadedBy string) (*models.Media, error) {
+	filename = SanitizeFilename(filename)
+	limit := l.uploadLimit(mediaType)
+
+	// Хэш и размер нужны до записи в хранилище, поэтому файл сначала
+	// целиком попадает во временный
+	tmp, err := os.CreateTemp("", "channelbot-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// Лишний байт сверх предела показывает, что файл слишком велик
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > limit {
+		return nil, invalid("%s is too large: the limit is %s", filename, megabytes(limit))
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	existing, err := l.storage.GetMediaByHash(ctx, hash)
+	if err == nil {
+		if err := l.Limits.Check(existing, orDetected(mediaType, existing.MimeType)); err != nil {
+			return nil, err
+		}
+		return existing, l.restore(ctx, existing, tmp, size)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	media, data, err := l.prepare(tmp, size, filename, mediaType)
+	if err != nil {
+		return nil, err
+	}
+	media.Hash = hash
+	media.Path = path.Join(hash[:2], hash, media.Filename)
+	media.UploadedBy = uploadedBy
+
+	if err := l.Limits.Check(media, orDetected(mediaType, media.MimeType)); err != nil {
+		return nil, err
+	}
+	if err := l.put(ctx, media, data); err != nil {
+		return nil, err
+	}
+
+	if err := l.storage.CreateMedia(ctx, media); err != nil {
+		// Тот же файл одновременно загрузил кто-то ещё
+		if errors.Is(err, storage.ErrDuplicateKey) {
+			if existing, err := l.storage.GetMediaByHash(ctx, hash); err == nil {
+				if existing.Path != media.Path {
+					l.files.Delete(ctx, media.Path)
+				}
+				return existing, nil
+			}
+		}
+		l.files.Delete(ctx, media.Path)
+		return nil, err
+	}
+
+	// Миниатюру можно создать и позже, при первом показе
+	l.makeThumbnail(ctx, media.Path)
+
+	return media, nil
+}
+
+// uploadLimit возвращает предел размера загружаемого файла. Фото, которые
+// будут обработаны, проверяются по пределу после уменьшения.
+func (l *Library) uploadLimit(mediaType string) int64 {
+	if mediaType == "" || (mediaType == "photo" && l.Images != nil) {
+		return l.Limits.Max()
+	}
+	return l.Limits.For(mediaType)
+}
+
+// prepare определяет тип и размеры файла и обрабатывает фото. Возвращает
+// запись без хэша, пути и автора и содержимое для сохранения.
+func (l *Library) prepare(tmp *os.File, size int64, filename, mediaType string) (*models.Media, content, error) {
+	head := make([]byte, 512)
+	n, _ := tmp.ReadAt(head, 0)
+	mimeType := DetectType(head[:n])
+
+	if l.Images != nil && MediaType(mimeType) == "photo" && orDetected(mediaType, mimeType) == "photo" {
+		img, err := imaging.Normalize(io.NewSectionReader(tmp, 0, size), *l.Images)
+		if err != nil {
+			return nil, content{}, invalid("%s could not be processed as a photo: %v", filename, err)
+		}
+		media := &models.Media{
+			Filename: strings.TrimSuffix(filename, path.Ext(filename)) + ".jpg",
+			MimeType: "image/jpeg",
+			Size:     int64(len(img.Data)),
+			Width:    img.Width,
+			Height:   img.Height,
+		}
+		return media, content{bytes.NewReader(img.Data), media.Size}, nil
+	}
+
+	dims := probe(tmp, size, mimeType)
+	media := &models.Media{
+		Filename: filename,
+		MimeType: mimeType,
+		Size:     size,
+		Width:    dims.Width,
+		Height:   dims.Height,
+		Duration: dims.Duration,
+	}
+	return media, content{tmp, size}, nil
+}
+
+// orDetected возвращает mediaType или, если он не задан, тип по содержимому.
+func orDetected(mediaType, mimeType string) string {
+	if mediaType == "" {
+		return MediaType(mimeType)
+	}
+	return mediaType
+}
+
+// restore возвращает в хранилище файл существующей записи, если его
+// удалили оттуда вручную. Фото обрабатываются заново.
+func (l *Library) restore(ctx context.Context, media *models.Media, tmp *os.File, size int64) error {
+	ok, err := l.files.Exists(ctx, media.Path)
+	if err != nil || ok {
+		return err
+	}
+	_, data, err := l.prepare(tmp, size, media.Filename, MediaType(media.MimeType))
+	if err != nil {
+		return err
+	}
+	return l.put(ctx, media, data)
+}
+
+// put записывает подготовленное содержимое в хранилище.
+func (l *Library) put(ctx context.Context, media *models.Media, data content) error {
+	return l.files.Put(ctx, media.Path, io.NewSectionReader(data, 0, data.size), data.size, media.MimeType)
+}
+
+// Open открывает файл медиатеки или поста по ключу.
+func (l *Library) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return l.files.Open(ctx, key)
+}
+
+// GC удаляет файлы, загруженные раньше before, на которые не ссылается ни
+// один пост или ревизия, и возвращает их количество.
+func (l *Library) GC(ctx context.Context, before time.Time) (int, error) {
+	files, err := l.storage.GetUnreferencedMedia(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, media := range files {
+		if err := l.files.Delete(ctx, media.Path); err != nil {
+			return removed, err
+		}
+		if err := l.files.Delete(ctx, ThumbnailKey(media.Path)); err != nil {
+			return removed, err
+		}
+		if err := l.storage.DeleteMedia(ctx, media.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// DetectType определяет MIME-тип по первым 512 байтам содержимого.
+func DetectType(head []byte) string {
+	mimeType := http.DetectContentType(head)
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return mimeType
+}
+
+// MediaType возвращает тип поста, которым отправляется файл: Bot API
+// принимает как фото JPEG, PNG и WebP, как видео — MP4, остальное уходит
+// документом.
+func MediaType(mimeType string) string {
+	switch {
+	case mimeType == "image/jpeg", mimeType == "image/png", mimeType == "image/webp":
+		return "photo"
+	case mimeType == "video/mp4":
+		return "video"
+	default:
+		return "document"
+	}
+}
@@ -0,0 +1,296 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/blob"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+func pngBytes(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func mp4Box(typ string, body ...[]byte) []byte {
+	data := bytes.Join(body, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(8+len(data)))
+	copy(header[4:], typ)
+	return append(header, data...)
+}
+
+// mp4Bytes собирает минимальный MP4 с mvhd и tkhd версии 0.
+func mp4Bytes(timescale, duration uint32, width, height int) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:], duration)
+
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], uint32(width)<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], uint32(height)<<16)
+
+	audio := mp4Box("trak", mp4Box("tkhd", make([]byte, 84)))
+	video := mp4Box("trak", mp4Box("tkhd", tkhd))
+	ftyp := mp4Box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41"))
+	return bytes.Join([][]byte{ftyp, mp4Box("moov", mp4Box("mvhd", mvhd), audio, video)}, nil)
+}
+
+func TestProbeMP4(t *testing.T) {
+	data := mp4Bytes(1000, 12500, 1280, 720)
+	d, err := probeMP4(io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))))
+	if err != nil {
+		t.Fatalf("probeMP4: %v", err)
+	}
+	if d != (dimensions{Width: 1280, Height: 720, Duration: 13}) {
+		t.Errorf("probeMP4 = %+v", d)
+	}
+
+	broken := data[:len(data)-10]
+	if _, err := probeMP4(io.NewSectionReader(bytes.NewReader(broken), 0, int64(len(broken)))); err == nil {
+		t.Error("probeMP4(truncated) error = nil")
+	}
+}
+
+func TestSave(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	dir := t.TempDir()
+	lib := New(s, blob.NewLocal(dir))
+
+	data := pngBytes(t, 30, 20)
+	media, err := lib.Save(ctx, bytes.NewReader(data), "../../cat.png", "photo", "admin")
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	// Фото перекодируется в JPEG, хэш остаётся хэшем исходного файла
+	if media.MimeType != "image/jpeg" || media.Width != 30 || media.Height != 20 ||
+		media.Filename != "cat.jpg" || media.UploadedBy != "admin" {
+		t.Errorf("Save = %+v", media)
+	}
+	if want := media.Hash[:2] + "/" + media.Hash + "/cat.jpg"; media.Path != want {
+		t.Errorf("Path = %q, want %q", media.Path, want)
+	}
+	stored := filepath.Join(dir, filepath.FromSlash(media.Path))
+	if got, err := os.ReadFile(stored); err != nil || int64(len(got)) != media.Size || DetectType(got) != "image/jpeg" {
+		t.Errorf("stored file = %d bytes, %v", len(got), err)
+	}
+
+	again, err := lib.Save(ctx, bytes.NewReader(data), "copy.png", "", "editor")
+	if err != nil {
+		t.Fatalf("Save(duplicate): %v", err)
+	}
+	if again.ID != media.ID || again.Path != media.Path {
+		t.Errorf("Save(duplicate) = %+v, want existing %+v", again, media)
+	}
+
+	// Пропавший с диска файл восстанавливается повторной загрузкой
+	os.Remove(stored)
+	if _, err := lib.Save(ctx, bytes.NewReader(data), "cat.png", "photo", "admin"); err != nil {
+		t.Fatalf("Save(restore): %v", err)
+	}
+	if _, err := os.Stat(stored); err != nil {
+		t.Errorf("restored file: %v", err)
+	}
+
+	video, err := lib.Save(ctx, bytes.NewReader(mp4Bytes(600, 1200, 640, 360)), "clip.mp4", "video", "admin")
+	if err != nil {
+		t.Fatalf("Save(video): %v", err)
+	}
+	if video.MimeType != "video/mp4" || video.Width != 640 || video.Height != 360 || video.Duration != 2 {
+		t.Errorf("Save(video) = %+v", video)
+	}
+
+	// Без обработки фото сохраняется как есть
+	lib.Images = nil
+	raw := pngBytes(t, 5, 5)
+	original, err := lib.Save(ctx, bytes.NewReader(raw), "raw.png", "photo", "admin")
+	if err != nil {
+		t.Fatalf("Save(unprocessed): %v", err)
+	}
+	if original.MimeType != "image/png" || original.Size != int64(len(raw)) || original.Filename != "raw.png" {
+		t.Errorf("Save(unprocessed) = %+v", original)
+	}
+
+	files, _ := s.ListMedia(ctx, models.MediaFilter{Limit: 10})
+	if len(files) != 3 {
+		t.Errorf("ListMedia = %d files, want 3", len(files))
+	}
+}
+
+func TestGC(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	dir := t.TempDir()
+	lib := New(s, blob.NewLocal(dir))
+
+	used, err := lib.Save(ctx, bytes.NewReader(pngBytes(t, 1, 1)), "used.png", "photo", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused, err := lib.Save(ctx, strings.NewReader("plain text"), "notes.txt", "document", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := models.Post{MediaType: "photo", MediaPath: used.Path, Status: "draft"}
+	if err := s.CreatePost(ctx, &post); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := lib.GC(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("GC(grace period) = %d, %v, want 0", n, err)
+	}
+
+	n, err := lib.GC(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("GC = %d, want 1", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(unused.Path))); !os.IsNotExist(err) {
+		t.Errorf("unused file still exists: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(used.Path))); err != nil {
+		t.Errorf("used file removed: %v", err)
+	}
+	if _, err := s.GetMedia(ctx, unused.ID); err == nil {
+		t.Error("unused media record still exists")
+	}
+}
+
+func TestSaveValidation(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+	lib := New(s, blob.NewLocal(t.TempDir()))
+	lib.Limits = Limits{Photo: 1 << 10, Other: 4 << 10}
+
+	small := pngBytes(t, 4, 4)
+	large := bytes.Repeat([]byte("x"), 2<<10)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		mediaType string
+		wantErr   string
+	}{
+		{"empty", nil, "document", "empty"},
+		{"text as photo", []byte("hello"), "photo", "not a photo"},
+		{"png as video", small, "video", "not an MP4 video"},
+		{"photo over limit", append(append([]byte(nil), small...), bytes.Repeat(large, 3)...), "photo", "too large"},
+		{"document over limit", bytes.Repeat(large, 3), "document", "too large"},
+		{"unknown type", small, "sticker", "Unsupported"},
+		{"wide photo", pngBytes(t, 420, 20), "photo", "dimensions"},
+		{"document", large, "document", ""},
+		{"png as document", small, "document", ""},
+	}
+	for _, tt := range tests {
+		_, err := lib.Save(ctx, bytes.NewReader(tt.data), "file", tt.mediaType, "admin")
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("Save(%s) error = %v", tt.name, err)
+			}
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !strings.Contains(verr.Message, tt.wantErr) {
+			t.Errorf("Save(%s) error = %v, want validation error %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// Уже загруженный файл тоже проверяется на соответствие типу
+	if _, err := lib.Save(ctx, bytes.NewReader(small), "again.png", "video", "admin"); err == nil {
+		t.Error("Save(existing png as video) error = nil")
+	}
+
+	files, _ := s.ListMedia(ctx, models.MediaFilter{Limit: 10})
+	if len(files) != 2 {
+		t.Errorf("ListMedia = %d files, want only the 2 valid ones", len(files))
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := map[string]string{
+		"cat.png":                         "cat.png",
+		"../../etc/passwd":                "passwd",
+		`C:\Users\me\photo.jpg`:           "photo.jpg",
+		"a<b>:c\"d|e?f*.txt":              "a_b__c_d_e_f_.txt",
+		"line\nbreak.pdf":                 "line_break.pdf",
+		" .hidden. ":                      "hidden",
+		"..":                              "file",
+		"":                                "file",
+		"отчёт за май.docx":               "отчёт за май.docx",
+		strings.Repeat("я", 100) + ".pdf": strings.Repeat("я", 62) + ".pdf",
+	}
+	for name, want := range tests {
+		if got := SanitizeFilename(name); got != want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	lib := New(storage.NewMemoryStorage(), blob.NewLocal(dir))
+
+	photo, err := lib.Save(ctx, bytes.NewReader(pngBytes(t, 800, 400)), "wide.png", "photo", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Миниатюра создаётся при загрузке
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(ThumbnailKey(photo.Path)))); err != nil {
+		t.Errorf("thumbnail after Save: %v", err)
+	}
+
+	thumb, err := lib.Thumbnail(ctx, photo.Path)
+	if err != nil {
+		t.Fatalf("Thumbnail: %v", err)
+	}
+	defer thumb.Close()
+	cfg, format, err := image.DecodeConfig(thumb)
+	if err != nil || format != "jpeg" || cfg.Width != ThumbnailSide || cfg.Height != ThumbnailSide/2 {
+		t.Errorf("thumbnail = %s %dx%d, %v", format, cfg.Width, cfg.Height, err)
+	}
+
+	doc, err := lib.Save(ctx, strings.NewReader("plain text"), "notes.txt", "document", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.Thumbnail(ctx, doc.Path); !errors.Is(err, ErrNoThumbnail) {
+		t.Errorf("Thumbnail(document) error = %v, want ErrNoThumbnail", err)
+	}
+
+	// Без FFmpeg у видео миниатюры нет
+	video, err := lib.Save(ctx, bytes.NewReader(mp4Bytes(600, 1200, 640, 360)), "clip.mp4", "video", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lib.Thumbnail(ctx, video.Path); !errors.Is(err, ErrNoThumbnail) {
+		t.Errorf("Thumbnail(video) error = %v, want ErrNoThumbnail", err)
+	}
+
+	if _, err := lib.GC(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(ThumbnailKey(photo.Path)))); !os.IsNotExist(err) {
+		t.Errorf("thumbnail after GC: %v", err)
+	}
+}
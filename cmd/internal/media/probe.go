@@ -0,0 +1,148 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strings"
+)
+
+// dimensions описывает размеры и длительность файла; поля нулевые, если
+// их не удалось определить.
+type dimensions struct {
+	Width, Height int
+	// Duration в секундах
+	Duration int
+}
+
+// probe определяет размеры изображения или MP4-видео. Неизвестный формат
+// не считается ошибкой.
+func probe(r io.ReaderAt, size int64, mimeType string) dimensions {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		cfg, _, err := image.DecodeConfig(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return dimensions{}
+		}
+		return dimensions{Width: cfg.Width, Height: cfg.Height}
+	case mimeType == "video/mp4":
+		d, _ := probeMP4(io.NewSectionReader(r, 0, size))
+		return d
+	}
+	return dimensions{}
+}
+
+var errBadBox = errors.New("media: malformed mp4 box")
+
+// box — заголовок бокса ISO BMFF: тип и содержимое без заголовка.
+type box struct {
+	Type string
+	Body *io.SectionReader
+}
+
+// readBoxes перечисляет боксы одного уровня.
+func readBoxes(r *io.SectionReader, fn func(b box) error) error {
+	var offset int64
+	for offset < r.Size() {
+		var header [8]byte
+		if _, err := r.ReadAt(header[:], offset); err != nil {
+			return errBadBox
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// Бокс продолжается до конца файла
+			size = r.Size() - offset
+		case 1:
+			var large [8]byte
+			if _, err := r.ReadAt(large[:], offset+8); err != nil {
+				return errBadBox
+			}
+			size = int64(binary.BigEndian.Uint64(large[:]))
+			headerSize = 16
+		}
+		if size < headerSize || offset+size > r.Size() {
+			return errBadBox
+		}
+
+		body := io.NewSectionReader(r, offset+headerSize, size-headerSize)
+		if err := fn(box{Type: string(header[4:]), Body: body}); err != nil {
+			return err
+		}
+		offset += size
+	}
+	return nil
+}
+
+// probeMP4 читает длительность из mvhd и размеры первой видеодорожки из tkhd.
+func probeMP4(r *io.SectionReader) (dimensions, error) {
+	var d dimensions
+	err := readBoxes(r, func(b box) error {
+		if b.Type != "moov" {
+			return nil
+		}
+		return readBoxes(b.Body, func(b box) error {
+			switch b.Type {
+			case "mvhd":
+				d.Duration = mvhdDuration(b.Body)
+			case "trak":
+				return readBoxes(b.Body, func(b box) error {
+					if b.Type == "tkhd" && d.Width == 0 {
+						d.Width, d.Height = tkhdSize(b.Body)
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+	})
+	return d, err
+}
+
+// mvhdDuration возвращает длительность ролика в секундах, округляя вверх.
+func mvhdDuration(r *io.SectionReader) int {
+	data := make([]byte, 32)
+	n, _ := r.ReadAt(data, 0)
+	data = data[:n]
+
+	var timescale, duration uint64
+	switch {
+	case len(data) >= 32 && data[0] == 1:
+		timescale = uint64(binary.BigEndian.Uint32(data[20:24]))
+		duration = binary.BigEndian.Uint64(data[24:32])
+	case len(data) >= 20 && data[0] == 0:
+		timescale = uint64(binary.BigEndian.Uint32(data[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(data[16:20]))
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return int((duration + timescale - 1) / timescale)
+}
+
+// tkhdSize возвращает ширину и высоту дорожки; у звуковых дорожек они нулевые.
+func tkhdSize(r *io.SectionReader) (int, int) {
+	data := make([]byte, 96)
+	n, _ := r.ReadAt(data, 0)
+	data = data[:n]
+
+	// После полей времени идут 52 байта до размеров (резерв, слой, громкость, матрица)
+	offset := 4 + 20 + 52
+	if len(data) > 0 && data[0] == 1 {
+		offset = 4 + 32 + 52
+	}
+	if len(data) < offset+8 {
+		return 0, 0
+	}
+
+	// Размеры хранятся в формате 16.16
+	width := binary.BigEndian.Uint32(data[offset : offset+4])
+	height := binary.BigEndian.Uint32(data[offset+4 : offset+8])
+	return int(width >> 16), int(height >> 16)
+}
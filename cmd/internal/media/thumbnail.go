@@ -0,0 +1,118 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/maksekak/channelBot/cmd/internal/blob"
+	"github.com/maksekak/channelBot/cmd/internal/imaging"
+)
+
+// ThumbnailSide — наибольшая сторона миниатюры.
+const ThumbnailSide = 320
+
+var thumbnailOptions = imaging.Options{MaxSide: ThumbnailSide, Quality: 80}
+
+// ErrNoThumbnail означает, что для файла нельзя сделать миниатюру:
+// документ или видео без FFmpeg.
+var ErrNoThumbnail = errors.New("media: no thumbnail for this file")
+
+// ThumbnailKey возвращает ключ миниатюры файла в хранилище.
+func ThumbnailKey(key string) string {
+	return "thumbs/" + key + ".jpg"
+}
+
+// Thumbnail открывает JPEG-миниатюру файла, создавая её при первом
+// обращении: для фото — уменьшенную копию, для видео — первый кадр.
+func (l *Library) Thumbnail(ctx context.Context, key string) (io.ReadCloser, error) {
+	thumb, err := l.files.Open(ctx, ThumbnailKey(key))
+	if !errors.Is(err, blob.ErrNotExist) {
+		return thumb, err
+	}
+
+	data, err := l.makeThumbnail(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// makeThumbnail создаёт миниатюру файла и сохраняет её в хранилище.
+func (l *Library) makeThumbnail(ctx context.Context, key string) ([]byte, error) {
+	file, err := l.files.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Тип определяется по содержимому: у файлов старых постов нет записи
+	// в медиатеке
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	content := io.MultiReader(bytes.NewReader(head[:n]), file)
+
+	var data []byte
+	switch MediaType(DetectType(head[:n])) {
+	case "photo":
+		img, err := imaging.Normalize(content, thumbnailOptions)
+		if err != nil {
+			return nil, err
+		}
+		data = img.Data
+	case "video":
+		if l.FFmpeg == "" {
+			return nil, ErrNoThumbnail
+		}
+		if data, err = l.videoFrame(ctx, content); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrNoThumbnail
+	}
+
+	if err := l.files.Put(ctx, ThumbnailKey(key), bytes.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// videoFrame извлекает первый кадр видео через FFmpeg: декодера H.264 на
+// чистом Go нет. Видео сохраняется во временный файл, так как у MP4 индекс
+// кадров может стоять в конце.
+func (l *Library) videoFrame(ctx context.Context, video io.Reader) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "channelbot-video-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, video); err != nil {
+		return nil, err
+	}
+
+	side := strconv.Itoa(ThumbnailSide)
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, l.FFmpeg,
+		"-v", "error", "-i", tmp.Name(), "-frames:v", "1",
+		"-vf", "scale='min("+side+",iw)':'min("+side+",ih)':force_original_aspect_ratio=decrease",
+		"-f", "image2", "-c:v", "mjpeg", "pipe:1")
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if out.Len() == 0 {
+		return nil, ErrNoThumbnail
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,127 @@
+package media
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+// Limits — максимальные размеры файлов в байтах. Значения по умолчанию
+// совпадают с ограничениями Bot API: 10 МБ на фото, 50 МБ на остальное.
+type Limits struct {
+	Photo int64
+	Other int64
+}
+
+var DefaultLimits = Limits{Photo: 10 << 20, Other: 50 << 20}
+
+// For возвращает ограничение для типа поста.
+func (l Limits) For(mediaType string) int64 {
+	if mediaType == "photo" {
+		return l.Photo
+	}
+	return l.Other
+}
+
+// Max возвращает наибольшее из ограничений.
+func (l Limits) Max() int64 {
+	return max(l.Photo, l.Other)
+}
+
+// Ограничения Telegram на размеры фото: сумма сторон и соотношение сторон.
+const (
+	maxPhotoSides = 10000
+	maxPhotoRatio = 20
+)
+
+// ValidationError — файл не подходит для отправки; Message можно показать
+// пользователю как есть.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+// megabytes форматирует размер для сообщений об ошибках.
+func megabytes(size int64) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+}
+
+// Check проверяет, что файл можно отправить как mediaType: тип содержимого
+// соответствует методу Bot API, а размер не превышает ограничений.
+func (l Limits) Check(m *models.Media, mediaType string) error {
+	if m.Size == 0 {
+		return invalid("The file is empty")
+	}
+
+	switch mediaType {
+	case "photo":
+		if MediaType(m.MimeType) != "photo" {
+			return invalid("%s is not a photo (detected %s): photos must be JPEG, PNG or WebP", m.Filename, m.MimeType)
+		}
+		if m.Width > 0 && m.Height > 0 {
+			long, short := max(m.Width, m.Height), min(m.Width, m.Height)
+			if m.Width+m.Height > maxPhotoSides || long > short*maxPhotoRatio {
+				return invalid("Photo dimensions %d×%d exceed Telegram limits; send it as a document", m.Width, m.Height)
+			}
+		}
+	case "video":
+		if MediaType(m.MimeType) != "video" {
+			return invalid("%s is not an MP4 video (detected %s); send it as a document", m.Filename, m.MimeType)
+		}
+	case "document":
+	default:
+		return invalid("Unsupported media type %q", mediaType)
+	}
+
+	if limit := l.For(mediaType); m.Size > limit {
+		return invalid("%s is too large: %s, the limit for %s is %s", m.Filename, megabytes(m.Size), mediaType, megabytes(limit))
+	}
+	return nil
+}
+
+// maxFilenameLength — предел длины имени файла в байтах.
+const maxFilenameLength = 128
+
+// SanitizeFilename делает имя файла безопасным для ключа хранилища и
+// заголовков: оставляет последний элемент пути, заменяет управляющие и
+// запрещённые в Windows символы, обрезает длину с сохранением расширения.
+func SanitizeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"|?*`, r) || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return "file"
+	}
+
+	if len(name) > maxFilenameLength {
+		ext := path.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		base := name[:maxFilenameLength-len(ext)]
+		// Не разрезаем многобайтовый символ
+		for !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		name = base + ext
+	}
+	return name
+}
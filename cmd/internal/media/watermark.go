@@ -0,0 +1,83 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"io"
+
+	"github.com/maksekak/channelBot/cmd/internal/imaging"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+// Логотип водяного знака: PNG с прозрачностью или JPEG разумного размера.
+const (
+	maxWatermarkSize = 2 << 20
+	maxWatermarkSide = 4096
+)
+
+// SaveWatermark проверяет и сохраняет логотип водяного знака канала и
+// возвращает его ключ в хранилище. Одинаковые логотипы хранятся одним
+// файлом. Неподходящий файл — *ValidationError.
+func (l *Library) SaveWatermark(ctx context.Context, r io.Reader, filename string) (string, error) {
+	filename = SanitizeFilename(filename)
+	data, err := io.ReadAll(io.LimitReader(r, maxWatermarkSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", invalid("%s is empty", filename)
+	}
+	if len(data) > maxWatermarkSize {
+		return "", invalid("%s is too large: the limit is %s", filename, megabytes(maxWatermarkSize))
+	}
+
+	mimeType := DetectType(data)
+	ext := map[string]string{"image/png": ".png", "image/jpeg": ".jpg"}[mimeType]
+	if ext == "" {
+		return "", invalid("%s is not a PNG or JPEG image", filename)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+		return "", invalid("%s could not be read as an image", filename)
+	}
+	if cfg.Width > maxWatermarkSide || cfg.Height > maxWatermarkSide {
+		return "", invalid("%s is too large: a logo must fit in %dx%d", filename, maxWatermarkSide, maxWatermarkSide)
+	}
+
+	sum := sha256.Sum256(data)
+	key := "watermarks/" + hex.EncodeToString(sum[:]) + ext
+	if err := l.files.Put(ctx, key, bytes.NewReader(data), int64(len(data)), mimeType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// DeleteWatermark удаляет логотип, на который больше не ссылается ни один
+// канал.
+func (l *Library) DeleteWatermark(ctx context.Context, key string) error {
+	return l.files.Delete(ctx, key)
+}
+
+// Watermark возвращает фото поста с водяным знаком канала в формате JPEG.
+func (l *Library) Watermark(ctx context.Context, post models.Post, channel models.Channel) ([]byte, error) {
+	photo, err := l.files.Open(ctx, post.MediaPath)
+	if err != nil {
+		return nil, err
+	}
+	defer photo.Close()
+
+	logo, err := l.files.Open(ctx, channel.WatermarkPath)
+	if err != nil {
+		return nil, err
+	}
+	defer logo.Close()
+
+	quality := imaging.DefaultOptions.Quality
+	if l.Images != nil {
+		quality = l.Images.Quality
+	}
+	return imaging.Watermark(photo, logo, channel.WatermarkPosition, quality)
+}
@@ -0,0 +1,442 @@
+// Package migrate применяет встроенные SQL-миграции и ведёт их учёт в
+// таблице schema_migrations.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+	"github.com/maksekak/channelBot/migrations"
+)
+
+// Migration — одна версия схемы.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status — состояние миграции в базе.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	// Modified — файл изменён после применения миграции
+	Modified bool
+}
+
+// Migrator применяет миграции к одной базе.
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect
+	migrations []Migration
+}
+
+type dialect struct {
+	name string
+	// lock блокирует параллельный запуск миграций и возвращает функцию
+	// снятия блокировки.
+	lock        func(ctx context.Context, db *sql.DB) (func(), error)
+	placeholder func(n int) string
+	// hasTable — запрос числа таблиц с именем из первого параметра
+	hasTable string
+}
+
+// initTable — таблица первой миграции. Если она есть, а учёт миграций
+// пуст, схема создана вручную до появления раннера.
+const initTable = "posts"
+
+// Ключ advisory lock PostgreSQL, общий для всех реплик.
+const advisoryLockKey = 7268430152691103
+
+var postgresDialect = dialect{
+	name: "postgres",
+	lock: func(ctx context.Context, db *sql.DB) (func(), error) {
+		// Блокировка сессионная, поэтому держим отдельное соединение
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockKey); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return func() {
+			conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockKey)
+			conn.Close()
+		}, nil
+	},
+	placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+	hasTable:    `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1`,
+}
+
+var sqliteDialect = dialect{
+	name: "sqlite3",
+	// SQLite рассчитан на один узел; запись сериализует сама база,
+	// а каждая миграция идёт в отдельной транзакции.
+	lock: func(ctx context.Context, db *sql.DB) (func(), error) {
+		return func() {}, nil
+	},
+	placeholder: func(int) string { return "?" },
+	hasTable:    `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+}
+
+// Open подключается к базе из DATABASE_URL и выбирает набор миграций по схеме.
+func Open(databaseURL string) (*Migrator, error) {
+	var (
+		db    *sql.DB
+		d     dialect
+		files fs.FS
+		dir   string
+		err   error
+	)
+
+	switch {
+	case strings.HasPrefix(databaseURL, "postgres://"), strings.HasPrefix(databaseURL, "postgresql://"):
+		db, err = sql.Open("postgres", databaseURL)
+		d, files, dir = postgresDialect, migrations.Postgres, "."
+	case strings.HasPrefix(databaseURL, "sqlite:"):
+		db, err = sql.Open("sqlite3", storage.SQLitePath(databaseURL)+"?_fk=1&_busy_timeout=5000")
+		d, files, dir = sqliteDialect, migrations.SQLite, "sqlite"
+		if db != nil {
+			db.SetMaxOpenConns(1)
+		}
+	default:
+		return nil, fmt.Errorf("migrate: unsupported DATABASE_URL scheme in %q", databaseURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	list, err := Load(files, dir)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: d, migrations: list}, nil
+}
+
+// New создаёт Migrator поверх открытой базы. driver — "postgres" или "sqlite3".
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	var (
+		d     dialect
+		files fs.FS
+		dir   string
+	)
+
+	switch driver {
+	case "postgres":
+		d, files, dir = postgresDialect, migrations.Postgres, "."
+	case "sqlite3":
+		d, files, dir = sqliteDialect, migrations.SQLite, "sqlite"
+	default:
+		return nil, fmt.Errorf("migrate: unsupported driver %q", driver)
+	}
+
+	list, err := Load(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: d, migrations: list}, nil
+}
+
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Load читает миграции NNN_name.sql и NNN_name.down.sql из каталога dir.
+func Load(files fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(name, ".sql")
+		isDown := strings.HasSuffix(base, ".down")
+		base = strings.TrimSuffix(base, ".down")
+
+		prefix, title, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("migrate: bad migration file name %q", name)
+		}
+
+		content, err := fs.ReadFile(files, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: title}
+			byVersion[version] = mig
+		}
+
+		if isDown {
+			mig.Down = string(content)
+		} else {
+			sum := sha256.Sum256(content)
+			mig.Up = string(content)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migrate: migration %03d has no up file", mig.Version)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list, nil
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		result[version] = a
+	}
+
+	return result, rows.Err()
+}
+
+// withLock выполняет fn под блокировкой миграций. Таблица учёта
+// создаётся тоже под блокировкой, иначе одновременно запущенные реплики
+// соревнуются на CREATE TABLE.
+func (m *Migrator) withLock(ctx context.Context, fn func(applied map[int]appliedMigration) error) error {
+	unlock, err := m.dialect.lock(ctx, m.db)
+	if err != nil {
+		return fmt.Errorf("migrate: acquire lock: %w", err)
+	}
+	defer unlock()
+
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+
+	// Состояние читается после блокировки: другая реплика могла успеть
+	// применить миграции, пока мы ждали.
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	return fn(applied)
+}
+
+// verify проверяет, что применённые миграции не менялись.
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	for _, mig := range m.migrations {
+		if a, ok := applied[mig.Version]; ok && a.checksum != mig.Checksum {
+			return fmt.Errorf("migrate: migration %03d_%s was modified after it was applied", mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+// Up применяет все ещё не применённые миграции по порядку. База со
+// схемой первой миграции, созданной вручную, сначала получает baseline
+// этой миграции, чтобы она не выполнялась повторно.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withLock(ctx, func(applied map[int]appliedMigration) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		if len(applied) == 0 && len(m.migrations) > 0 {
+			var n int
+			if err := m.db.QueryRowContext(ctx, m.dialect.hasTable, initTable).Scan(&n); err != nil {
+				return err
+			}
+			if n > 0 {
+				if err := m.markApplied(ctx, applied, m.migrations[0].Version); err != nil {
+					return fmt.Errorf("migrate: baseline existing schema: %w", err)
+				}
+			}
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			if err := m.run(ctx, mig.Up, func(tx *sql.Tx) error {
+				query := fmt.Sprintf(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (%s, %s, %s, %s)`,
+					m.dialect.placeholder(1), m.dialect.placeholder(2), m.dialect.placeholder(3), m.dialect.placeholder(4))
+				_, err := tx.ExecContext(ctx, query, mig.Version, mig.Name, mig.Checksum, time.Now().UTC())
+				return err
+			}); err != nil {
+				return fmt.Errorf("migrate: apply %03d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down откатывает steps последних применённых миграций.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withLock(ctx, func(applied map[int]appliedMigration) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migrate: migration %03d_%s has no down file", mig.Version, mig.Name)
+			}
+
+			if err := m.run(ctx, mig.Down, func(tx *sql.Tx) error {
+				query := `DELETE FROM schema_migrations WHERE version = ` + m.dialect.placeholder(1)
+				_, err := tx.ExecContext(ctx, query, mig.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migrate: roll back %03d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Baseline отмечает миграции до version включительно как применённые, не
+// выполняя их. Нужен для баз, схема которых создавалась вручную.
+func (m *Migrator) Baseline(ctx context.Context, version int) error {
+	return m.withLock(ctx, func(applied map[int]appliedMigration) error {
+		return m.markApplied(ctx, applied, version)
+	})
+}
+
+// markApplied записывает миграции до version включительно как
+// применённые и добавляет их в applied.
+func (m *Migrator) markApplied(ctx context.Context, applied map[int]appliedMigration, version int) error {
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		now := time.Now().UTC()
+		query := fmt.Sprintf(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (%s, %s, %s, %s)`,
+			m.dialect.placeholder(1), m.dialect.placeholder(2), m.dialect.placeholder(3), m.dialect.placeholder(4))
+		if _, err := m.db.ExecContext(ctx, query, mig.Version, mig.Name, mig.Checksum, now); err != nil {
+			return err
+		}
+		applied[mig.Version] = appliedMigration{checksum: mig.Checksum, appliedAt: now}
+	}
+	return nil
+}
+
+// Status возвращает все известные миграции с отметкой о применении.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var applied map[int]appliedMigration
+	err := m.withLock(ctx, func(a map[int]appliedMigration) error {
+		applied = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := Status{Migration: mig}
+		if a, ok := applied[mig.Version]; ok {
+			appliedAt := a.appliedAt
+			status.AppliedAt = &appliedAt
+			status.Modified = a.checksum != mig.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// run выполняет SQL миграции и запись в schema_migrations в одной транзакции.
+func (m *Migrator) run(ctx context.Context, script string, record func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
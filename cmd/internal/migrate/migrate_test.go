@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newSQLiteMigrator(t *testing.T) *Migrator {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "bot.db")+"?_fk=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	m := newSQLiteMigrator(t)
+	total := len(m.migrations)
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(done) != total {
+		t.Fatalf("Up applied %d migrations, want %d", len(done), total)
+	}
+
+	done, err = m.Up(ctx)
+	if err != nil || len(done) != 0 {
+		t.Fatalf("second Up = %d, %v; want nothing to apply", len(done), err)
+	}
+
+	rolledBack, err := m.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if len(rolledBack) != 1 || rolledBack[0].Version != m.migrations[total-1].Version {
+		t.Fatalf("Down rolled back %+v, want the last migration", rolledBack)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for i, status := range statuses {
+		applied := status.AppliedAt != nil
+		if want := i < total-1; applied != want {
+			t.Errorf("migration %03d applied = %v, want %v", status.Version, applied, want)
+		}
+	}
+
+	if _, err := m.Down(ctx, total); err != nil {
+		t.Fatalf("Down all: %v", err)
+	}
+	if done, err := m.Up(ctx); err != nil || len(done) != total {
+		t.Fatalf("Up after full rollback = %d, %v", len(done), err)
+	}
+}
+
+func TestModifiedMigrationIsRejected(t *testing.T) {
+	ctx := context.Background()
+	m := newSQLiteMigrator(t)
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	m.migrations[0].Checksum = "edited"
+
+	if _, err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Fatalf("Up with edited migration: got %v, want modification error", err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !statuses[0].Modified {
+		t.Error("Status did not flag the edited migration")
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	ctx := context.Background()
+	m := newSQLiteMigrator(t)
+
+	// Схема первой миграции создана вручную, как было до появления раннера
+	if _, err := m.db.Exec(m.migrations[0].Up); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Baseline(ctx, m.migrations[0].Version); err != nil {
+		t.Fatalf("Baseline: %v", err)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up after baseline: %v", err)
+	}
+	if len(done) != len(m.migrations)-1 {
+		t.Errorf("Up applied %d migrations, want %d", len(done), len(m.migrations)-1)
+	}
+}
+
+func TestUpBaselinesExistingSchema(t *testing.T) {
+	ctx := context.Background()
+	m := newSQLiteMigrator(t)
+
+	// Схема первой миграции создана вручную, учёта миграций ещё нет
+	if _, err := m.db.Exec(m.migrations[0].Up); err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up on existing schema: %v", err)
+	}
+	if len(done) != len(m.migrations)-1 || done[0].Version == m.migrations[0].Version {
+		t.Errorf("Up applied %d migrations, want all but the first", len(done))
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %03d is not applied", status.Version)
+		}
+	}
+}
@@ -2,23 +2,53 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
 	"time"
 )
 
 type Channel struct {
-	ID         int       `json:"id" db:"id"`
-	TelegramID int64     `json:"telegram_id" db:"telegram_id"`
-	Username   string    `json:"username" db:"username"`
-	Title      string    `json:"title" db:"title"`
-	IsActive   bool      `json:"is_active" db:"is_active"`
-	Timezone   string    `json:"timezone" db:"timezone"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	ID         int        `json:"id" db:"id"`
+	TelegramID int64      `json:"telegram_id" db:"telegram_id"`
+	Username   string     `json:"username" db:"username"`
+	Title      string     `json:"title" db:"title"`
+	IsActive   bool       `json:"is_active" db:"is_active"`
+	Timezone   string     `json:"timezone" db:"timezone"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+
+	// WatermarkPath — ключ логотипа в хранилище медиа, который накладывается
+	// на фото постов при отправке в канал; пустой — без водяного знака
+	WatermarkPath     string `json:"watermark_path,omitempty" db:"watermark_path"`
+	WatermarkPosition string `json:"watermark_position,omitempty" db:"watermark_position"`
+
+	// Footer — HTML-подпись, добавляемая в конец каждого поста, Buttons —
+	// кнопки канала после кнопок поста
+	Footer  string          `json:"footer,omitempty" db:"footer"`
+	Buttons json.RawMessage `json:"buttons,omitempty" db:"buttons"`
+	// Настройки отправки по умолчанию; пост может их переопределить
+	DisableNotification bool `json:"disable_notification" db:"disable_notification"`
+	DisableLinkPreview  bool `json:"disable_link_preview" db:"disable_link_preview"`
+	ProtectContent      bool `json:"protect_content" db:"protect_content"`
+	// LinkPreviewAbove показывает превью ссылки над текстом,
+	// LinkPreviewSize — размер медиа в превью (LinkPreviewSmall/Large)
+	LinkPreviewAbove bool   `json:"link_preview_above" db:"link_preview_above"`
+	LinkPreviewSize  string `json:"link_preview_size,omitempty" db:"link_preview_size"`
+
+	// MessageThreadID — тема форума супергруппы, в которую уходят посты;
+	// ноль — без темы
+	MessageThreadID int `json:"message_thread_id,omitempty" db:"message_thread_id"`
+	// DiscussionComment — HTML-комментарий, который после публикации
+	// оставляется под постом в группе обсуждения канала; пустой — без него
+	DiscussionComment string `json:"discussion_comment,omitempty" db:"discussion_comment"`
 }
 
 type Post struct {
-	ID           int             `json:"id" db:"id"`
-	Content      string          `json:"content" db:"content"`
-	MediaType    string          `json:"media_type" db:"media_type"`
+	ID        int    `json:"id" db:"id"`
+	Content   string `json:"content" db:"content"`
+	MediaType string `json:"media_type" db:"media_type"`
+	// MediaPath — ключ файла в хранилище медиа
 	MediaPath    string          `json:"media_path" db:"media_path"`
 	Buttons      json.RawMessage `json:"buttons" db:"buttons"`
 	ScheduleTime *time.Time      `json:"schedule_time" db:"schedule_time"`
@@ -26,6 +56,33 @@ type Post struct {
 	CreatedBy    string          `json:"created_by" db:"created_by"`
 	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
 	SentAt       *time.Time      `json:"sent_at" db:"sent_at"`
+	DeletedAt    *time.Time      `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Variables — значения пользовательских переменных {{name}} в тексте
+	Variables map[string]string `json:"variables,omitempty" db:"variables"`
+	// Variants — другие варианты текста для части каналов
+	Variants []PostVariant `json:"variants,omitempty" db:"variants"`
+	// Options переопределяют настройки отправки каналов
+	Options SendOptions `json:"options" db:"send_options"`
+
+	// Ключи идемпотентности создания и немедленной отправки
+	IdempotencyKey     string `json:"idempotency_key,omitempty" db:"idempotency_key"`
+	SendIdempotencyKey string `json:"-" db:"send_idempotency_key"`
+}
+
+// PostRevision — снимок редактируемых полей поста. Number нумерует
+// ревизии одного поста начиная с 1.
+type PostRevision struct {
+	ID           int             `json:"id" db:"id"`
+	PostID       int             `json:"post_id" db:"post_id"`
+	Number       int             `json:"number" db:"number"`
+	Content      string          `json:"content" db:"content"`
+	MediaType    string          `json:"media_type" db:"media_type"`
+	MediaPath    string          `json:"media_path" db:"media_path"`
+	Buttons      json.RawMessage `json:"buttons" db:"buttons"`
+	ScheduleTime *time.Time      `json:"schedule_time" db:"schedule_time"`
+	Author       string          `json:"author" db:"author"`
+	Reason       string          `json:"reason" db:"reason"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
 }
 
 type PostChannel struct {
@@ -36,6 +93,113 @@ type PostChannel struct {
 	Status    string    `json:"status" db:"status"`
 	Error     string    `json:"error" db:"error"`
 	SentAt    time.Time `json:"sent_at" db:"sent_at"`
+	// Variant — имя отправленного варианта поста; пустое — основной текст
+	Variant string `json:"variant,omitempty" db:"variant"`
+	// MessageThreadID — тема форума, в которую ушло сообщение
+	MessageThreadID int `json:"message_thread_id,omitempty" db:"message_thread_id"`
+	// Комментарий в группе обсуждения: CommentStatus пустой, если
+	// комментария нет, иначе CommentPending, "sent" или "error"
+	CommentStatus    string `json:"comment_status,omitempty" db:"comment_status"`
+	CommentMessageID int    `json:"comment_message_id,omitempty" db:"comment_message_id"`
+	CommentError     string `json:"comment_error,omitempty" db:"comment_error"`
+	// RepostOf — исходная доставка, если сообщение повторено из другого
+	// канала способом RepostMode
+	RepostOf   int    `json:"repost_of,omitempty" db:"repost_of"`
+	RepostMode string `json:"repost_mode,omitempty" db:"repost_mode"`
+}
+
+// CommentPending — комментарий ждёт, пока Telegram перешлёт пост в группу
+// обсуждения.
+const CommentPending = "pending"
+
+// Способы повтора сообщения: copyMessage публикует копию без указания
+// источника, forwardMessage — пересылку с ним.
+const (
+	RepostCopy    = "copy"
+	RepostForward = "forward"
+)
+
+// Repost — повтор доставленного сообщения в другом канале, сразу или по
+// расписанию. Status — "scheduled", "sending", "sent" или "error"; новое
+// сообщение записывается доставкой с RepostOf = PostChannelID.
+type Repost struct {
+	ID            int        `json:"id" db:"id"`
+	PostChannelID int        `json:"post_channel_id" db:"post_channel_id"`
+	ChannelID     int        `json:"channel_id" db:"channel_id"`
+	Mode          string     `json:"mode" db:"mode"`
+	ScheduleTime  *time.Time `json:"schedule_time" db:"schedule_time"`
+	Status        string     `json:"status" db:"status"`
+	CreatedBy     string     `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ForumTopic — тема форума супергруппы, замеченная в обновлениях бота.
+type ForumTopic struct {
+	ChatID    int64     `json:"chat_id" db:"chat_id"`
+	ThreadID  int       `json:"thread_id" db:"thread_id"`
+	Name      string    `json:"name" db:"name"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SendOptions — настройки отправки поста. Незаданные (nil) берутся из
+// настроек канала.
+type SendOptions struct {
+	DisableNotification *bool `json:"disable_notification,omitempty"`
+	DisableLinkPreview  *bool `json:"disable_link_preview,omitempty"`
+	// ProtectContent запрещает пересылку и сохранение сообщения
+	ProtectContent *bool `json:"protect_content,omitempty"`
+	// LinkPreviewURL — ссылка для превью вместо первой ссылки в тексте
+	LinkPreviewURL   string `json:"link_preview_url,omitempty"`
+	LinkPreviewAbove *bool  `json:"link_preview_above,omitempty"`
+	LinkPreviewSize  string `json:"link_preview_size,omitempty"`
+	// NoFooter и NoChannelButtons отключают подпись и кнопки канала
+	NoFooter         bool `json:"no_footer,omitempty"`
+	NoChannelButtons bool `json:"no_channel_buttons,omitempty"`
+
+	// MessageThreadID — тема форума канала; задаётся при отправке из
+	// настроек канала и не сохраняется в посте
+	MessageThreadID int `json:"-"`
+}
+
+// Размеры медиа в превью ссылки; пустой — на усмотрение Telegram
+const (
+	LinkPreviewSmall = "small"
+	LinkPreviewLarge = "large"
+)
+
+// Validate проверяет размер превью и адрес ссылки для превью.
+func (o SendOptions) Validate() error {
+	switch o.LinkPreviewSize {
+	case "", LinkPreviewSmall, LinkPreviewLarge:
+	default:
+		return fmt.Errorf("unknown link preview size %q", o.LinkPreviewSize)
+	}
+	if o.LinkPreviewURL != "" {
+		u, err := url.Parse(o.LinkPreviewURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("link preview URL must be an http(s) link")
+		}
+	}
+	return nil
+}
+
+// PostVariant — вариант текста и кнопок поста. Вариант с ChannelIDs
+// отправляется в эти каналы; варианты без каналов участвуют в A/B-тесте и
+// вместе с основным текстом случайно делят остальные каналы.
+type PostVariant struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	// Buttons заменяют кнопки поста; пусто — кнопки поста
+	Buttons    json.RawMessage `json:"buttons,omitempty"`
+	ChannelIDs []int           `json:"channel_ids,omitempty"`
+}
+
+// VariantStatistics — доставки одного варианта поста.
+type VariantStatistics struct {
+	Variant    string `json:"variant"`
+	Channels   int    `json:"channels"`
+	Successful int    `json:"successful"`
+	Failed     int    `json:"failed"`
 }
 
 type Button struct {
@@ -51,3 +215,165 @@ type Statistics struct {
 	TotalChannels  int `json:"total_channels"`
 	ActiveChannels int `json:"active_channels"`
 }
+
+// Tag — рубрика поста (news, promo, digest) с правилами отправки.
+type Tag struct {
+	ID   int    `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	// Hashtag добавляет #name в конец текста поста при отправке
+	Hashtag bool `json:"hashtag" db:"hashtag"`
+	// ChannelIDs — каналы по умолчанию для постов с тегом; пусто — все активные
+	ChannelIDs []int     `json:"channel_ids" db:"channel_ids"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// TagStatistics — посты и доставки постов с тегом. Посты, удалённые из
+// корзины окончательно, в ней не учитываются.
+type TagStatistics struct {
+	TagID      int    `json:"tag_id"`
+	Name       string `json:"name"`
+	Posts      int    `json:"posts"`
+	Successful int    `json:"successful"`
+	Failed     int    `json:"failed"`
+}
+
+// PostTemplate — заготовка поста для повторяющихся рубрик. Текст и кнопки
+// могут содержать переменные, Variables задаёт значения пользовательских
+// переменных по умолчанию.
+type PostTemplate struct {
+	ID        int               `json:"id" db:"id"`
+	Name      string            `json:"name" db:"name"`
+	Content   string            `json:"content" db:"content"`
+	MediaType string            `json:"media_type" db:"media_type"`
+	MediaPath string            `json:"media_path" db:"media_path"`
+	Buttons   json.RawMessage   `json:"buttons" db:"buttons"`
+	Variables map[string]string `json:"variables,omitempty" db:"variables"`
+	CreatedBy string            `json:"created_by" db:"created_by"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// Media — файл медиатеки. Один файл хранится один раз, повторная загрузка
+// того же содержимого возвращает существующую запись.
+type Media struct {
+	ID   int    `json:"id" db:"id"`
+	Hash string `json:"hash" db:"hash"`
+	// Path — ключ файла в хранилище медиа, как и Post.MediaPath
+	Path     string `json:"path" db:"path"`
+	Filename string `json:"filename" db:"filename"`
+	MimeType string `json:"mime_type" db:"mime_type"`
+	Size     int64  `json:"size" db:"size"`
+	// Размеры изображения или видео и длительность видео в секундах;
+	// ноль, если неизвестны
+	Width      int       `json:"width,omitempty" db:"width"`
+	Height     int       `json:"height,omitempty" db:"height"`
+	Duration   int       `json:"duration,omitempty" db:"duration"`
+	UploadedBy string    `json:"uploaded_by" db:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// MediaFilter отбирает файлы медиатеки.
+type MediaFilter struct {
+	// Query ищет подстроку в имени файла
+	Query string
+	// MimePrefix оставляет файлы с MIME-типом, начинающимся с префикса ("image/")
+	MimePrefix string
+	Limit      int
+	Offset     int
+}
+
+// Поля сортировки списка постов.
+const (
+	PostSortCreated   = "created_at"
+	PostSortScheduled = "schedule_time"
+	PostSortSent      = "sent_at"
+)
+
+// PostFilter отбирает посты для списка. Пустые поля не ограничивают выборку.
+type PostFilter struct {
+	Status    string
+	Author    string
+	MediaType string
+	// ChannelID оставляет посты, доставленные (в том числе с ошибкой) в канал
+	ChannelID int
+	TagID     int
+	// Query — поиск по тексту поста: все слова должны встречаться в content
+	Query string
+	// From и To ограничивают поле сортировки: From включительно, To — нет
+	From *time.Time
+	To   *time.Time
+
+	// Sort — одно из PostSort*, по умолчанию PostSortCreated. При сортировке
+	// по времени публикации или отправки посты без этого времени не выводятся.
+	Sort string
+	Asc  bool
+	// After — курсор: выдача продолжается после этой позиции. Если задан,
+	// Offset не используется.
+	After *PostCursor
+
+	Limit  int
+	Offset int
+}
+
+// PostCursor — позиция в списке постов: значение поля сортировки и ID.
+// Так же задаётся позиция в журнале действий.
+type PostCursor struct {
+	Time time.Time
+	ID   int
+}
+
+type DeliveryFilter struct {
+	PostID    int
+	ChannelID int
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+type APIToken struct {
+	ID         int        `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	CreatedBy  string     `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+}
+
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditEntry — запись журнала действий. Before и After — JSON-снимки
+// объекта до и после действия, если они применимы.
+type AuditEntry struct {
+	ID         int             `json:"id" db:"id"`
+	Actor      string          `json:"actor" db:"actor"`
+	Action     string          `json:"action" db:"action"`
+	TargetType string          `json:"target_type" db:"target_type"`
+	TargetID   int             `json:"target_id" db:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty" db:"before"`
+	After      json.RawMessage `json:"after,omitempty" db:"after"`
+	IP         string          `json:"ip" db:"ip"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   int
+	From       *time.Time
+	To         *time.Time
+	// After — курсор (created_at, id): выдача продолжается после этой
+	// записи. Если задан, Offset не используется.
+	After  *PostCursor
+	Limit  int
+	Offset int
+}
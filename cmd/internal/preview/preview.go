@@ -0,0 +1,142 @@
+// Package preview показывает пост в админ-панели так, как его увидят
+// подписчики: текст с разметкой Telegram, медиа и кнопки.
+package preview
+
+import (
+	"encoding/json"
+	"html"
+	"html/template"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+// Post — пост, подготовленный для шаблона "post_preview".
+type Post struct {
+	Content   template.HTML
+	MediaType string
+	// MediaURL — адрес файла, ThumbnailURL — его миниатюры; у документа
+	// вместо превью показывается Filename
+	MediaURL     string
+	ThumbnailURL string
+	Filename     string
+	Buttons      []models.Button
+}
+
+// New готовит пост к показу. Адреса файла и миниатюры задаёт вызывающий,
+// так как они зависят от маршрутов админ-панели.
+func New(post models.Post) Post {
+	p := Post{
+		Content:   HTML(post.Content),
+		MediaType: post.MediaType,
+	}
+	if len(post.Buttons) > 0 {
+		json.Unmarshal(post.Buttons, &p.Buttons)
+	}
+	return p
+}
+
+// Теги, которые Telegram принимает в parse_mode HTML. Значение — атрибуты,
+// которые сохраняются.
+var allowedTags = map[string][]string{
+	"b": nil, "strong": nil,
+	"i": nil, "em": nil,
+	"u": nil, "ins": nil,
+	"s": nil, "strike": nil, "del": nil,
+	"span":       {"class"},
+	"tg-spoiler": nil,
+	"a":          {"href"},
+	"code":       {"class"},
+	"pre":        nil,
+	"blockquote": {"expandable"},
+	"tg-emoji":   nil,
+}
+
+// HTML превращает текст поста с разметкой Telegram в безопасный HTML.
+// Неизвестные Telegram теги показываются как текст, переводы строк — как
+// <br>.
+func HTML(content string) template.HTML {
+	var b strings.Builder
+	z := nethtml.NewTokenizer(strings.NewReader(content))
+	// Открытые теги: закрывающий тег без пары выводится как текст
+	var open []string
+
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		token := z.Token()
+
+		switch tt {
+		case nethtml.TextToken:
+			b.WriteString(strings.ReplaceAll(html.EscapeString(token.Data), "\n", "<br>"))
+		case nethtml.StartTagToken:
+			attrs, ok := allowedTags[token.Data]
+			if class, _ := attr(token, "class"); !ok || (token.Data == "span" && class != "tg-spoiler") {
+				b.WriteString(html.EscapeString(string(z.Raw())))
+				continue
+			}
+			open = append(open, token.Data)
+			writeTag(&b, token, attrs)
+		case nethtml.EndTagToken:
+			if len(open) == 0 || open[len(open)-1] != token.Data {
+				b.WriteString(html.EscapeString(string(z.Raw())))
+				continue
+			}
+			open = open[:len(open)-1]
+			b.WriteString("</" + token.Data + ">")
+		default:
+			b.WriteString(html.EscapeString(string(z.Raw())))
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return template.HTML(b.String())
+}
+
+// writeTag выводит открывающий тег только с разрешёнными атрибутами.
+// Ссылки допускают лишь схемы, которые принимает Telegram.
+func writeTag(b *strings.Builder, token nethtml.Token, attrs []string) {
+	b.WriteString("<" + token.Data)
+	for _, name := range attrs {
+		value, ok := attr(token, name)
+		switch {
+		case !ok:
+			continue
+		case name == "expandable":
+			b.WriteString(" data-expandable")
+			continue
+		case name == "href" && !safeURL(value):
+			continue
+		}
+		b.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	if token.Data == "a" {
+		b.WriteString(` target="_blank" rel="noopener noreferrer"`)
+	}
+	b.WriteString(">")
+}
+
+func attr(token nethtml.Token, name string) (string, bool) {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func safeURL(value string) bool {
+	lower := strings.ToLower(strings.TrimSpace(value))
+	for _, scheme := range []string{"http://", "https://", "tg://", "mailto:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package preview
+
+import (
+	"testing"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+func TestHTML(t *testing.T) {
+	tests := map[string]string{
+		"plain\ntext":                                   "plain<br>text",
+		"<b>bold</b> & <i>italic</i>":                   "<b>bold</b> &amp; <i>italic</i>",
+		`<a href="https://example.com">link</a>`:        `<a href="https://example.com" target="_blank" rel="noopener noreferrer">link</a>`,
+		`<a href="javascript:alert(1)">x</a>`:           `<a target="_blank" rel="noopener noreferrer">x</a>`,
+		`<script>alert(1)</script>`:                     "&lt;script&gt;alert(1)&lt;/script&gt;",
+		`<b onclick="x()">bold</b>`:                     "<b>bold</b>",
+		`<span class="tg-spoiler">s</span>`:             `<span class="tg-spoiler">s</span>`,
+		`<span class="red">s</span>`:                    `&lt;span class=&#34;red&#34;&gt;s&lt;/span&gt;`,
+		`<blockquote expandable>q</blockquote>`:         "<blockquote data-expandable>q</blockquote>",
+		"<b>unclosed":                                   "<b>unclosed</b>",
+		"stray</i>":                                     "stray&lt;/i&gt;",
+		`<pre><code class="language-go">x</code></pre>`: `<pre><code class="language-go">x</code></pre>`,
+	}
+	for content, want := range tests {
+		if got := string(HTML(content)); got != want {
+			t.Errorf("HTML(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	p := New(models.Post{
+		Content:   "<b>hi</b>",
+		MediaType: "photo",
+		Buttons:   []byte(`[{"text":"Open","url":"https://example.com"}]`),
+	})
+	if p.Content != "<b>hi</b>" || p.MediaType != "photo" {
+		t.Errorf("New = %+v", p)
+	}
+	if len(p.Buttons) != 1 || p.Buttons[0].Text != "Open" {
+		t.Errorf("Buttons = %+v", p.Buttons)
+	}
+}
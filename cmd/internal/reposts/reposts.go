@@ -0,0 +1,133 @@
+// Package reposts повторяет доставленное сообщение в других каналах:
+// copyMessage публикует копию без указания источника, forwardMessage —
+// пересылку с ним. Повтор отправляется сразу или по расписанию, новое
+// сообщение записывается доставкой того же поста.
+package reposts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/delivery"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// Validate проверяет, что доставку source можно повторить способом mode в
+// каналах channelIDs.
+func Validate(source models.PostChannel, channelIDs []int, mode string) error {
+	if mode != models.RepostCopy && mode != models.RepostForward {
+		return fmt.Errorf("unknown repost mode %q", mode)
+	}
+	if source.Status != "sent" || source.MessageID == 0 {
+		return errors.New("only delivered messages can be reposted")
+	}
+	if len(channelIDs) == 0 {
+		return errors.New("select at least one channel")
+	}
+	if slices.Contains(channelIDs, source.ChannelID) {
+		return errors.New("the message is already in the source channel")
+	}
+	return nil
+}
+
+// Create проверяет каналы и записывает повторы доставки source. С
+// scheduleTime повторы ждут планировщика, без него создаются в статусе
+// "sending", и вызывающий отправляет их через Publish.
+func Create(ctx context.Context, store storage.Storage, source models.PostChannel, channelIDs []int, mode string, scheduleTime *time.Time, createdBy string) ([]models.Repost, error) {
+	if err := Validate(source, channelIDs, mode); err != nil {
+		return nil, err
+	}
+	for _, id := range channelIDs {
+		if _, err := store.GetChannel(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	status := "sending"
+	if scheduleTime != nil {
+		status = "scheduled"
+	}
+	var reposts []models.Repost
+	for _, id := range channelIDs {
+		repost := models.Repost{
+			PostChannelID: source.ID,
+			ChannelID:     id,
+			Mode:          mode,
+			ScheduleTime:  scheduleTime,
+			Status:        status,
+			CreatedBy:     createdBy,
+		}
+		if err := store.CreateRepost(ctx, &repost); err != nil {
+			return reposts, err
+		}
+		reposts = append(reposts, repost)
+	}
+	return reposts, nil
+}
+
+// Publish отправляет повтор и записывает новое сообщение доставкой поста,
+// даже если Telegram ответил ошибкой. Статус повтора становится "sent" или
+// "error". Ошибка возвращается, только если повтор не удалось подготовить.
+func Publish(ctx context.Context, store storage.Storage, sender delivery.Sender, repost models.Repost) (*models.PostChannel, error) {
+	source, target, from, err := load(ctx, store, repost)
+	if err != nil {
+		if err := store.UpdateRepostStatus(ctx, repost.ID, "error"); err != nil {
+			log.Printf("Error updating repost %d: %v", repost.ID, err)
+		}
+		return nil, err
+	}
+
+	messageID, err := sender.Repost(ctx, *from, source.MessageID, *target, repost.Mode)
+	status := "sent"
+	errorMsg := ""
+	if err != nil {
+		status = "error"
+		errorMsg = err.Error()
+		log.Printf("Error reposting to channel %s: %v", target.Username, err)
+	}
+
+	postChannel := models.PostChannel{
+		PostID:          source.PostID,
+		ChannelID:       target.ID,
+		MessageID:       messageID,
+		Status:          status,
+		Error:           errorMsg,
+		SentAt:          time.Now(),
+		Variant:         source.Variant,
+		MessageThreadID: target.MessageThreadID,
+		RepostOf:        source.ID,
+		RepostMode:      repost.Mode,
+	}
+	if err == nil && target.DiscussionComment != "" {
+		postChannel.CommentStatus = models.CommentPending
+	}
+	if err := store.CreatePostChannel(ctx, &postChannel); err != nil {
+		log.Printf("Error saving post channel: %v", err)
+	}
+	if err := store.UpdateRepostStatus(ctx, repost.ID, status); err != nil {
+		log.Printf("Error updating repost %d: %v", repost.ID, err)
+	}
+	return &postChannel, nil
+}
+
+// load возвращает исходную доставку, канал назначения и исходный канал.
+func load(ctx context.Context, store storage.Storage, repost models.Repost) (*models.PostChannel, *models.Channel, *models.Channel, error) {
+	source, err := store.GetPostChannel(ctx, repost.PostChannelID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	target, err := store.GetChannel(ctx, repost.ChannelID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	from, err := store.GetChannel(ctx, source.ChannelID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return source, target, from, nil
+}
@@ -0,0 +1,184 @@
+package reposts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/delivery"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+	"github.com/maksekak/channelBot/cmd/internal/telegram"
+)
+
+// fakeTelegram отвечает на copyMessage и forwardMessage как Bot API и
+// запоминает методы и запросы.
+type fakeTelegram struct {
+	mu       sync.Mutex
+	methods  []string
+	payloads []map[string]interface{}
+	fail     map[string]bool
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&payload)
+	chatID, _ := payload["chat_id"].(string)
+
+	f.mu.Lock()
+	f.methods = append(f.methods, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+	f.payloads = append(f.payloads, payload)
+	f.mu.Unlock()
+
+	if f.fail[chatID] {
+		w.Write([]byte(`{"ok":false,"description":"Bad Request: chat not found"}`))
+		return
+	}
+	w.Write([]byte(`{"ok":true,"result":{"message_id":77}}`))
+}
+
+func newTestSender(t *testing.T, fake *fakeTelegram) delivery.Sender {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := telegram.NewClient("test")
+	client.APIURL = server.URL
+	return delivery.Sender{Telegram: client}
+}
+
+func createChannels(t *testing.T, store storage.Storage, channels ...*models.Channel) {
+	t.Helper()
+	for _, channel := range channels {
+		if err := store.CreateChannel(context.Background(), channel); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	source := models.PostChannel{ID: 1, ChannelID: 1, MessageID: 5, Status: "sent"}
+	for _, tc := range []struct {
+		name     string
+		source   models.PostChannel
+		channels []int
+		mode     string
+		ok       bool
+	}{
+		{"copy", source, []int{2}, models.RepostCopy, true},
+		{"forward", source, []int{2, 3}, models.RepostForward, true},
+		{"unknown mode", source, []int{2}, "quote", false},
+		{"no channels", source, nil, models.RepostCopy, false},
+		{"source channel", source, []int{2, 1}, models.RepostCopy, false},
+		{"failed delivery", models.PostChannel{ID: 1, ChannelID: 1, Status: "error"}, []int{2}, models.RepostCopy, false},
+	} {
+		err := Validate(tc.source, tc.channels, tc.mode)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: Validate = %v, want ok %v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestCreateAndPublish(t *testing.T) {
+	fake := &fakeTelegram{fail: map[string]bool{"-1003": true}}
+	sender := newTestSender(t, fake)
+	store := storage.NewMemoryStorage()
+	ctx := context.Background()
+
+	source := models.Channel{TelegramID: -1001, Title: "source", IsActive: true}
+	target := models.Channel{TelegramID: -1002, Title: "target", IsActive: true, DisableNotification: true,
+		MessageThreadID: 4, DiscussionComment: "Discuss"}
+	broken := models.Channel{TelegramID: -1003, Title: "broken", IsActive: true}
+	createChannels(t, store, &source, &target, &broken)
+
+	post := models.Post{Content: "hello", MediaType: "text", Status: "sent"}
+	store.CreatePost(ctx, &post)
+	delivered := models.PostChannel{PostID: post.ID, ChannelID: source.ID, MessageID: 5, Status: "sent", Variant: "B", SentAt: time.Now()}
+	store.CreatePostChannel(ctx, &delivered)
+
+	if _, err := Create(ctx, store, delivered, []int{target.ID, 999}, models.RepostCopy, nil, "admin"); err == nil {
+		t.Error("Create accepted an unknown channel")
+	}
+
+	reposts, err := Create(ctx, store, delivered, []int{target.ID, broken.ID}, models.RepostForward, nil, "admin")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(reposts) != 2 || reposts[0].Status != "sending" || reposts[0].CreatedBy != "admin" {
+		t.Fatalf("reposts = %+v", reposts)
+	}
+
+	for _, repost := range reposts {
+		if _, err := Publish(ctx, store, sender, repost); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	if len(fake.methods) != 2 || fake.methods[0] != "forwardMessage" {
+		t.Fatalf("methods = %v, want forwardMessage", fake.methods)
+	}
+	payload := fake.payloads[0]
+	if payload["chat_id"] != "-1002" || payload["from_chat_id"] != "-1001" || payload["message_id"] != float64(5) ||
+		payload["message_thread_id"] != float64(4) || payload["disable_notification"] != true {
+		t.Errorf("payload = %v", payload)
+	}
+
+	deliveries, _ := store.GetPostChannels(ctx, models.DeliveryFilter{PostID: post.ID, Limit: 10})
+	byChannel := map[int]models.PostChannel{}
+	for _, pc := range deliveries {
+		byChannel[pc.ChannelID] = pc
+	}
+	got := byChannel[target.ID]
+	if got.Status != "sent" || got.MessageID != 77 || got.RepostOf != delivered.ID || got.RepostMode != models.RepostForward ||
+		got.Variant != "B" || got.MessageThreadID != 4 || got.CommentStatus != models.CommentPending {
+		t.Errorf("repost delivery = %+v", got)
+	}
+	if got := byChannel[broken.ID]; got.Status != "error" || got.Error == "" || got.RepostOf != delivered.ID {
+		t.Errorf("failed repost delivery = %+v", got)
+	}
+
+	list, _ := store.GetPostReposts(ctx, post.ID)
+	statuses := map[int]string{}
+	for _, repost := range list {
+		statuses[repost.ChannelID] = repost.Status
+	}
+	if statuses[target.ID] != "sent" || statuses[broken.ID] != "error" {
+		t.Errorf("repost statuses = %v", statuses)
+	}
+}
+
+func TestPublishCopy(t *testing.T) {
+	fake := &fakeTelegram{}
+	sender := newTestSender(t, fake)
+	store := storage.NewMemoryStorage()
+	ctx := context.Background()
+
+	source := models.Channel{TelegramID: -1001, Title: "source", IsActive: true}
+	target := models.Channel{TelegramID: -1002, Title: "target", IsActive: true, Footer: "Footer"}
+	createChannels(t, store, &source, &target)
+	delivered := models.PostChannel{PostID: 1, ChannelID: source.ID, MessageID: 5, Status: "sent"}
+	store.CreatePostChannel(ctx, &delivered)
+
+	when := time.Now().Add(time.Hour)
+	reposts, err := Create(ctx, store, delivered, []int{target.ID}, models.RepostCopy, &when, "")
+	if err != nil || len(reposts) != 1 || reposts[0].Status != "scheduled" {
+		t.Fatalf("Create = %+v, %v", reposts, err)
+	}
+
+	pc, err := Publish(ctx, store, sender, reposts[0])
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if fake.methods[0] != "copyMessage" || pc.RepostMode != models.RepostCopy || pc.CommentStatus != "" {
+		t.Errorf("method %s, delivery %+v", fake.methods[0], pc)
+	}
+	if _, ok := fake.payloads[0]["message_thread_id"]; ok {
+		t.Error("message_thread_id is sent for a channel without a topic")
+	}
+}
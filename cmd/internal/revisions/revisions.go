@@ -0,0 +1,203 @@
+// Package revisions ведёт историю изменений постов: снимки содержимого,
+// сравнение двух ревизий и восстановление поста из ревизии.
+package revisions
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// Snapshot снимает ревизию с редактируемых полей поста.
+func Snapshot(post *models.Post, author, reason string) models.PostRevision {
+	return models.PostRevision{
+		PostID:       post.ID,
+		Content:      post.Content,
+		MediaType:    post.MediaType,
+		MediaPath:    post.MediaPath,
+		Buttons:      post.Buttons,
+		ScheduleTime: post.ScheduleTime,
+		Author:       author,
+		Reason:       reason,
+	}
+}
+
+// Same сообщает, совпадает ли содержимое двух ревизий.
+func Same(a, b models.PostRevision) bool {
+	return a.Content == b.Content &&
+		a.MediaType == b.MediaType &&
+		a.MediaPath == b.MediaPath &&
+		bytes.Equal(normalizeButtons(a.Buttons), normalizeButtons(b.Buttons)) &&
+		sameTime(a.ScheduleTime, b.ScheduleTime)
+}
+
+// Record сохраняет текущее состояние поста новой ревизией. Если содержимое
+// не изменилось с последней ревизии, ничего не записывается и возвращается nil.
+func Record(ctx context.Context, s storage.Storage, post *models.Post, author, reason string) (*models.PostRevision, error) {
+	rev := Snapshot(post, author, reason)
+
+	existing, err := s.GetPostRevisions(ctx, post.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 && Same(existing[0], rev) {
+		return nil, nil
+	}
+
+	if err := s.CreatePostRevision(ctx, &rev); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// Apply переносит содержимое ревизии в пост. Время публикации меняется
+// только у ещё не опубликованного поста, статус draft/scheduled
+// приводится в соответствие с ним.
+func Apply(post *models.Post, rev *models.PostRevision) {
+	post.Content = rev.Content
+	post.MediaType = rev.MediaType
+	post.MediaPath = rev.MediaPath
+	post.Buttons = rev.Buttons
+
+	if post.Status != "draft" && post.Status != "scheduled" {
+		return
+	}
+
+	post.ScheduleTime = rev.ScheduleTime
+	if post.ScheduleTime != nil {
+		post.Status = "scheduled"
+	} else {
+		post.Status = "draft"
+	}
+}
+
+// normalizeButtons приводит пустые значения кнопок к одному виду:
+// nil, "null" и "[]" означают отсутствие кнопок.
+func normalizeButtons(raw []byte) []byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" || string(raw) == "[]" {
+		return nil
+	}
+	return raw
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// Op — вид строки в построчном сравнении.
+type Op string
+
+const (
+	OpEqual  Op = " "
+	OpInsert Op = "+"
+	OpDelete Op = "-"
+)
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Change — изменённое поле. Для многострочного текста Lines содержит
+// построчное сравнение, для остальных полей — только Old и New.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+	Lines []Line
+}
+
+// Diff возвращает поля, отличающиеся в ревизиях from и to.
+func Diff(from, to models.PostRevision) []Change {
+	var changes []Change
+
+	if from.Content != to.Content {
+		changes = append(changes, Change{
+			Field: "content",
+			Old:   from.Content,
+			New:   to.Content,
+			Lines: DiffLines(from.Content, to.Content),
+		})
+	}
+	if from.MediaType != to.MediaType {
+		changes = append(changes, Change{Field: "media_type", Old: from.MediaType, New: to.MediaType})
+	}
+	if from.MediaPath != to.MediaPath {
+		changes = append(changes, Change{Field: "media_path", Old: from.MediaPath, New: to.MediaPath})
+	}
+	if before, after := normalizeButtons(from.Buttons), normalizeButtons(to.Buttons); !bytes.Equal(before, after) {
+		changes = append(changes, Change{Field: "buttons", Old: string(before), New: string(after)})
+	}
+	if !sameTime(from.ScheduleTime, to.ScheduleTime) {
+		changes = append(changes, Change{
+			Field: "schedule_time",
+			Old:   formatTime(from.ScheduleTime),
+			New:   formatTime(to.ScheduleTime),
+		})
+	}
+
+	return changes
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("02.01.2006 15:04")
+}
+
+// DiffLines сравнивает тексты построчно по наибольшей общей
+// подпоследовательности. Посты короткие, поэтому квадратичной памяти
+// достаточно.
+func DiffLines(a, b string) []Line {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+
+	// lcs[i][j] — длина НОП суффиксов x[i:] и y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []Line
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, Line{OpEqual, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{OpDelete, x[i]})
+			i++
+		default:
+			lines = append(lines, Line{OpInsert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, Line{OpDelete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, Line{OpInsert, y[j]})
+	}
+
+	return lines
+}
@@ -0,0 +1,84 @@
+package revisions
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+func TestDiffLines(t *testing.T) {
+	got := DiffLines("a\nb\nc", "a\nx\nc\nd")
+	want := []Line{
+		{OpEqual, "a"},
+		{OpDelete, "b"},
+		{OpInsert, "x"},
+		{OpEqual, "c"},
+		{OpInsert, "d"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffLines = %v, want %v", got, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	when := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	from := models.PostRevision{Content: "hello", MediaType: "text", Buttons: json.RawMessage("null")}
+	to := models.PostRevision{Content: "hello", MediaType: "text", Buttons: json.RawMessage("[]"), ScheduleTime: &when}
+
+	changes := Diff(from, to)
+	if len(changes) != 1 || changes[0].Field != "schedule_time" || changes[0].New != "01.03.2026 10:00" {
+		t.Errorf("Diff = %+v, want only schedule_time", changes)
+	}
+
+	if len(Diff(from, from)) != 0 {
+		t.Error("Diff of equal revisions is not empty")
+	}
+}
+
+func TestRecordSkipsUnchanged(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStorage()
+
+	post := models.Post{Content: "v1", MediaType: "text", Status: "draft", CreatedBy: "admin"}
+	if err := s.CreatePost(ctx, &post); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := Record(ctx, s, &post, "admin", "created")
+	if err != nil || first == nil || first.Number != 1 {
+		t.Fatalf("Record = %+v, %v", first, err)
+	}
+
+	again, err := Record(ctx, s, &post, "admin", "no-op")
+	if err != nil || again != nil {
+		t.Fatalf("Record without changes = %+v, %v, want nil", again, err)
+	}
+
+	post.Content = "v2"
+	second, err := Record(ctx, s, &post, "editor", "fix")
+	if err != nil || second == nil || second.Number != 2 {
+		t.Fatalf("Record after change = %+v, %v", second, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	when := time.Now().Add(time.Hour)
+	rev := &models.PostRevision{Content: "old", MediaType: "text", ScheduleTime: &when}
+
+	draft := models.Post{Content: "new", MediaType: "text", Status: "draft"}
+	Apply(&draft, rev)
+	if draft.Content != "old" || draft.Status != "scheduled" || draft.ScheduleTime != &when {
+		t.Errorf("Apply to draft = %+v", draft)
+	}
+
+	sent := models.Post{Content: "new", MediaType: "text", Status: "sent"}
+	Apply(&sent, rev)
+	if sent.Content != "old" || sent.Status != "sent" || sent.ScheduleTime != nil {
+		t.Errorf("Apply to sent post = %+v", sent)
+	}
+}
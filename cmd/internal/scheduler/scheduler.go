@@ -1,25 +1,41 @@
 package scheduler
 
 import (
+	"context"
 	"log"
 	"time"
 
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/delivery"
+	"github.com/maksekak/channelBot/cmd/internal/media"
 	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/reposts"
 	"github.com/maksekak/channelBot/cmd/internal/storage"
+	"github.com/maksekak/channelBot/cmd/internal/tags"
 	"github.com/maksekak/channelBot/cmd/internal/telegram"
+	"github.com/maksekak/channelBot/cmd/internal/variants"
 	"github.com/robfig/cron/v3"
 )
 
 type Scheduler struct {
 	storage  storage.Storage
 	telegram *telegram.Client
+	audit    *audit.Logger
 	cron     *cron.Cron
+
+	// TrashRetention — срок хранения удалённых постов и каналов в корзине.
+	// Ноль отключает автоматическую очистку.
+	TrashRetention time.Duration
+	// Media, если задана, раз в сутки очищается от неиспользуемых файлов
+	// и накладывает на фото водяные знаки каналов.
+	Media *media.Library
 }
 
 func NewScheduler(storage storage.Storage, telegram *telegram.Client) *Scheduler {
 	return &Scheduler{
 		storage:  storage,
 		telegram: telegram,
+		audit:    audit.New(storage),
 		cron:     cron.New(),
 	}
 }
@@ -27,39 +43,197 @@ func NewScheduler(storage storage.Storage, telegram *telegram.Client) *Scheduler
 func (s *Scheduler) Start() {
 	// Проверка запланированных постов каждую минуту
 	s.cron.AddFunc("* * * * *", s.processScheduledPosts)
+	s.cron.AddFunc("* * * * *", s.processScheduledReposts)
+	if s.TrashRetention > 0 {
+		// Очистка корзины раз в сутки
+		s.cron.AddFunc("@daily", s.purgeTrash)
+	}
+	if s.Media != nil {
+		s.cron.AddFunc("@daily", s.collectMedia)
+	}
 	s.cron.Start()
 	log.Println("Scheduler started")
 }
 
 func (s *Scheduler) processScheduledPosts() {
+	ctx := context.Background()
 	now := time.Now()
-	posts, err := s.storage.GetScheduledPosts(now)
+	posts, err := s.storage.GetScheduledPosts(ctx, now)
 	if err != nil {
 		log.Printf("Error getting scheduled posts: %v", err)
 		return
 	}
 
 	for _, post := range posts {
+		// Пост мог уже забрать другой экземпляр или отправка «сейчас»
+		claimed, err := s.storage.ClaimPostForSending(ctx, post.ID, "")
+		if err != nil {
+			log.Printf("Error claiming post %d: %v", post.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
 		log.Printf("Processing scheduled post ID: %d", post.ID)
-		s.sendPost(post)
+		s.Publish(ctx, post)
+	}
+}
+
+// processScheduledReposts отправляет повторы, время которых наступило.
+func (s *Scheduler) processScheduledReposts() {
+	ctx := context.Background()
+	due, err := s.storage.GetDueReposts(ctx, time.Now())
+	if err != nil {
+		log.Printf("Error getting scheduled reposts: %v", err)
+		return
+	}
+
+	var claimed []models.Repost
+	for _, repost := range due {
+		ok, err := s.storage.ClaimRepost(ctx, repost.ID)
+		if err != nil {
+			log.Printf("Error claiming repost %d: %v", repost.ID, err)
+			continue
+		}
+		if ok {
+			claimed = append(claimed, repost)
+		}
+	}
+	s.Repost(ctx, claimed)
+}
+
+// purgeTrash окончательно удаляет записи, пролежавшие в корзине дольше
+// TrashRetention. Доставки остаются в статистике.
+func (s *Scheduler) purgeTrash() {
+	ctx := context.Background()
+	before := time.Now().Add(-s.TrashRetention)
 
-		// Обновление статуса поста
-		post.Status = "sent"
-		now := time.Now()
-		post.SentAt = &now
-		s.storage.UpdatePost(&post)
+	n, err := s.storage.PurgeDeleted(ctx, before)
+	if err != nil {
+		log.Printf("Error purging trash: %v", err)
+		return
+	}
+	if n == 0 {
+		return
 	}
+
+	log.Printf("Purged %d items from trash", n)
+	s.audit.Record(ctx, audit.Event{
+		Actor:  audit.ActorScheduler,
+		Action: audit.ActionTrashPurge,
+		After:  map[string]interface{}{"purged": n, "deleted_before": before},
+	})
 }
 
-func (s *Scheduler) sendPost(post models.Post) {
-	channels, err := s.storage.GetActiveChannels()
+// collectMedia удаляет файлы медиатеки, на которые не ссылается ни один
+// пост или ревизия.
+func (s *Scheduler) collectMedia() {
+	ctx := context.Background()
+	before := time.Now().Add(-media.GracePeriod)
+
+	n, err := s.Media.GC(ctx, before)
 	if err != nil {
-		log.Printf("Error getting active channels: %v", err)
+		log.Printf("Error collecting unused media: %v", err)
+	}
+	if n == 0 {
 		return
 	}
 
+	log.Printf("Removed %d unused media files", n)
+	s.audit.Record(ctx, audit.Event{
+		Actor:      audit.ActorScheduler,
+		Action:     audit.ActionMediaCollect,
+		TargetType: audit.TargetMedia,
+		After:      map[string]interface{}{"removed": n, "uploaded_before": before},
+	})
+}
+
+// Publish отправляет пост во все активные каналы и помечает его
+// отправленным. Если каналы определить не удалось, пост никуда не уходит:
+// отметка отправки снимается, и пост возвращается к расписанию или в
+// черновики.
+func (s *Scheduler) Publish(ctx context.Context, post models.Post) error {
+	deliveries, err := s.sendPost(ctx, post)
+	if err != nil {
+		log.Printf("Error preparing post %d: %v", post.ID, err)
+		s.release(ctx, post)
+		return err
+	}
+
+	// Обновление статуса поста
+	post.Status = "sent"
+	now := time.Now()
+	post.SentAt = &now
+	if err := s.storage.UpdatePost(ctx, &post); err != nil {
+		log.Printf("Error updating post %d: %v", post.ID, err)
+	}
+
+	s.audit.Record(ctx, audit.Event{
+		Actor:      audit.ActorScheduler,
+		Action:     audit.ActionPostSent,
+		TargetType: audit.TargetPost,
+		TargetID:   post.ID,
+		After:      deliveries,
+	})
+	return nil
+}
+
+// release снимает с поста отметку отправки, чтобы его можно было
+// отправить снова.
+func (s *Scheduler) release(ctx context.Context, post models.Post) {
+	post.Status = "draft"
+	if post.ScheduleTime != nil {
+		post.Status = "scheduled"
+	}
+	if err := s.storage.UpdatePost(ctx, &post); err != nil {
+		log.Printf("Error releasing post %d: %v", post.ID, err)
+	}
+}
+
+// Repost отправляет повторы доставок и записывает в журнал новые
+// сообщения каждого поста.
+func (s *Scheduler) Repost(ctx context.Context, list []models.Repost) {
+	sender := delivery.Sender{Telegram: s.telegram, Media: s.Media}
+	deliveries := make(map[int][]models.PostChannel)
+	var postIDs []int
+	for _, repost := range list {
+		postChannel, err := reposts.Publish(ctx, s.storage, sender, repost)
+		if err != nil {
+			log.Printf("Error reposting %d: %v", repost.ID, err)
+			continue
+		}
+		if _, ok := deliveries[postChannel.PostID]; !ok {
+			postIDs = append(postIDs, postChannel.PostID)
+		}
+		deliveries[postChannel.PostID] = append(deliveries[postChannel.PostID], *postChannel)
+	}
+
+	for _, postID := range postIDs {
+		s.audit.Record(ctx, audit.Event{
+			Actor:      audit.ActorScheduler,
+			Action:     audit.ActionPostReposted,
+			TargetType: audit.TargetPost,
+			TargetID:   postID,
+			After:      deliveries[postID],
+		})
+	}
+}
+
+func (s *Scheduler) sendPost(ctx context.Context, post models.Post) ([]models.PostChannel, error) {
+	postTags, channels, err := tags.Prepare(ctx, s.storage, post)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []models.PostChannel
+	sender := delivery.Sender{Telegram: s.telegram, Media: s.Media}
+	assigned := variants.Assign(post.Variants, channels)
+
 	for _, channel := range channels {
-		messageID, err := s.telegram.SendMessage(channel.TelegramID, post)
+		variant := assigned[channel.ID]
+		message := tags.Decorate(variants.Apply(post, variant), postTags)
+		messageID, err := sender.Send(ctx, channel, message)
 		status := "sent"
 		errorMsg := ""
 		if err != nil {
@@ -69,15 +243,25 @@ func (s *Scheduler) sendPost(post models.Post) {
 		}
 
 		postChannel := models.PostChannel{
-			PostID:    post.ID,
-			ChannelID: channel.ID,
-			MessageID: messageID,
-			Status:    status,
-			Error:     errorMsg,
-			SentAt:    time.Now(),
+			PostID:          post.ID,
+			ChannelID:       channel.ID,
+			MessageID:       messageID,
+			Status:          status,
+			Error:           errorMsg,
+			SentAt:          time.Now(),
+			Variant:         variant,
+			MessageThreadID: channel.MessageThreadID,
+		}
+		// Комментарий оставляется, когда Telegram перешлёт пост в группу
+		// обсуждения канала
+		if err == nil && channel.DiscussionComment != "" {
+			postChannel.CommentStatus = models.CommentPending
 		}
-		if err := s.storage.CreatePostChannel(&postChannel); err != nil {
+		if err := s.storage.CreatePostChannel(ctx, &postChannel); err != nil {
 			log.Printf("Error saving post channel: %v", err)
 		}
+		deliveries = append(deliveries, postChannel)
 	}
+
+	return deliveries, nil
 }
@@ -0,0 +1,263 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+	"github.com/maksekak/channelBot/cmd/internal/telegram"
+)
+
+// fakeTelegram отвечает на sendMessage и copyMessage как Bot API и
+// запоминает chat_id.
+type fakeTelegram struct {
+	mu      sync.Mutex
+	chats   []string
+	texts   []string
+	threads []float64
+	fail    map[string]bool
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&payload)
+	chatID, _ := payload["chat_id"].(string)
+	text, _ := payload["text"].(string)
+	thread, _ := payload["message_thread_id"].(float64)
+
+	f.mu.Lock()
+	f.chats = append(f.chats, chatID)
+	f.texts = append(f.texts, text)
+	f.threads = append(f.threads, thread)
+	f.mu.Unlock()
+
+	if f.fail[chatID] {
+		w.Write([]byte(`{"ok":false,"description":"Bad Request: chat not found"}`))
+		return
+	}
+	w.Write([]byte(`{"ok":true,"result":{"message_id":7}}`))
+}
+
+func newTestScheduler(t *testing.T, fake *fakeTelegram) (*Scheduler, *storage.MemoryStorage) {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := telegram.NewClient("test")
+	client.APIURL = server.URL
+
+	store := storage.NewMemoryStorage()
+	return NewScheduler(store, client), store
+}
+
+func TestProcessScheduledPosts(t *testing.T) {
+	fake := &fakeTelegram{fail: map[string]bool{"-1002": true}}
+	s, store := newTestScheduler(t, fake)
+	ctx := context.Background()
+
+	for _, channel := range []models.Channel{
+		{TelegramID: -1001, Title: "ok", IsActive: true},
+		{TelegramID: -1002, Title: "broken", IsActive: true},
+		{TelegramID: -1003, Title: "inactive", IsActive: false},
+	} {
+		if err := store.CreateChannel(ctx, &channel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	due := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+	duePost := models.Post{Content: "due", MediaType: "text", Status: "scheduled", ScheduleTime: &due}
+	laterPost := models.Post{Content: "later", MediaType: "text", Status: "scheduled", ScheduleTime: &later}
+	store.CreatePost(ctx, &duePost)
+	store.CreatePost(ctx, &laterPost)
+
+	s.processScheduledPosts()
+
+	got, _ := store.GetPost(ctx, duePost.ID)
+	if got.Status != "sent" || got.SentAt == nil {
+		t.Errorf("due post = status %q sent_at %v, want sent", got.Status, got.SentAt)
+	}
+	got, _ = store.GetPost(ctx, laterPost.ID)
+	if got.Status != "scheduled" {
+		t.Errorf("later post status = %q, want scheduled", got.Status)
+	}
+
+	deliveries, _ := store.GetPostChannels(ctx, models.DeliveryFilter{PostID: duePost.ID, Limit: 10})
+	if len(deliveries) != 2 {
+		t.Fatalf("deliveries = %d, want 2 (inactive channel skipped)", len(deliveries))
+	}
+
+	stats, _ := store.GetStatistics(ctx, 1)
+	if stats.Successful != 1 || stats.Failed != 1 {
+		t.Errorf("statistics = %+v, want 1 sent and 1 failed", stats)
+	}
+
+	entries, _ := store.ListAuditEntries(ctx, models.AuditFilter{Action: audit.ActionPostSent, Limit: 10})
+	if len(entries) != 1 || entries[0].Actor != audit.ActorScheduler || entries[0].TargetID != duePost.ID {
+		t.Errorf("audit entries = %+v, want one post.sent by scheduler", entries)
+	}
+
+	// Повторный проход не отправляет пост второй раз
+	s.processScheduledPosts()
+	if len(fake.chats) != 2 {
+		t.Errorf("telegram calls = %d, want 2", len(fake.chats))
+	}
+}
+
+func TestPublishAppliesTagRules(t *testing.T) {
+	fake := &fakeTelegram{}
+	s, store := newTestScheduler(t, fake)
+	ctx := context.Background()
+
+	var channels []models.Channel
+	for _, telegramID := range []int64{-1001, -1002} {
+		channel := models.Channel{TelegramID: telegramID, Title: "c", IsActive: true}
+		if err := store.CreateChannel(ctx, &channel); err != nil {
+			t.Fatal(err)
+		}
+		channels = append(channels, channel)
+	}
+
+	tag := models.Tag{Name: "promo", Hashtag: true, ChannelIDs: []int{channels[1].ID}}
+	if err := store.CreateTag(ctx, &tag); err != nil {
+		t.Fatal(err)
+	}
+
+	post := models.Post{Content: "Sale", MediaType: "text", Status: "sending"}
+	store.CreatePost(ctx, &post)
+	store.SetPostTags(ctx, post.ID, []int{tag.ID})
+
+	s.Publish(ctx, post)
+
+	if len(fake.chats) != 1 || fake.chats[0] != "-1002" {
+		t.Fatalf("telegram chats = %v, want only the tag channel -1002", fake.chats)
+	}
+	if want := "Sale\n\n#promo"; fake.texts[0] != want {
+		t.Errorf("sent text = %q, want %q", fake.texts[0], want)
+	}
+
+	// Хэштеги не сохраняются в самом посте
+	got, _ := store.GetPost(ctx, post.ID)
+	if got.Content != "Sale" {
+		t.Errorf("stored content = %q, want unchanged", got.Content)
+	}
+}
+
+func TestPublishForumTopicAndComment(t *testing.T) {
+	fake := &fakeTelegram{}
+	s, store := newTestScheduler(t, fake)
+	ctx := context.Background()
+
+	channel := models.Channel{TelegramID: -1001, Title: "forum", IsActive: true, MessageThreadID: 42, DiscussionComment: "Discuss"}
+	if err := store.CreateChannel(ctx, &channel); err != nil {
+		t.Fatal(err)
+	}
+
+	post := models.Post{Content: "Hello", MediaType: "text", Status: "sending"}
+	store.CreatePost(ctx, &post)
+	s.Publish(ctx, post)
+
+	if len(fake.threads) != 1 || fake.threads[0] != 42 {
+		t.Errorf("message_thread_id = %v, want 42", fake.threads)
+	}
+	deliveries, _ := store.GetPostChannels(ctx, models.DeliveryFilter{PostID: post.ID, Limit: 10})
+	if len(deliveries) != 1 || deliveries[0].MessageThreadID != 42 || deliveries[0].CommentStatus != models.CommentPending {
+		t.Errorf("deliveries = %+v, want topic 42 and pending comment", deliveries)
+	}
+}
+
+func TestProcessScheduledReposts(t *testing.T) {
+	fake := &fakeTelegram{}
+	s, store := newTestScheduler(t, fake)
+	ctx := context.Background()
+
+	source := models.Channel{TelegramID: -1001, Title: "source", IsActive: true}
+	target := models.Channel{TelegramID: -1002, Title: "target", IsActive: true}
+	for _, channel := range []*models.Channel{&source, &target} {
+		if err := store.CreateChannel(ctx, channel); err != nil {
+			t.Fatal(err)
+		}
+	}
+	post := models.Post{Content: "Hello", MediaType: "text", Status: "sent"}
+	store.CreatePost(ctx, &post)
+	delivered := models.PostChannel{PostID: post.ID, ChannelID: source.ID, MessageID: 5, Status: "sent", SentAt: time.Now()}
+	store.CreatePostChannel(ctx, &delivered)
+
+	due := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+	for _, when := range []*time.Time{&due, &later} {
+		repost := models.Repost{PostChannelID: delivered.ID, ChannelID: target.ID, Mode: models.RepostCopy, ScheduleTime: when, Status: "scheduled"}
+		store.CreateRepost(ctx, &repost)
+	}
+
+	s.processScheduledReposts()
+	s.processScheduledReposts()
+
+	if len(fake.chats) != 1 || fake.chats[0] != "-1002" {
+		t.Fatalf("telegram chats = %v, want one copy to -1002", fake.chats)
+	}
+	deliveries, _ := store.GetPostChannels(ctx, models.DeliveryFilter{PostID: post.ID, ChannelID: target.ID, Limit: 10})
+	if len(deliveries) != 1 || deliveries[0].MessageID != 7 || deliveries[0].RepostOf != delivered.ID {
+		t.Errorf("deliveries = %+v, want the copy of delivery %d", deliveries, delivered.ID)
+	}
+
+	entries, _ := store.ListAuditEntries(ctx, models.AuditFilter{Action: audit.ActionPostReposted, Limit: 10})
+	if len(entries) != 1 || entries[0].Actor != audit.ActorScheduler || entries[0].TargetID != post.ID {
+		t.Errorf("audit entries = %+v, want one post.reposted by scheduler", entries)
+	}
+}
+
+// brokenChannels — хранилище, которое не может выдать активные каналы.
+type brokenChannels struct {
+	*storage.MemoryStorage
+}
+
+func (brokenChannels) GetActiveChannels(ctx context.Context) ([]models.Channel, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestPublishReleasesPostWhenPreparingFails(t *testing.T) {
+	fake := &fakeTelegram{}
+	_, store := newTestScheduler(t, fake)
+	s := NewScheduler(brokenChannels{store}, nil)
+	ctx := context.Background()
+
+	due := time.Now().Add(-time.Minute)
+	scheduled := models.Post{Content: "due", MediaType: "text", Status: "scheduled", ScheduleTime: &due}
+	now := models.Post{Content: "now", MediaType: "text", Status: "sending"}
+	store.CreatePost(ctx, &scheduled)
+	store.CreatePost(ctx, &now)
+	store.ClaimPostForSending(ctx, scheduled.ID, "")
+
+	for _, post := range []models.Post{scheduled, now} {
+		if err := s.Publish(ctx, post); err == nil {
+			t.Errorf("Publish(%d) = nil, want the preparing error", post.ID)
+		}
+	}
+
+	got, _ := store.GetPost(ctx, scheduled.ID)
+	if got.Status != "scheduled" || got.SentAt != nil {
+		t.Errorf("scheduled post = status %q sent_at %v, want scheduled again", got.Status, got.SentAt)
+	}
+	got, _ = store.GetPost(ctx, now.ID)
+	if got.Status != "draft" {
+		t.Errorf("post sent now = status %q, want draft", got.Status)
+	}
+	if len(fake.chats) != 0 {
+		t.Errorf("telegram calls = %d, want none", len(fake.chats))
+	}
+	entries, _ := store.ListAuditEntries(ctx, models.AuditFilter{Action: audit.ActionPostSent, Limit: 10})
+	if len(entries) != 0 {
+		t.Errorf("audit entries = %+v, want no post.sent", entries)
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// PostSortTime возвращает значение поля сортировки sort у поста или nil,
+// если оно не задано.
+func PostSortTime(post models.Post, sort string) *time.Time {
+	switch postSortColumn(sort) {
+	case models.PostSortScheduled:
+		return post.ScheduleTime
+	case models.PostSortSent:
+		return post.SentAt
+	default:
+		return &post.CreatedAt
+	}
+}
+
+// NextPostCursor возвращает курсор, продолжающий выдачу после post.
+func NextPostCursor(post models.Post, sort string) string {
+	t := PostSortTime(post, sort)
+	if t == nil {
+		return ""
+	}
+	raw := fmt.Sprintf("%d:%d", t.UnixNano(), post.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParsePostCursor разбирает курсор, выданный NextPostCursor.
+func ParsePostCursor(value string) (*models.PostCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var nanos int64
+	var id int
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &nanos, &id); err != nil {
+		return nil, errInvalidCursor
+	}
+
+	// Время без часового пояса хранится как UTC-стенные часы, поэтому
+	// курсор восстанавливается в UTC, чтобы сравнение шло с тем же значением
+	return &models.PostCursor{Time: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound — запись не найдена. Конкретные ошибки имеют тип
+	// *NotFoundError и сравниваются с ErrNotFound через errors.Is.
+	ErrNotFound = errors.New("storage: not found")
+
+	// ErrDuplicateKey возвращается при создании поста с ключом идемпотентности,
+	// который уже использован тем же автором, и медиафайла с уже известным
+	// хэшем содержимого.
+	ErrDuplicateKey = errors.New("storage: duplicate key")
+
+	// ErrDuplicateName возвращается при создании или переименовании тега
+	// в уже занятое имя.
+	ErrDuplicateName = errors.New("storage: duplicate name")
+)
+
+// NotFoundError описывает отсутствующую запись.
+type NotFoundError struct {
+	Entity string
+	Key    interface{}
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("storage: %s %v not found", e.Entity, e.Key)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+func notFound(entity string, key interface{}) error {
+	return &NotFoundError{Entity: entity, Key: key}
+}
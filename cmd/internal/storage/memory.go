@@ -0,0 +1,1430 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+// MemoryStorage хранит данные в памяти процесса с той же семантикой, что и
+// PostgresStorage. Используется в тестах и в демо-режиме.
+type MemoryStorage struct {
+	mu sync.RWMutex
+
+	channels     map[int]models.Channel
+	posts        map[int]models.Post
+	postChannels map[int]models.PostChannel
+	revisions    map[int]models.PostRevision
+	tags         map[int]models.Tag
+	postTags     map[int][]int
+	templates    map[int]models.PostTemplate
+	media        map[int]models.Media
+	auditLog     []models.AuditEntry
+	apiTokens    map[int]models.APIToken
+	forumTopics  map[forumTopicKey]models.ForumTopic
+	reposts      map[int]models.Repost
+
+	nextID map[string]int
+}
+
+// forumTopicKey — первичный ключ forum_topics.
+type forumTopicKey struct {
+	chatID   int64
+	threadID int
+}
+
+var _ Storage = (*MemoryStorage)(nil)
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		channels:     make(map[int]models.Channel),
+		posts:        make(map[int]models.Post),
+		postChannels: make(map[int]models.PostChannel),
+		revisions:    make(map[int]models.PostRevision),
+		tags:         make(map[int]models.Tag),
+		postTags:     make(map[int][]int),
+		templates:    make(map[int]models.PostTemplate),
+		media:        make(map[int]models.Media),
+		apiTokens:    make(map[int]models.APIToken),
+		forumTopics:  make(map[forumTopicKey]models.ForumTopic),
+		reposts:      make(map[int]models.Repost),
+		nextID:       make(map[string]int),
+	}
+}
+
+func (s *MemoryStorage) newID(table string) int {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// newestFirst сортирует по убыванию времени, при равенстве — по убыванию ID,
+// как ORDER BY ... DESC, id DESC в PostgresStorage.
+func newestFirst(ti, tj time.Time, idi, idj int) bool {
+	if !ti.Equal(tj) {
+		return ti.After(tj)
+	}
+	return idi > idj
+}
+
+// page применяет LIMIT/OFFSET.
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return items
+}
+
+func (s *MemoryStorage) GetChannels(ctx context.Context) ([]models.Channel, error) {
+	return s.filterChannels(func(c models.Channel) bool { return c.DeletedAt == nil }), nil
+}
+
+func (s *MemoryStorage) GetActiveChannels(ctx context.Context) ([]models.Channel, error) {
+	return s.filterChannels(func(c models.Channel) bool { return c.IsActive && c.DeletedAt == nil }), nil
+}
+
+func (s *MemoryStorage) filterChannels(keep func(models.Channel) bool) []models.Channel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var channels []models.Channel
+	for _, channel := range s.channels {
+		if keep(channel) {
+			channels = append(channels, channel)
+		}
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return newestFirst(channels[i].CreatedAt, channels[j].CreatedAt, channels[i].ID, channels[j].ID)
+	})
+
+	return channels
+}
+
+func (s *MemoryStorage) GetChannel(ctx context.Context, id int) (*models.Channel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	channel, ok := s.channels[id]
+	if !ok || channel.DeletedAt != nil {
+		return nil, notFound("channel", id)
+	}
+	return &channel, nil
+}
+
+func (s *MemoryStorage) CreateChannel(ctx context.Context, channel *models.Channel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel.ID = s.newID("channels")
+	channel.CreatedAt = time.Now()
+	s.channels[channel.ID] = copyChannel(*channel)
+
+	return nil
+}
+
+func (s *MemoryStorage) UpdateChannel(ctx context.Context, channel *models.Channel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.channels[channel.ID]
+	if !ok || stored.DeletedAt != nil {
+		return notFound("channel", channel.ID)
+	}
+
+	stored.TelegramID = channel.TelegramID
+	stored.Username = channel.Username
+	stored.Title = channel.Title
+	stored.IsActive = channel.IsActive
+	stored.Timezone = channel.Timezone
+	stored.WatermarkPath = channel.WatermarkPath
+	stored.WatermarkPosition = channel.WatermarkPosition
+	stored.Footer = channel.Footer
+	stored.Buttons = channel.Buttons
+	stored.DisableNotification = channel.DisableNotification
+	stored.DisableLinkPreview = channel.DisableLinkPreview
+	stored.ProtectContent = channel.ProtectContent
+	stored.LinkPreviewAbove = channel.LinkPreviewAbove
+	stored.LinkPreviewSize = channel.LinkPreviewSize
+	stored.MessageThreadID = channel.MessageThreadID
+	stored.DiscussionComment = channel.DiscussionComment
+	s.channels[channel.ID] = copyChannel(stored)
+
+	return nil
+}
+
+func (s *MemoryStorage) DeleteChannel(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel, ok := s.channels[id]
+	if !ok || channel.DeletedAt != nil {
+		return notFound("channel", id)
+	}
+
+	now := time.Now()
+	channel.DeletedAt = &now
+	s.channels[id] = channel
+
+	return nil
+}
+
+// copyChannel возвращает копию канала, не разделяющую Buttons с хранилищем.
+func copyChannel(channel models.Channel) models.Channel {
+	if channel.Buttons != nil {
+		channel.Buttons = append(json.RawMessage(nil), channel.Buttons...)
+	}
+	return channel
+}
+
+// copyPost возвращает копию поста, не разделяющую Buttons с хранилищем.
+func copyPost(post models.Post) models.Post {
+	if post.Buttons != nil {
+		post.Buttons = append(json.RawMessage(nil), post.Buttons...)
+	}
+	post.Variables = copyVariables(post.Variables)
+	post.Variants = copyVariants(post.Variants)
+	post.Options.DisableNotification = copyBool(post.Options.DisableNotification)
+	post.Options.DisableLinkPreview = copyBool(post.Options.DisableLinkPreview)
+	post.Options.ProtectContent = copyBool(post.Options.ProtectContent)
+	post.Options.LinkPreviewAbove = copyBool(post.Options.LinkPreviewAbove)
+	return post
+}
+
+func copyBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	v := *b
+	return &v
+}
+
+func copyVariants(variants []models.PostVariant) []models.PostVariant {
+	if len(variants) == 0 {
+		return nil
+	}
+	copied := make([]models.PostVariant, len(variants))
+	for i, v := range variants {
+		if v.Buttons != nil {
+			v.Buttons = append(json.RawMessage(nil), v.Buttons...)
+		}
+		v.ChannelIDs = append([]int(nil), v.ChannelIDs...)
+		copied[i] = v
+	}
+	return copied
+}
+
+func copyVariables(vars map[string]string) map[string]string {
+	if len(vars) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(vars))
+	for name, value := range vars {
+		copied[name] = value
+	}
+	return copied
+}
+
+func (s *MemoryStorage) CreatePost(ctx context.Context, post *models.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if post.IdempotencyKey != "" {
+		for _, existing := range s.posts {
+			if existing.CreatedBy == post.CreatedBy && existing.IdempotencyKey == post.IdempotencyKey {
+				return ErrDuplicateKey
+			}
+		}
+	}
+
+	post.ID = s.newID("posts")
+	post.CreatedAt = time.Now()
+	post.SendIdempotencyKey = ""
+	s.posts[post.ID] = copyPost(*post)
+
+	return nil
+}
+
+func (s *MemoryStorage) GetPost(ctx context.Context, id int) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	post, ok := s.posts[id]
+	if !ok || post.DeletedAt != nil {
+		return nil, notFound("post", id)
+	}
+
+	post = copyPost(post)
+	return &post, nil
+}
+
+func (s *MemoryStorage) GetPostByIdempotencyKey(ctx context.Context, createdBy, key string) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, post := range s.posts {
+		if key != "" && post.CreatedBy == createdBy && post.IdempotencyKey == key {
+			post = copyPost(post)
+			return &post, nil
+		}
+	}
+
+	return nil, notFound("post with idempotency key", key)
+}
+
+func (s *MemoryStorage) GetPosts(ctx context.Context, limit, offset int) ([]models.Post, error) {
+	return s.ListPosts(ctx, models.PostFilter{Limit: limit, Offset: offset})
+}
+
+func (s *MemoryStorage) ListPosts(ctx context.Context, filter models.PostFilter) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	words := strings.Fields(strings.ToLower(filter.Query))
+	column := postSortColumn(filter.Sort)
+
+	var posts []models.Post
+	for _, post := range s.posts {
+		sortTime := PostSortTime(post, column)
+		switch {
+		case post.DeletedAt != nil,
+			sortTime == nil,
+			filter.Status != "" && post.Status != filter.Status,
+			filter.Author != "" && post.CreatedBy != filter.Author,
+			filter.MediaType != "" && post.MediaType != filter.MediaType,
+			filter.ChannelID != 0 && !s.deliveredTo(post.ID, filter.ChannelID),
+			filter.TagID != 0 && !containsInt(s.postTags[post.ID], filter.TagID),
+			!containsWords(post.Content, words),
+			filter.From != nil && sortTime.Before(*filter.From),
+			filter.To != nil && !sortTime.Before(*filter.To):
+			continue
+		}
+		if filter.After != nil && !afterCursor(*sortTime, post.ID, *filter.After, filter.Asc) {
+			continue
+		}
+		posts = append(posts, copyPost(post))
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		ti, tj := *PostSortTime(posts[i], column), *PostSortTime(posts[j], column)
+		if filter.Asc {
+			return newestFirst(tj, ti, posts[j].ID, posts[i].ID)
+		}
+		return newestFirst(ti, tj, posts[i].ID, posts[j].ID)
+	})
+
+	if filter.After != nil {
+		filter.Offset = 0
+	}
+	return page(posts, filter.Limit, filter.Offset), nil
+}
+
+func (s *MemoryStorage) deliveredTo(postID, channelID int) bool {
+	for _, pc := range s.postChannels {
+		if pc.PostID == postID && pc.ChannelID == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWords(content string, words []string) bool {
+	content = strings.ToLower(content)
+	for _, word := range words {
+		if !strings.Contains(content, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// afterCursor сообщает, идёт ли позиция (t, id) после курсора в порядке
+// сортировки.
+func afterCursor(t time.Time, id int, cursor models.PostCursor, asc bool) bool {
+	if asc {
+		return t.After(cursor.Time) || (t.Equal(cursor.Time) && id > cursor.ID)
+	}
+	return t.Before(cursor.Time) || (t.Equal(cursor.Time) && id < cursor.ID)
+}
+
+func (s *MemoryStorage) GetScheduledPosts(ctx context.Context, before time.Time) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var posts []models.Post
+	for _, post := range s.posts {
+		if post.DeletedAt == nil && post.Status == "scheduled" && post.ScheduleTime != nil && !post.ScheduleTime.After(before) {
+			posts = append(posts, copyPost(post))
+		}
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		ti, tj := *posts[i].ScheduleTime, *posts[j].ScheduleTime
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return posts[i].ID < posts[j].ID
+	})
+
+	return posts, nil
+}
+
+func (s *MemoryStorage) UpdatePost(ctx context.Context, post *models.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.posts[post.ID]
+	if !ok || stored.DeletedAt != nil {
+		return notFound("post", post.ID)
+	}
+
+	s.updatePost(stored, post)
+	return nil
+}
+
+func (s *MemoryStorage) UpdatePostIfStatus(ctx context.Context, post *models.Post, status string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.posts[post.ID]
+	if !ok || stored.DeletedAt != nil || stored.Status != status {
+		return false, nil
+	}
+
+	s.updatePost(stored, post)
+	return true, nil
+}
+
+func (s *MemoryStorage) updatePost(stored models.Post, post *models.Post) {
+	stored.Content = post.Content
+	stored.MediaType = post.MediaType
+	stored.MediaPath = post.MediaPath
+	stored.Buttons = post.Buttons
+	stored.ScheduleTime = post.ScheduleTime
+	stored.Status = post.Status
+	stored.SentAt = post.SentAt
+	stored.Variables = post.Variables
+	stored.Variants = post.Variants
+	stored.Options = post.Options
+	s.posts[post.ID] = copyPost(stored)
+}
+
+func (s *MemoryStorage) ClaimPostForSending(ctx context.Context, id int, idempotencyKey string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[id]
+	if !ok || post.DeletedAt != nil || (post.Status != "draft" && post.Status != "scheduled") {
+		return false, nil
+	}
+
+	post.Status = "sending"
+	post.SendIdempotencyKey = idempotencyKey
+	s.posts[id] = post
+
+	return true, nil
+}
+
+func (s *MemoryStorage) DeletePost(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[id]
+	if !ok || post.DeletedAt != nil {
+		return notFound("post", id)
+	}
+
+	now := time.Now()
+	post.DeletedAt = &now
+	s.posts[id] = post
+
+	return nil
+}
+
+func (s *MemoryStorage) CreateMedia(ctx context.Context, media *models.Media) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.media {
+		if existing.Hash == media.Hash {
+			return ErrDuplicateKey
+		}
+	}
+
+	media.ID = s.newID("media")
+	media.CreatedAt = time.Now()
+	s.media[media.ID] = *media
+
+	return nil
+}
+
+func (s *MemoryStorage) GetMedia(ctx context.Context, id int) (*models.Media, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	media, ok := s.media[id]
+	if !ok {
+		return nil, notFound("media", id)
+	}
+	return &media, nil
+}
+
+func (s *MemoryStorage) GetMediaByHash(ctx context.Context, hash string) (*models.Media, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, media := range s.media {
+		if media.Hash == hash {
+			return &media, nil
+		}
+	}
+	return nil, notFound("media", hash)
+}
+
+func (s *MemoryStorage) ListMedia(ctx context.Context, filter models.MediaFilter) ([]models.Media, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := strings.ToLower(strings.TrimSpace(filter.Query))
+	var files []models.Media
+	for _, media := range s.media {
+		if query != "" && !strings.Contains(strings.ToLower(media.Filename), query) {
+			continue
+		}
+		if !strings.HasPrefix(media.MimeType, filter.MimePrefix) {
+			continue
+		}
+		files = append(files, media)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return newestFirst(files[i].CreatedAt, files[j].CreatedAt, files[i].ID, files[j].ID)
+	})
+
+	return page(files, filter.Limit, filter.Offset), nil
+}
+
+func (s *MemoryStorage) GetUnreferencedMedia(ctx context.Context, before time.Time) ([]models.Media, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	referenced := make(map[string]bool)
+	for _, post := range s.posts {
+		referenced[post.MediaPath] = true
+	}
+	for _, rev := range s.revisions {
+		referenced[rev.MediaPath] = true
+	}
+	for _, tpl := range s.templates {
+		referenced[tpl.MediaPath] = true
+	}
+
+	var files []models.Media
+	for _, media := range s.media {
+		if media.CreatedAt.Before(before) && !referenced[media.Path] {
+			files = append(files, media)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	return files, nil
+}
+
+func (s *MemoryStorage) DeleteMedia(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.media[id]; !ok {
+		return notFound("media", id)
+	}
+	delete(s.media, id)
+
+	return nil
+}
+
+func copyPostTemplate(tpl models.PostTemplate) models.PostTemplate {
+	if tpl.Buttons != nil {
+		tpl.Buttons = append(json.RawMessage(nil), tpl.Buttons...)
+	}
+	tpl.Variables = copyVariables(tpl.Variables)
+	return tpl
+}
+
+func (s *MemoryStorage) GetPostTemplates(ctx context.Context) ([]models.PostTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var templates []models.PostTemplate
+	for _, tpl := range s.templates {
+		templates = append(templates, copyPostTemplate(tpl))
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+func (s *MemoryStorage) GetPostTemplate(ctx context.Context, id int) (*models.PostTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tpl, ok := s.templates[id]
+	if !ok {
+		return nil, notFound("post template", id)
+	}
+
+	tpl = copyPostTemplate(tpl)
+	return &tpl, nil
+}
+
+func (s *MemoryStorage) templateNameTaken(name string, exceptID int) bool {
+	for _, tpl := range s.templates {
+		if tpl.Name == name && tpl.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStorage) CreatePostTemplate(ctx context.Context, tpl *models.PostTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.templateNameTaken(tpl.Name, 0) {
+		return ErrDuplicateName
+	}
+
+	tpl.ID = s.newID("post_templates")
+	tpl.CreatedAt = time.Now()
+	tpl.UpdatedAt = tpl.CreatedAt
+	s.templates[tpl.ID] = copyPostTemplate(*tpl)
+
+	return nil
+}
+
+func (s *MemoryStorage) UpdatePostTemplate(ctx context.Context, tpl *models.PostTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.templates[tpl.ID]
+	if !ok {
+		return notFound("post template", tpl.ID)
+	}
+	if s.templateNameTaken(tpl.Name, tpl.ID) {
+		return ErrDuplicateName
+	}
+
+	stored.Name = tpl.Name
+	stored.Content = tpl.Content
+	stored.MediaType = tpl.MediaType
+	stored.MediaPath = tpl.MediaPath
+	stored.Buttons = tpl.Buttons
+	stored.Variables = tpl.Variables
+	stored.UpdatedAt = time.Now()
+	s.templates[tpl.ID] = copyPostTemplate(stored)
+	tpl.UpdatedAt = stored.UpdatedAt
+
+	return nil
+}
+
+func (s *MemoryStorage) DeletePostTemplate(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.templates[id]; !ok {
+		return notFound("post template", id)
+	}
+	delete(s.templates, id)
+
+	return nil
+}
+
+func copyTag(tag models.Tag) models.Tag {
+	tag.ChannelIDs = append([]int(nil), tag.ChannelIDs...)
+	return tag
+}
+
+func containsInt(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// tagsByName возвращает копии тегов, отобранных keep, по алфавиту.
+func (s *MemoryStorage) tagsByName(keep func(models.Tag) bool) []models.Tag {
+	var tags []models.Tag
+	for _, tag := range s.tags {
+		if keep(tag) {
+			tags = append(tags, copyTag(tag))
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags
+}
+
+func (s *MemoryStorage) GetTags(ctx context.Context) ([]models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.tagsByName(func(models.Tag) bool { return true }), nil
+}
+
+func (s *MemoryStorage) GetTag(ctx context.Context, id int) (*models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tag, ok := s.tags[id]
+	if !ok {
+		return nil, notFound("tag", id)
+	}
+
+	tag = copyTag(tag)
+	return &tag, nil
+}
+
+func (s *MemoryStorage) tagNameTaken(name string, exceptID int) bool {
+	for _, tag := range s.tags {
+		if tag.Name == name && tag.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStorage) CreateTag(ctx context.Context, tag *models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tagNameTaken(tag.Name, 0) {
+		return ErrDuplicateName
+	}
+
+	tag.ID = s.newID("tags")
+	tag.CreatedAt = time.Now()
+	s.tags[tag.ID] = copyTag(*tag)
+
+	return nil
+}
+
+func (s *MemoryStorage) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tags[tag.ID]
+	if !ok {
+		return notFound("tag", tag.ID)
+	}
+	if s.tagNameTaken(tag.Name, tag.ID) {
+		return ErrDuplicateName
+	}
+
+	stored.Name = tag.Name
+	stored.Hashtag = tag.Hashtag
+	stored.ChannelIDs = tag.ChannelIDs
+	s.tags[tag.ID] = copyTag(stored)
+
+	return nil
+}
+
+func (s *MemoryStorage) DeleteTag(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tags[id]; !ok {
+		return notFound("tag", id)
+	}
+
+	delete(s.tags, id)
+
+	// ON DELETE CASCADE
+	for postID, tagIDs := range s.postTags {
+		var kept []int
+		for _, tagID := range tagIDs {
+			if tagID != id {
+				kept = append(kept, tagID)
+			}
+		}
+		s.postTags[postID] = kept
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) SetPostTags(ctx context.Context, postID int, tagIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Внешние ключи: пост и теги должны существовать
+	if _, ok := s.posts[postID]; !ok && len(tagIDs) > 0 {
+		return notFound("post", postID)
+	}
+
+	var ids []int
+	for _, id := range tagIDs {
+		if _, ok := s.tags[id]; !ok {
+			return notFound("tag", id)
+		}
+		if !containsInt(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	s.postTags[postID] = ids
+
+	return nil
+}
+
+func (s *MemoryStorage) GetPostTags(ctx context.Context, postID int) ([]models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.postTags[postID]
+	return s.tagsByName(func(tag models.Tag) bool { return containsInt(ids, tag.ID) }), nil
+}
+
+func (s *MemoryStorage) GetTagStatistics(ctx context.Context, days int) ([]models.TagStatistics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	since := time.Time{}
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	var stats []models.TagStatistics
+	for _, tag := range s.tagsByName(func(models.Tag) bool { return true }) {
+		st := models.TagStatistics{TagID: tag.ID, Name: tag.Name}
+
+		for postID, tagIDs := range s.postTags {
+			if !containsInt(tagIDs, tag.ID) {
+				continue
+			}
+			if post, ok := s.posts[postID]; ok && post.DeletedAt == nil {
+				st.Posts++
+			}
+		}
+
+		for _, pc := range s.postChannels {
+			if pc.SentAt.Before(since) || !containsInt(s.postTags[pc.PostID], tag.ID) {
+				continue
+			}
+			switch pc.Status {
+			case "sent":
+				st.Successful++
+			case "error":
+				st.Failed++
+			}
+		}
+
+		stats = append(stats, st)
+	}
+
+	return stats, nil
+}
+
+func (s *MemoryStorage) GetDeletedChannels(ctx context.Context) ([]models.Channel, error) {
+	channels := s.filterChannels(func(c models.Channel) bool { return c.DeletedAt != nil })
+	sort.Slice(channels, func(i, j int) bool {
+		return newestFirst(*channels[i].DeletedAt, *channels[j].DeletedAt, channels[i].ID, channels[j].ID)
+	})
+	return channels, nil
+}
+
+func (s *MemoryStorage) GetDeletedPosts(ctx context.Context) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var posts []models.Post
+	for _, post := range s.posts {
+		if post.DeletedAt != nil {
+			posts = append(posts, copyPost(post))
+		}
+	}
+
+	sort.Slice(posts, func(i, j int) bool {
+		return newestFirst(*posts[i].DeletedAt, *posts[j].DeletedAt, posts[i].ID, posts[j].ID)
+	})
+
+	return posts, nil
+}
+
+func (s *MemoryStorage) RestoreChannel(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel, ok := s.channels[id]
+	if !ok || channel.DeletedAt == nil {
+		return notFound("deleted channel", id)
+	}
+
+	channel.DeletedAt = nil
+	s.channels[id] = channel
+
+	return nil
+}
+
+func (s *MemoryStorage) RestorePost(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[id]
+	if !ok || post.DeletedAt == nil {
+		return notFound("deleted post", id)
+	}
+
+	post.DeletedAt = nil
+	s.posts[id] = post
+
+	return nil
+}
+
+func (s *MemoryStorage) PurgeChannel(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel, ok := s.channels[id]
+	if !ok || channel.DeletedAt == nil {
+		return notFound("deleted channel", id)
+	}
+
+	s.purgeChannel(id)
+	return nil
+}
+
+func (s *MemoryStorage) PurgePost(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.posts[id]
+	if !ok || post.DeletedAt == nil {
+		return notFound("deleted post", id)
+	}
+
+	s.purgePost(id)
+	return nil
+}
+
+func (s *MemoryStorage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for id, post := range s.posts {
+		if post.DeletedAt != nil && post.DeletedAt.Before(before) {
+			s.purgePost(id)
+			purged++
+		}
+	}
+	for id, channel := range s.channels {
+		if channel.DeletedAt != nil && channel.DeletedAt.Before(before) {
+			s.purgeChannel(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// purgeChannel удаляет канал окончательно. Доставки остаются для
+// статистики, ссылка на канал обнуляется (ON DELETE SET NULL); повторы в
+// канал удаляются (ON DELETE CASCADE).
+func (s *MemoryStorage) purgeChannel(id int) {
+	delete(s.channels, id)
+
+	for repostID, repost := range s.reposts {
+		if repost.ChannelID == id {
+			delete(s.reposts, repostID)
+		}
+	}
+
+	for pcID, pc := range s.postChannels {
+		if pc.ChannelID == id {
+			pc.ChannelID = 0
+			s.postChannels[pcID] = pc
+		}
+	}
+}
+
+// purgePost удаляет пост окончательно вместе с ревизиями (ON DELETE
+// CASCADE); доставки остаются с обнулённой ссылкой на пост.
+func (s *MemoryStorage) purgePost(id int) {
+	delete(s.posts, id)
+
+	for pcID, pc := range s.postChannels {
+		if pc.PostID == id {
+			pc.PostID = 0
+			s.postChannels[pcID] = pc
+		}
+	}
+	for revID, rev := range s.revisions {
+		if rev.PostID == id {
+			delete(s.revisions, revID)
+		}
+	}
+	delete(s.postTags, id)
+}
+
+// copyRevision возвращает копию ревизии, не разделяющую Buttons с хранилищем.
+func copyRevision(rev models.PostRevision) models.PostRevision {
+	if rev.Buttons != nil {
+		rev.Buttons = append(json.RawMessage(nil), rev.Buttons...)
+	}
+	return rev
+}
+
+func (s *MemoryStorage) CreatePostRevision(ctx context.Context, rev *models.PostRevision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[rev.PostID]; !ok {
+		return notFound("post", rev.PostID)
+	}
+
+	number := 0
+	for _, existing := range s.revisions {
+		if existing.PostID == rev.PostID && existing.Number > number {
+			number = existing.Number
+		}
+	}
+
+	rev.ID = s.newID("post_revisions")
+	rev.Number = number + 1
+	rev.CreatedAt = time.Now()
+	s.revisions[rev.ID] = copyRevision(*rev)
+
+	return nil
+}
+
+func (s *MemoryStorage) GetPostRevision(ctx context.Context, id int) (*models.PostRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rev, ok := s.revisions[id]
+	if !ok {
+		return nil, notFound("post revision", id)
+	}
+
+	rev = copyRevision(rev)
+	return &rev, nil
+}
+
+func (s *MemoryStorage) GetPostRevisions(ctx context.Context, postID int) ([]models.PostRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var revs []models.PostRevision
+	for _, rev := range s.revisions {
+		if rev.PostID == postID {
+			revs = append(revs, copyRevision(rev))
+		}
+	}
+
+	sort.Slice(revs, func(i, j int) bool {
+		return revs[i].Number > revs[j].Number
+	})
+
+	return revs, nil
+}
+
+func (s *MemoryStorage) CreatePostChannel(ctx context.Context, pc *models.PostChannel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pc.ID = s.newID("post_channels")
+	pc.SentAt = time.Now()
+	s.postChannels[pc.ID] = *pc
+
+	return nil
+}
+
+func (s *MemoryStorage) GetPostChannel(ctx context.Context, id int) (*models.PostChannel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pc, ok := s.postChannels[id]
+	if !ok {
+		return nil, notFound("delivery", id)
+	}
+	return &pc, nil
+}
+
+func (s *MemoryStorage) GetPostChannels(ctx context.Context, filter models.DeliveryFilter) ([]models.PostChannel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deliveries []models.PostChannel
+	for _, pc := range s.postChannels {
+		if filter.PostID != 0 && pc.PostID != filter.PostID {
+			continue
+		}
+		if filter.ChannelID != 0 && pc.ChannelID != filter.ChannelID {
+			continue
+		}
+		if filter.Status != "" && pc.Status != filter.Status {
+			continue
+		}
+		deliveries = append(deliveries, pc)
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return newestFirst(deliveries[i].SentAt, deliveries[j].SentAt, deliveries[i].ID, deliveries[j].ID)
+	})
+
+	return page(deliveries, filter.Limit, filter.Offset), nil
+}
+
+func (s *MemoryStorage) GetPendingComment(ctx context.Context, chatID int64, messageID int) (*models.PostChannel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *models.PostChannel
+	for _, pc := range s.postChannels {
+		if pc.MessageID != messageID || pc.CommentStatus != models.CommentPending {
+			continue
+		}
+		if s.channels[pc.ChannelID].TelegramID != chatID {
+			continue
+		}
+		if found == nil || pc.ID > found.ID {
+			pc := pc
+			found = &pc
+		}
+	}
+	if found == nil {
+		return nil, notFound("delivery", messageID)
+	}
+	return found, nil
+}
+
+func (s *MemoryStorage) UpdatePostChannelComment(ctx context.Context, pc *models.PostChannel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.postChannels[pc.ID]
+	if !ok {
+		return notFound("delivery", pc.ID)
+	}
+	stored.CommentStatus = pc.CommentStatus
+	stored.CommentMessageID = pc.CommentMessageID
+	stored.CommentError = pc.CommentError
+	s.postChannels[pc.ID] = stored
+
+	return nil
+}
+
+func (s *MemoryStorage) CreateRepost(ctx context.Context, repost *models.Repost) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repost.ID = s.newID("reposts")
+	repost.CreatedAt = time.Now()
+	s.reposts[repost.ID] = *repost
+
+	return nil
+}
+
+func (s *MemoryStorage) GetPostReposts(ctx context.Context, postID int) ([]models.Repost, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reposts []models.Repost
+	for _, repost := range s.reposts {
+		if s.postChannels[repost.PostChannelID].PostID == postID {
+			reposts = append(reposts, repost)
+		}
+	}
+	sort.Slice(reposts, func(i, j int) bool {
+		return newestFirst(reposts[i].CreatedAt, reposts[j].CreatedAt, reposts[i].ID, reposts[j].ID)
+	})
+
+	return reposts, nil
+}
+
+func (s *MemoryStorage) GetDueReposts(ctx context.Context, before time.Time) ([]models.Repost, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reposts []models.Repost
+	for _, repost := range s.reposts {
+		if repost.Status == "scheduled" && repost.ScheduleTime != nil && !repost.ScheduleTime.After(before) {
+			reposts = append(reposts, repost)
+		}
+	}
+	sort.Slice(reposts, func(i, j int) bool {
+		if !reposts[i].ScheduleTime.Equal(*reposts[j].ScheduleTime) {
+			return reposts[i].ScheduleTime.Before(*reposts[j].ScheduleTime)
+		}
+		return reposts[i].ID < reposts[j].ID
+	})
+
+	return reposts, nil
+}
+
+func (s *MemoryStorage) ClaimRepost(ctx context.Context, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repost, ok := s.reposts[id]
+	if !ok || repost.Status != "scheduled" {
+		return false, nil
+	}
+	repost.Status = "sending"
+	s.reposts[id] = repost
+
+	return true, nil
+}
+
+func (s *MemoryStorage) UpdateRepostStatus(ctx context.Context, id int, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repost, ok := s.reposts[id]
+	if !ok {
+		return notFound("repost", id)
+	}
+	repost.Status = status
+	s.reposts[id] = repost
+
+	return nil
+}
+
+func (s *MemoryStorage) SaveForumTopic(ctx context.Context, topic *models.ForumTopic) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	topic.UpdatedAt = time.Now()
+	s.forumTopics[forumTopicKey{topic.ChatID, topic.ThreadID}] = *topic
+
+	return nil
+}
+
+func (s *MemoryStorage) GetForumTopics(ctx context.Context, chatID int64) ([]models.ForumTopic, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var topics []models.ForumTopic
+	for key, topic := range s.forumTopics {
+		if key.chatID == chatID {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].ThreadID < topics[j].ThreadID })
+
+	return topics, nil
+}
+
+func (s *MemoryStorage) GetVariantStatistics(ctx context.Context, postID int) ([]models.VariantStatistics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byName := make(map[string]*models.VariantStatistics)
+	channels := make(map[string]map[int]bool)
+	for _, pc := range s.postChannels {
+		if pc.PostID != postID {
+			continue
+		}
+		st, ok := byName[pc.Variant]
+		if !ok {
+			st = &models.VariantStatistics{Variant: pc.Variant}
+			byName[pc.Variant] = st
+			channels[pc.Variant] = make(map[int]bool)
+		}
+		channels[pc.Variant][pc.ChannelID] = true
+		switch pc.Status {
+		case "sent":
+			st.Successful++
+		case "error":
+			st.Failed++
+		}
+	}
+
+	stats := make([]models.VariantStatistics, 0, len(byName))
+	for name, st := range byName {
+		st.Channels = len(channels[name])
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Variant < stats[j].Variant })
+	return stats, nil
+}
+
+func (s *MemoryStorage) GetStatistics(ctx context.Context, days int) (*models.Statistics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &models.Statistics{}
+
+	since := time.Time{}
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	for _, post := range s.posts {
+		if post.DeletedAt != nil {
+			continue
+		}
+		stats.TotalPosts++
+		if post.Status == "scheduled" {
+			stats.Scheduled++
+		}
+	}
+
+	for _, pc := range s.postChannels {
+		if pc.SentAt.Before(since) {
+			continue
+		}
+		switch pc.Status {
+		case "sent":
+			stats.Successful++
+		case "error":
+			stats.Failed++
+		}
+	}
+
+	for _, channel := range s.channels {
+		if channel.DeletedAt != nil {
+			continue
+		}
+		stats.TotalChannels++
+		if channel.IsActive {
+			stats.ActiveChannels++
+		}
+	}
+
+	return stats, nil
+}
+
+func copyAPIToken(token models.APIToken) models.APIToken {
+	token.Scopes = append([]string(nil), token.Scopes...)
+	return token
+}
+
+func (s *MemoryStorage) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.ID = s.newID("audit_log")
+	entry.CreatedAt = time.Now()
+
+	stored := *entry
+	stored.Before = append(json.RawMessage(nil), entry.Before...)
+	stored.After = append(json.RawMessage(nil), entry.After...)
+	s.auditLog = append(s.auditLog, stored)
+
+	return nil
+}
+
+func (s *MemoryStorage) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []models.AuditEntry
+	for _, entry := range s.auditLog {
+		switch {
+		case filter.Actor != "" && entry.Actor != filter.Actor,
+			filter.Action != "" && entry.Action != filter.Action,
+			filter.TargetType != "" && entry.TargetType != filter.TargetType,
+			filter.TargetID != 0 && entry.TargetID != filter.TargetID,
+			filter.From != nil && entry.CreatedAt.Before(*filter.From),
+			filter.To != nil && !entry.CreatedAt.Before(*filter.To),
+			filter.After != nil && !afterCursor(entry.CreatedAt, entry.ID, *filter.After, false):
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return newestFirst(entries[i].CreatedAt, entries[j].CreatedAt, entries[i].ID, entries[j].ID)
+	})
+
+	return page(entries, filter.Limit, auditOffset(filter)), nil
+}
+
+func (s *MemoryStorage) CreateAPIToken(ctx context.Context, token *models.APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.ID = s.newID("api_tokens")
+	token.CreatedAt = time.Now()
+	s.apiTokens[token.ID] = copyAPIToken(*token)
+
+	return nil
+}
+
+func (s *MemoryStorage) GetAPITokenByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.apiTokens {
+		if token.TokenHash == hash {
+			token = copyAPIToken(token)
+			return &token, nil
+		}
+	}
+
+	return nil, notFound("api token", "(hash)")
+}
+
+func (s *MemoryStorage) GetAPITokens(ctx context.Context) ([]models.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tokens []models.APIToken
+	for _, token := range s.apiTokens {
+		tokens = append(tokens, copyAPIToken(token))
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return newestFirst(tokens[i].CreatedAt, tokens[j].CreatedAt, tokens[i].ID, tokens[j].ID)
+	})
+
+	return tokens, nil
+}
+
+func (s *MemoryStorage) TouchAPIToken(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.apiTokens[id]
+	if !ok {
+		return notFound("api token", id)
+	}
+
+	now := time.Now()
+	token.LastUsedAt = &now
+	s.apiTokens[id] = token
+
+	return nil
+}
+
+func (s *MemoryStorage) RevokeAPIToken(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.apiTokens[id]
+	if !ok || token.RevokedAt != nil {
+		return notFound("api token", id)
+	}
+
+	now := time.Now()
+	token.RevokedAt = &now
+	s.apiTokens[id] = token
+
+	return nil
+}
@@ -1,10 +1,15 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/maksekak/channelBot/cmd/internal/models"
 )
 
@@ -12,6 +17,8 @@ type PostgresStorage struct {
 	db *sql.DB
 }
 
+var _ Storage = (*PostgresStorage)(nil)
+
 func NewPostgresStorage(connStr string) (*PostgresStorage, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
@@ -25,9 +32,54 @@ func NewPostgresStorage(connStr string) (*PostgresStorage, error) {
 	return &PostgresStorage{db: db}, nil
 }
 
-func (s *PostgresStorage) GetChannels() ([]models.Channel, error) {
-	query := `SELECT id, telegram_id, username, title, is_active, timezone, created_at FROM channels ORDER BY created_at DESC`
-	rows, err := s.db.Query(query)
+func (s *PostgresStorage) DB() *sql.DB {
+	return s.db
+}
+
+func (s *PostgresStorage) Close() error {
+	return s.db.Close()
+}
+
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const channelColumns = `id, telegram_id, COALESCE(username, ''), title, is_active, timezone, created_at, deleted_at,
+              COALESCE(watermark_path, ''), COALESCE(watermark_position, ''), COALESCE(footer, ''), buttons,
+              disable_notification, disable_link_preview, protect_content, link_preview_above, COALESCE(link_preview_size, ''),
+              message_thread_id, COALESCE(discussion_comment, '')`
+
+func scanChannel(row rowScanner) (models.Channel, error) {
+	var channel models.Channel
+	var buttons []byte
+	err := row.Scan(
+		&channel.ID,
+		&channel.TelegramID,
+		&channel.Username,
+		&channel.Title,
+		&channel.IsActive,
+		&channel.Timezone,
+		&channel.CreatedAt,
+		&channel.DeletedAt,
+		&channel.WatermarkPath,
+		&channel.WatermarkPosition,
+		&channel.Footer,
+		&buttons,
+		&channel.DisableNotification,
+		&channel.DisableLinkPreview,
+		&channel.ProtectContent,
+		&channel.LinkPreviewAbove,
+		&channel.LinkPreviewSize,
+		&channel.MessageThreadID,
+		&channel.DiscussionComment,
+	)
+	channel.Buttons = buttons
+	return channel, err
+}
+
+func (s *PostgresStorage) queryChannels(ctx context.Context, query string, args ...interface{}) ([]models.Channel, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -35,42 +87,159 @@ func (s *PostgresStorage) GetChannels() ([]models.Channel, error) {
 
 	var channels []models.Channel
 	for rows.Next() {
-		var channel models.Channel
-		err := rows.Scan(
-			&channel.ID,
-			&channel.TelegramID,
-			&channel.Username,
-			&channel.Title,
-			&channel.IsActive,
-			&channel.Timezone,
-			&channel.CreatedAt,
-		)
+		channel, err := scanChannel(rows)
 		if err != nil {
 			return nil, err
 		}
 		channels = append(channels, channel)
 	}
 
-	return channels, nil
+	return channels, rows.Err()
+}
+
+func (s *PostgresStorage) GetChannels(ctx context.Context) ([]models.Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE deleted_at IS NULL ORDER BY created_at DESC, id DESC`
+	return s.queryChannels(ctx, query)
+}
+
+func (s *PostgresStorage) GetActiveChannels(ctx context.Context) ([]models.Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE is_active = true AND deleted_at IS NULL ORDER BY created_at DESC, id DESC`
+	return s.queryChannels(ctx, query)
+}
+
+func (s *PostgresStorage) GetChannel(ctx context.Context, id int) (*models.Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE id = $1 AND deleted_at IS NULL`
+	channel, err := scanChannel(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "channel", id)
+	}
+	return &channel, nil
 }
 
-func (s *PostgresStorage) CreateChannel(channel *models.Channel) error {
-	query := `INSERT INTO channels (telegram_id, username, title, is_active, timezone, created_at) 
-              VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
-	return s.db.QueryRow(query,
+func (s *PostgresStorage) CreateChannel(ctx context.Context, channel *models.Channel) error {
+	query := `INSERT INTO channels (telegram_id, username, title, is_active, timezone, created_at, watermark_path, watermark_position,
+                  footer, buttons, disable_notification, disable_link_preview, protect_content, link_preview_above, link_preview_size,
+                  message_thread_id, discussion_comment)
+              VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $11, $12, $13, $14, NULLIF($15, ''),
+                  $16, NULLIF($17, ''))
+              RETURNING id, created_at`
+	return s.db.QueryRowContext(ctx, query,
 		channel.TelegramID,
 		channel.Username,
 		channel.Title,
 		channel.IsActive,
 		channel.Timezone,
 		time.Now(),
-	).Scan(&channel.ID)
+		channel.WatermarkPath,
+		channel.WatermarkPosition,
+		channel.Footer,
+		buttonsJSON(channel.Buttons),
+		channel.DisableNotification,
+		channel.DisableLinkPreview,
+		channel.ProtectContent,
+		channel.LinkPreviewAbove,
+		channel.LinkPreviewSize,
+		channel.MessageThreadID,
+		channel.DiscussionComment,
+	).Scan(&channel.ID, &channel.CreatedAt)
 }
 
-func (s *PostgresStorage) CreatePost(post *models.Post) error {
-	query := `INSERT INTO posts (content, media_type, media_path, buttons, schedule_time, status, created_by, created_at) 
-              VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-	return s.db.QueryRow(query,
+func (s *PostgresStorage) UpdateChannel(ctx context.Context, channel *models.Channel) error {
+	query := `UPDATE channels SET telegram_id = $1, username = $2, title = $3, is_active = $4, timezone = $5,
+              watermark_path = NULLIF($6, ''), watermark_position = NULLIF($7, ''), footer = NULLIF($8, ''), buttons = $9,
+              disable_notification = $10, disable_link_preview = $11, protect_content = $12, link_preview_above = $13,
+              link_preview_size = NULLIF($14, ''), message_thread_id = $15, discussion_comment = NULLIF($16, '')
+              WHERE id = $17 AND deleted_at IS NULL`
+	err := s.execAffectingOne(ctx, query,
+		channel.TelegramID,
+		channel.Username,
+		channel.Title,
+		channel.IsActive,
+		channel.Timezone,
+		channel.WatermarkPath,
+		channel.WatermarkPosition,
+		channel.Footer,
+		buttonsJSON(channel.Buttons),
+		channel.DisableNotification,
+		channel.DisableLinkPreview,
+		channel.ProtectContent,
+		channel.LinkPreviewAbove,
+		channel.LinkPreviewSize,
+		channel.MessageThreadID,
+		channel.DiscussionComment,
+		channel.ID,
+	)
+	return notFoundIfNoRows(err, "channel", channel.ID)
+}
+
+func (s *PostgresStorage) DeleteChannel(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE channels SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`, time.Now(), id)
+	return notFoundIfNoRows(err, "channel", id)
+}
+
+const postColumns = `id, COALESCE(content, ''), media_type, COALESCE(media_path, ''), buttons, schedule_time, status,
+              COALESCE(created_by, ''), created_at, sent_at, deleted_at, COALESCE(idempotency_key, ''), COALESCE(send_idempotency_key, ''),
+              variables, variants, send_options`
+
+func scanPost(row rowScanner) (models.Post, error) {
+	var post models.Post
+	// buttons может быть NULL, а json.RawMessage не сканирует NULL и строки
+	var buttons, variables, variants, options []byte
+	err := row.Scan(
+		&post.ID,
+		&post.Content,
+		&post.MediaType,
+		&post.MediaPath,
+		&buttons,
+		&post.ScheduleTime,
+		&post.Status,
+		&post.CreatedBy,
+		&post.CreatedAt,
+		&post.SentAt,
+		&post.DeletedAt,
+		&post.IdempotencyKey,
+		&post.SendIdempotencyKey,
+		&variables,
+		&variants,
+		&options,
+	)
+	post.Buttons = buttons
+	if err == nil && len(variables) > 0 {
+		err = json.Unmarshal(variables, &post.Variables)
+	}
+	if err == nil && len(variants) > 0 {
+		err = json.Unmarshal(variants, &post.Variants)
+	}
+	if err == nil && len(options) > 0 {
+		err = json.Unmarshal(options, &post.Options)
+	}
+	return post, err
+}
+
+func (s *PostgresStorage) queryPosts(ctx context.Context, query string, args ...interface{}) ([]models.Post, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
+func (s *PostgresStorage) CreatePost(ctx context.Context, post *models.Post) error {
+	query := `INSERT INTO posts (content, media_type, media_path, buttons, schedule_time, status, created_by, created_at, idempotency_key, variables, variants,
+                  send_options)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12) RETURNING id, created_at`
+	err := s.db.QueryRowContext(ctx, query,
 		post.Content,
 		post.MediaType,
 		post.MediaPath,
@@ -79,92 +248,1213 @@ func (s *PostgresStorage) CreatePost(post *models.Post) error {
 		post.Status,
 		post.CreatedBy,
 		time.Now(),
-	).Scan(&post.ID)
+		post.IdempotencyKey,
+		variablesJSON(post.Variables),
+		variantsJSON(post.Variants),
+		sendOptionsJSON(post.Options),
+	).Scan(&post.ID, &post.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "idx_posts_idempotency_key" {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (s *PostgresStorage) GetPost(ctx context.Context, id int) (*models.Post, error) {
+	post, err := scanPost(s.db.QueryRowContext(ctx, `SELECT `+postColumns+` FROM posts WHERE id = $1 AND deleted_at IS NULL`, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "post", id)
+	}
+	return &post, nil
+}
+
+func (s *PostgresStorage) GetPostByIdempotencyKey(ctx context.Context, createdBy, key string) (*models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE created_by = $1 AND idempotency_key = $2`
+	post, err := scanPost(s.db.QueryRowContext(ctx, query, createdBy, key))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "post with idempotency key", key)
+	}
+	return &post, nil
+}
+
+func (s *PostgresStorage) GetPosts(ctx context.Context, limit, offset int) ([]models.Post, error) {
+	return s.ListPosts(ctx, models.PostFilter{Limit: limit, Offset: offset})
+}
+
+func (s *PostgresStorage) ListPosts(ctx context.Context, filter models.PostFilter) ([]models.Post, error) {
+	placeholder := func(n int) string { return fmt.Sprintf("$%d", n) }
+
+	// Слова ищутся с учётом морфологии русского или английского языка
+	search := func(query string, arg func(interface{}) string) string {
+		p := arg(query)
+		return `(to_tsvector('russian', COALESCE(content, '')) @@ plainto_tsquery('russian', ` + p + `)
+              OR to_tsvector('english', COALESCE(content, '')) @@ plainto_tsquery('english', ` + p + `))`
+	}
+
+	query, args := postListQuery(filter, placeholder, search)
+	return s.queryPosts(ctx, query, args...)
+}
+
+// postSortColumn возвращает колонку сортировки списка постов.
+func postSortColumn(sort string) string {
+	switch sort {
+	case models.PostSortScheduled, models.PostSortSent:
+		return sort
+	default:
+		return models.PostSortCreated
+	}
+}
+
+// postListQuery строит запрос списка постов по фильтру. placeholder
+// возвращает плейсхолдер n-го аргумента, search — условие полнотекстового
+// поиска, добавляя аргументы через arg.
+func postListQuery(
+	filter models.PostFilter,
+	placeholder func(n int) string,
+	search func(query string, arg func(interface{}) string) string,
+) (string, []interface{}) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return placeholder(len(args))
+	}
+
+	column := postSortColumn(filter.Sort)
+	conditions := []string{"deleted_at IS NULL"}
+	if column != models.PostSortCreated {
+		conditions = append(conditions, column+" IS NOT NULL")
+	}
+
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+	if filter.Author != "" {
+		conditions = append(conditions, "created_by = "+arg(filter.Author))
+	}
+	if filter.MediaType != "" {
+		conditions = append(conditions, "media_type = "+arg(filter.MediaType))
+	}
+	if filter.ChannelID != 0 {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM post_channels pc WHERE pc.post_id = posts.id AND pc.channel_id = "+arg(filter.ChannelID)+")")
+	}
+	if filter.TagID != 0 {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM post_tags pt WHERE pt.post_id = posts.id AND pt.tag_id = "+arg(filter.TagID)+")")
+	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		conditions = append(conditions, search(q, arg))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, column+" >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, column+" < "+arg(*filter.To))
+	}
+
+	direction, compare := "DESC", "<"
+	if filter.Asc {
+		direction, compare = "ASC", ">"
+	}
+
+	if filter.After != nil {
+		// Плейсхолдеры SQLite позиционные, поэтому время передаётся дважды
+		after := filter.After
+		conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[4]s AND id %[2]s %[5]s))",
+			column, compare, arg(after.Time), arg(after.Time), arg(after.ID)))
+	}
+
+	query := `SELECT ` + postColumns + ` FROM posts WHERE ` + strings.Join(conditions, " AND ") +
+		fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s LIMIT %[3]s", column, direction, arg(filter.Limit))
+	if filter.After == nil {
+		query += " OFFSET " + arg(filter.Offset)
+	}
+
+	return query, args
+}
+
+func (s *PostgresStorage) GetScheduledPosts(ctx context.Context, before time.Time) ([]models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts
+              WHERE schedule_time <= $1 AND status = 'scheduled' AND deleted_at IS NULL ORDER BY schedule_time, id`
+	return s.queryPosts(ctx, query, before)
+}
+
+func (s *PostgresStorage) UpdatePost(ctx context.Context, post *models.Post) error {
+	return notFoundIfNoRows(s.updatePost(ctx, post, ""), "post", post.ID)
+}
+
+func (s *PostgresStorage) UpdatePostIfStatus(ctx context.Context, post *models.Post, status string) (bool, error) {
+	err := s.updatePost(ctx, post, " AND status = $12", status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// updatePost записывает поля поста; condition с аргументами extra
+// дополняет условие WHERE.
+func (s *PostgresStorage) updatePost(ctx context.Context, post *models.Post, condition string, extra ...interface{}) error {
+	query := `UPDATE posts SET content = $1, media_type = $2, media_path = $3, buttons = $4,
+              schedule_time = $5, status = $6, sent_at = $7, variables = $8, variants = $9,
+              send_options = $10 WHERE id = $11 AND deleted_at IS NULL` + condition
+	args := []interface{}{
+		post.Content,
+		post.MediaType,
+		post.MediaPath,
+		post.Buttons,
+		post.ScheduleTime,
+		post.Status,
+		post.SentAt,
+		variablesJSON(post.Variables),
+		variantsJSON(post.Variants),
+		sendOptionsJSON(post.Options),
+		post.ID,
+	}
+	return s.execAffectingOne(ctx, query, append(args, extra...)...)
+}
+
+func (s *PostgresStorage) ClaimPostForSending(ctx context.Context, id int, idempotencyKey string) (bool, error) {
+	query := `UPDATE posts SET status = 'sending', send_idempotency_key = NULLIF($2, '')
+              WHERE id = $1 AND status IN ('draft', 'scheduled') AND deleted_at IS NULL`
+	err := s.execAffectingOne(ctx, query, id, idempotencyKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *PostgresStorage) DeletePost(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE posts SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`, time.Now(), id)
+	return notFoundIfNoRows(err, "post", id)
+}
+
+const postRevisionColumns = `id, post_id, number, COALESCE(content, ''), COALESCE(media_type, ''), COALESCE(media_path, ''),
+              buttons, schedule_time, COALESCE(author, ''), COALESCE(reason, ''), created_at`
+
+func scanPostRevision(row rowScanner) (models.PostRevision, error) {
+	var rev models.PostRevision
+	var buttons []byte
+	err := row.Scan(
+		&rev.ID,
+		&rev.PostID,
+		&rev.Number,
+		&rev.Content,
+		&rev.MediaType,
+		&rev.MediaPath,
+		&buttons,
+		&rev.ScheduleTime,
+		&rev.Author,
+		&rev.Reason,
+		&rev.CreatedAt,
+	)
+	rev.Buttons = buttons
+	return rev, err
+}
+
+const tagColumns = `id, name, hashtag, channel_ids, created_at`
+
+func scanTag(row rowScanner) (models.Tag, error) {
+	var tag models.Tag
+	var channelIDs []byte
+	err := row.Scan(&tag.ID, &tag.Name, &tag.Hashtag, &channelIDs, &tag.CreatedAt)
+	if err == nil && len(channelIDs) > 0 {
+		err = json.Unmarshal(channelIDs, &tag.ChannelIDs)
+	}
+	return tag, err
+}
+
+// tagChannelIDs сериализует каналы тега; пустой список хранится как NULL.
+func tagChannelIDs(ids []int) []byte {
+	if len(ids) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(ids)
+	return data
+}
+
+// variablesJSON сериализует переменные поста или шаблона; пустые хранятся
+// как NULL.
+func variablesJSON(vars map[string]string) []byte {
+	if len(vars) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(vars)
+	return data
+}
+
+// sendOptionsJSON сериализует настройки отправки поста; настройки по
+// умолчанию хранятся как NULL.
+func sendOptionsJSON(options models.SendOptions) []byte {
+	if options == (models.SendOptions{}) {
+		return nil
+	}
+	data, _ := json.Marshal(options)
+	return data
+}
+
+// buttonsJSON возвращает кнопки для колонки JSONB; пустые хранятся как NULL.
+func buttonsJSON(buttons json.RawMessage) []byte {
+	if len(buttons) == 0 || string(buttons) == "null" {
+		return nil
+	}
+	return buttons
+}
+
+// variantsJSON сериализует варианты поста; пустой список хранится как NULL.
+func variantsJSON(variants []models.PostVariant) []byte {
+	if len(variants) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(variants)
+	return data
+}
+
+const postTemplateColumns = `id, name, COALESCE(content, ''), media_type, COALESCE(media_path, ''), buttons, variables,
+              COALESCE(created_by, ''), created_at, updated_at`
+
+func scanPostTemplate(row rowScanner) (models.PostTemplate, error) {
+	var tpl models.PostTemplate
+	var buttons, variables []byte
+	err := row.Scan(
+		&tpl.ID,
+		&tpl.Name,
+		&tpl.Content,
+		&tpl.MediaType,
+		&tpl.MediaPath,
+		&buttons,
+		&variables,
+		&tpl.CreatedBy,
+		&tpl.CreatedAt,
+		&tpl.UpdatedAt,
+	)
+	tpl.Buttons = buttons
+	if err == nil && len(variables) > 0 {
+		err = json.Unmarshal(variables, &tpl.Variables)
+	}
+	return tpl, err
+}
+
+func queryPostTemplates(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]models.PostTemplate, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []models.PostTemplate
+	for rows.Next() {
+		tpl, err := scanPostTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, tpl)
+	}
+
+	return templates, rows.Err()
+}
+
+func (s *PostgresStorage) GetPostTemplates(ctx context.Context) ([]models.PostTemplate, error) {
+	return queryPostTemplates(ctx, s.db, `SELECT `+postTemplateColumns+` FROM post_templates ORDER BY name`)
+}
+
+func (s *PostgresStorage) GetPostTemplate(ctx context.Context, id int) (*models.PostTemplate, error) {
+	query := `SELECT ` + postTemplateColumns + ` FROM post_templates WHERE id = $1`
+	tpl, err := scanPostTemplate(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "post template", id)
+	}
+	return &tpl, nil
+}
+
+func (s *PostgresStorage) CreatePostTemplate(ctx context.Context, tpl *models.PostTemplate) error {
+	query := `INSERT INTO post_templates (name, content, media_type, media_path, buttons, variables, created_by, created_at, updated_at)
+              VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $8) RETURNING id, created_at, updated_at`
+	err := s.db.QueryRowContext(ctx, query,
+		tpl.Name,
+		tpl.Content,
+		tpl.MediaType,
+		tpl.MediaPath,
+		tpl.Buttons,
+		variablesJSON(tpl.Variables),
+		tpl.CreatedBy,
+		time.Now(),
+	).Scan(&tpl.ID, &tpl.CreatedAt, &tpl.UpdatedAt)
+	return duplicatePostTemplateName(err)
 }
 
-func (s *PostgresStorage) GetScheduledPosts(before time.Time) ([]models.Post, error) {
-	query := `SELECT id, content, media_type, media_path, buttons, schedule_time, status, created_by, created_at 
-              FROM posts WHERE schedule_time <= $1 AND status = 'scheduled'`
+func (s *PostgresStorage) UpdatePostTemplate(ctx context.Context, tpl *models.PostTemplate) error {
+	query := `UPDATE post_templates SET name = $1, content = $2, media_type = $3, media_path = NULLIF($4, ''),
+              buttons = $5, variables = $6, updated_at = $7 WHERE id = $8`
+	now := time.Now()
+	err := s.execAffectingOne(ctx, query,
+		tpl.Name,
+		tpl.Content,
+		tpl.MediaType,
+		tpl.MediaPath,
+		tpl.Buttons,
+		variablesJSON(tpl.Variables),
+		now,
+		tpl.ID,
+	)
+	if err == nil {
+		tpl.UpdatedAt = now
+	}
+	return notFoundIfNoRows(duplicatePostTemplateName(err), "post template", tpl.ID)
+}
 
-	rows, err := s.db.Query(query, before)
+func duplicatePostTemplateName(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "post_templates_name_key" {
+		return ErrDuplicateName
+	}
+	return err
+}
+
+func (s *PostgresStorage) DeletePostTemplate(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM post_templates WHERE id = $1`, id)
+	return notFoundIfNoRows(err, "post template", id)
+}
+
+func (s *PostgresStorage) queryTags(ctx context.Context, query string, args ...interface{}) ([]models.Tag, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []models.Post
+	var tags []models.Tag
 	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(
-			&post.ID,
-			&post.Content,
-			&post.MediaType,
-			&post.MediaPath,
-			&post.Buttons,
-			&post.ScheduleTime,
-			&post.Status,
-			&post.CreatedBy,
-			&post.CreatedAt,
-		)
+		tag, err := scanTag(rows)
 		if err != nil {
 			return nil, err
 		}
-		posts = append(posts, post)
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (s *PostgresStorage) GetTags(ctx context.Context) ([]models.Tag, error) {
+	return s.queryTags(ctx, `SELECT `+tagColumns+` FROM tags ORDER BY name`)
+}
+
+func (s *PostgresStorage) GetTag(ctx context.Context, id int) (*models.Tag, error) {
+	tag, err := scanTag(s.db.QueryRowContext(ctx, `SELECT `+tagColumns+` FROM tags WHERE id = $1`, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "tag", id)
+	}
+	return &tag, nil
+}
+
+func (s *PostgresStorage) CreateTag(ctx context.Context, tag *models.Tag) error {
+	query := `INSERT INTO tags (name, hashtag, channel_ids, created_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := s.db.QueryRowContext(ctx, query, tag.Name, tag.Hashtag, tagChannelIDs(tag.ChannelIDs), time.Now()).
+		Scan(&tag.ID, &tag.CreatedAt)
+	return duplicateTagName(err)
+}
+
+func (s *PostgresStorage) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	query := `UPDATE tags SET name = $1, hashtag = $2, channel_ids = $3 WHERE id = $4`
+	err := s.execAffectingOne(ctx, query, tag.Name, tag.Hashtag, tagChannelIDs(tag.ChannelIDs), tag.ID)
+	return notFoundIfNoRows(duplicateTagName(err), "tag", tag.ID)
+}
+
+func duplicateTagName(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "tags_name_key" {
+		return ErrDuplicateName
+	}
+	return err
+}
+
+func (s *PostgresStorage) DeleteTag(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM tags WHERE id = $1`, id)
+	return notFoundIfNoRows(err, "tag", id)
+}
+
+func (s *PostgresStorage) SetPostTags(ctx context.Context, postID int, tagIDs []int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_tags WHERE post_id = $1`, postID); err != nil {
+		return err
+	}
+	for _, tagID := range tagIDs {
+		_, err := tx.ExecContext(ctx, `INSERT INTO post_tags (post_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, postID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStorage) GetPostTags(ctx context.Context, postID int) ([]models.Tag, error) {
+	query := `SELECT ` + tagColumns + ` FROM tags
+              WHERE id IN (SELECT tag_id FROM post_tags WHERE post_id = $1) ORDER BY name`
+	return s.queryTags(ctx, query, postID)
+}
+
+// tagStatisticsQuery считает посты и доставки по тегам; единственный
+// аргумент — начало периода доставок.
+const tagStatisticsQuery = `SELECT t.id, t.name,
+        (SELECT COUNT(*) FROM post_tags pt JOIN posts p ON p.id = pt.post_id
+          WHERE pt.tag_id = t.id AND p.deleted_at IS NULL),
+        (SELECT COUNT(*) FROM post_tags pt JOIN post_channels pc ON pc.post_id = pt.post_id
+          WHERE pt.tag_id = t.id AND pc.status = 'sent' AND pc.sent_at >= $1),
+        (SELECT COUNT(*) FROM post_tags pt JOIN post_channels pc ON pc.post_id = pt.post_id
+          WHERE pt.tag_id = t.id AND pc.status = 'error' AND pc.sent_at >= $1)
+      FROM tags t ORDER BY t.name`
+
+func queryTagStatistics(ctx context.Context, db *sql.DB, query string, since time.Time) ([]models.TagStatistics, error) {
+	rows, err := db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.TagStatistics
+	for rows.Next() {
+		var st models.TagStatistics
+		if err := rows.Scan(&st.TagID, &st.Name, &st.Posts, &st.Successful, &st.Failed); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+
+	return stats, rows.Err()
+}
+
+func (s *PostgresStorage) GetTagStatistics(ctx context.Context, days int) ([]models.TagStatistics, error) {
+	since := time.Time{}
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+	return queryTagStatistics(ctx, s.db, tagStatisticsQuery, since)
+}
+
+const mediaColumns = `id, hash, path, filename, mime_type, size, COALESCE(width, 0), COALESCE(height, 0),
+        COALESCE(duration, 0), COALESCE(uploaded_by, ''), created_at`
+
+func scanMedia(row rowScanner) (models.Media, error) {
+	var media models.Media
+	err := row.Scan(&media.ID, &media.Hash, &media.Path, &media.Filename, &media.MimeType, &media.Size,
+		&media.Width, &media.Height, &media.Duration, &media.UploadedBy, &media.CreatedAt)
+	return media, err
+}
+
+func queryMedia(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]models.Media, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []models.Media
+	for rows.Next() {
+		media, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, media)
+	}
+
+	return files, rows.Err()
+}
+
+// mediaListQuery строит запрос списка медиатеки. like — оператор поиска
+// по имени без учёта регистра.
+func mediaListQuery(filter models.MediaFilter, placeholder func(n int) string, like string) (string, []interface{}) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return placeholder(len(args))
+	}
+
+	conditions := []string{"1 = 1"}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		conditions = append(conditions, "filename "+like+" "+arg("%"+escapeLike(q)+"%")+` ESCAPE '\'`)
+	}
+	if filter.MimePrefix != "" {
+		conditions = append(conditions, "mime_type LIKE "+arg(escapeLike(filter.MimePrefix)+"%")+` ESCAPE '\'`)
+	}
+
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE ` + strings.Join(conditions, " AND ") +
+		` ORDER BY created_at DESC, id DESC LIMIT ` + arg(filter.Limit) + ` OFFSET ` + arg(filter.Offset)
+
+	return query, args
+}
+
+// unreferencedMediaQuery выбирает файлы старше $1, путь которых не
+// встречается ни в постах, ни в ревизиях, ни в шаблонах.
+const unreferencedMediaQuery = `SELECT ` + mediaColumns + ` FROM media m
+      WHERE m.created_at < $1
+        AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.media_path = m.path)
+        AND NOT EXISTS (SELECT 1 FROM post_revisions r WHERE r.media_path = m.path)
+        AND NOT EXISTS (SELECT 1 FROM post_templates t WHERE t.media_path = m.path)
+      ORDER BY m.id`
+
+func (s *PostgresStorage) CreateMedia(ctx context.Context, media *models.Media) error {
+	query := `INSERT INTO media (hash, path, filename, mime_type, size, width, height, duration, uploaded_by, created_at)
+              VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, 0), NULLIF($8, 0), NULLIF($9, ''), $10)
+              RETURNING id, created_at`
+	err := s.db.QueryRowContext(ctx, query, media.Hash, media.Path, media.Filename, media.MimeType, media.Size,
+		media.Width, media.Height, media.Duration, media.UploadedBy, time.Now()).Scan(&media.ID, &media.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "media_hash_key" {
+		return ErrDuplicateKey
 	}
+	return err
+}
+
+func (s *PostgresStorage) GetMedia(ctx context.Context, id int) (*models.Media, error) {
+	media, err := scanMedia(s.db.QueryRowContext(ctx, `SELECT `+mediaColumns+` FROM media WHERE id = $1`, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "media", id)
+	}
+	return &media, nil
+}
+
+func (s *PostgresStorage) GetMediaByHash(ctx context.Context, hash string) (*models.Media, error) {
+	media, err := scanMedia(s.db.QueryRowContext(ctx, `SELECT `+mediaColumns+` FROM media WHERE hash = $1`, hash))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "media", hash)
+	}
+	return &media, nil
+}
 
-	return posts, nil
+func (s *PostgresStorage) ListMedia(ctx context.Context, filter models.MediaFilter) ([]models.Media, error) {
+	placeholder := func(n int) string { return fmt.Sprintf("$%d", n) }
+	query, args := mediaListQuery(filter, placeholder, "ILIKE")
+	return queryMedia(ctx, s.db, query, args...)
 }
 
-func (s *PostgresStorage) CreatePostChannel(pc *models.PostChannel) error {
-	query := `INSERT INTO post_channels (post_id, channel_id, message_id, status, error, sent_at) 
-              VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
-	return s.db.QueryRow(query,
+func (s *PostgresStorage) GetUnreferencedMedia(ctx context.Context, before time.Time) ([]models.Media, error) {
+	return queryMedia(ctx, s.db, unreferencedMediaQuery, before)
+}
+
+func (s *PostgresStorage) DeleteMedia(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM media WHERE id = $1`, id)
+	return notFoundIfNoRows(err, "media", id)
+}
+
+func (s *PostgresStorage) GetDeletedChannels(ctx context.Context) ([]models.Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`
+	return s.queryChannels(ctx, query)
+}
+
+func (s *PostgresStorage) GetDeletedPosts(ctx context.Context) ([]models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`
+	return s.queryPosts(ctx, query)
+}
+
+func (s *PostgresStorage) RestoreChannel(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE channels SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	return notFoundIfNoRows(err, "deleted channel", id)
+}
+
+func (s *PostgresStorage) RestorePost(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	return notFoundIfNoRows(err, "deleted post", id)
+}
+
+func (s *PostgresStorage) PurgeChannel(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM channels WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	return notFoundIfNoRows(err, "deleted channel", id)
+}
+
+func (s *PostgresStorage) PurgePost(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM posts WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	return notFoundIfNoRows(err, "deleted post", id)
+}
+
+func (s *PostgresStorage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	total := 0
+	for _, query := range []string{
+		`DELETE FROM posts WHERE deleted_at < $1`,
+		`DELETE FROM channels WHERE deleted_at < $1`,
+	} {
+		result, err := tx.ExecContext(ctx, query, before)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += int(n)
+	}
+
+	return total, tx.Commit()
+}
+
+func (s *PostgresStorage) CreatePostRevision(ctx context.Context, rev *models.PostRevision) error {
+	query := `INSERT INTO post_revisions (post_id, number, content, media_type, media_path, buttons, schedule_time, author, reason, created_at)
+              SELECT $1, COALESCE(MAX(number), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9 FROM post_revisions WHERE post_id = $1
+              RETURNING id, number, created_at`
+	return s.db.QueryRowContext(ctx, query,
+		rev.PostID,
+		rev.Content,
+		rev.MediaType,
+		rev.MediaPath,
+		rev.Buttons,
+		rev.ScheduleTime,
+		rev.Author,
+		rev.Reason,
+		time.Now(),
+	).Scan(&rev.ID, &rev.Number, &rev.CreatedAt)
+}
+
+func (s *PostgresStorage) GetPostRevision(ctx context.Context, id int) (*models.PostRevision, error) {
+	query := `SELECT ` + postRevisionColumns + ` FROM post_revisions WHERE id = $1`
+	rev, err := scanPostRevision(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "post revision", id)
+	}
+	return &rev, nil
+}
+
+func (s *PostgresStorage) GetPostRevisions(ctx context.Context, postID int) ([]models.PostRevision, error) {
+	query := `SELECT ` + postRevisionColumns + ` FROM post_revisions WHERE post_id = $1 ORDER BY number DESC`
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revs []models.PostRevision
+	for rows.Next() {
+		rev, err := scanPostRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+
+	return revs, rows.Err()
+}
+
+const postChannelColumns = `id, COALESCE(post_id, 0), COALESCE(channel_id, 0), COALESCE(message_id, 0), status, COALESCE(error, ''), sent_at, variant,
+              message_thread_id, comment_status, COALESCE(comment_message_id, 0), COALESCE(comment_error, ''),
+              COALESCE(repost_of, 0), repost_mode`
+
+func scanPostChannel(row rowScanner) (models.PostChannel, error) {
+	var pc models.PostChannel
+	err := row.Scan(
+		&pc.ID,
+		&pc.PostID,
+		&pc.ChannelID,
+		&pc.MessageID,
+		&pc.Status,
+		&pc.Error,
+		&pc.SentAt,
+		&pc.Variant,
+		&pc.MessageThreadID,
+		&pc.CommentStatus,
+		&pc.CommentMessageID,
+		&pc.CommentError,
+		&pc.RepostOf,
+		&pc.RepostMode,
+	)
+	return pc, err
+}
+
+func (s *PostgresStorage) CreatePostChannel(ctx context.Context, pc *models.PostChannel) error {
+	query := `INSERT INTO post_channels (post_id, channel_id, message_id, status, error, sent_at, variant,
+                  message_thread_id, comment_status, comment_message_id, comment_error, repost_of, repost_mode)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, 0), NULLIF($11, ''), NULLIF($12, 0), $13)
+              RETURNING id, sent_at`
+	return s.db.QueryRowContext(ctx, query,
 		pc.PostID,
 		pc.ChannelID,
 		pc.MessageID,
 		pc.Status,
 		pc.Error,
 		time.Now(),
-	).Scan(&pc.ID)
+		pc.Variant,
+		pc.MessageThreadID,
+		pc.CommentStatus,
+		pc.CommentMessageID,
+		pc.CommentError,
+		pc.RepostOf,
+		pc.RepostMode,
+	).Scan(&pc.ID, &pc.SentAt)
+}
+
+func (s *PostgresStorage) GetPostChannel(ctx context.Context, id int) (*models.PostChannel, error) {
+	query := `SELECT ` + postChannelColumns + ` FROM post_channels WHERE id = $1`
+	pc, err := scanPostChannel(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "delivery", id)
+	}
+	return &pc, nil
+}
+
+func (s *PostgresStorage) GetPostChannels(ctx context.Context, filter models.DeliveryFilter) ([]models.PostChannel, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.PostID != 0 {
+		args = append(args, filter.PostID)
+		conditions = append(conditions, fmt.Sprintf("post_id = $%d", len(args)))
+	}
+	if filter.ChannelID != 0 {
+		args = append(args, filter.ChannelID)
+		conditions = append(conditions, fmt.Sprintf("channel_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	query := `SELECT ` + postChannelColumns + ` FROM post_channels`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += " ORDER BY sent_at DESC, id DESC"
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.PostChannel
+	for rows.Next() {
+		pc, err := scanPostChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, pc)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (s *PostgresStorage) GetPendingComment(ctx context.Context, chatID int64, messageID int) (*models.PostChannel, error) {
+	query := `SELECT ` + postChannelColumns + ` FROM post_channels
+              WHERE message_id = $2 AND comment_status = 'pending'
+                AND channel_id IN (SELECT id FROM channels WHERE telegram_id = $1)
+              ORDER BY id DESC LIMIT 1`
+	pc, err := scanPostChannel(s.db.QueryRowContext(ctx, query, chatID, messageID))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "delivery", messageID)
+	}
+	return &pc, nil
+}
+
+func (s *PostgresStorage) UpdatePostChannelComment(ctx context.Context, pc *models.PostChannel) error {
+	query := `UPDATE post_channels SET comment_status = $1, comment_message_id = NULLIF($2, 0), comment_error = NULLIF($3, '')
+              WHERE id = $4`
+	err := s.execAffectingOne(ctx, query, pc.CommentStatus, pc.CommentMessageID, pc.CommentError, pc.ID)
+	return notFoundIfNoRows(err, "delivery", pc.ID)
+}
+
+func (s *PostgresStorage) SaveForumTopic(ctx context.Context, topic *models.ForumTopic) error {
+	query := `INSERT INTO forum_topics (chat_id, thread_id, name, updated_at) VALUES ($1, $2, $3, $4)
+              ON CONFLICT (chat_id, thread_id) DO UPDATE SET name = EXCLUDED.name, updated_at = EXCLUDED.updated_at
+              RETURNING updated_at`
+	return s.db.QueryRowContext(ctx, query, topic.ChatID, topic.ThreadID, topic.Name, time.Now()).Scan(&topic.UpdatedAt)
+}
+
+func (s *PostgresStorage) GetForumTopics(ctx context.Context, chatID int64) ([]models.ForumTopic, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT chat_id, thread_id, name, updated_at FROM forum_topics WHERE chat_id = $1 ORDER BY thread_id`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanForumTopics(rows)
+}
+
+func scanForumTopics(rows *sql.Rows) ([]models.ForumTopic, error) {
+	var topics []models.ForumTopic
+	for rows.Next() {
+		var topic models.ForumTopic
+		if err := rows.Scan(&topic.ChatID, &topic.ThreadID, &topic.Name, &topic.UpdatedAt); err != nil {
+			return nil, err
+		}
+		topics = append(topics, topic)
+	}
+	return topics, rows.Err()
+}
+
+const repostColumns = `id, post_channel_id, channel_id, mode, schedule_time, status, COALESCE(created_by, ''), created_at`
+
+func scanRepost(row rowScanner) (models.Repost, error) {
+	var repost models.Repost
+	err := row.Scan(
+		&repost.ID,
+		&repost.PostChannelID,
+		&repost.ChannelID,
+		&repost.Mode,
+		&repost.ScheduleTime,
+		&repost.Status,
+		&repost.CreatedBy,
+		&repost.CreatedAt,
+	)
+	return repost, err
+}
+
+func queryReposts(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]models.Repost, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reposts []models.Repost
+	for rows.Next() {
+		repost, err := scanRepost(rows)
+		if err != nil {
+			return nil, err
+		}
+		reposts = append(reposts, repost)
+	}
+	return reposts, rows.Err()
+}
+
+func (s *PostgresStorage) CreateRepost(ctx context.Context, repost *models.Repost) error {
+	query := `INSERT INTO reposts (post_channel_id, channel_id, mode, schedule_time, status, created_by, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	return s.db.QueryRowContext(ctx, query,
+		repost.PostChannelID,
+		repost.ChannelID,
+		repost.Mode,
+		repost.ScheduleTime,
+		repost.Status,
+		repost.CreatedBy,
+		time.Now(),
+	).Scan(&repost.ID, &repost.CreatedAt)
+}
+
+func (s *PostgresStorage) GetPostReposts(ctx context.Context, postID int) ([]models.Repost, error) {
+	query := `SELECT ` + repostColumns + ` FROM reposts
+              WHERE post_channel_id IN (SELECT id FROM post_channels WHERE post_id = $1)
+              ORDER BY created_at DESC, id DESC`
+	return queryReposts(ctx, s.db, query, postID)
+}
+
+func (s *PostgresStorage) GetDueReposts(ctx context.Context, before time.Time) ([]models.Repost, error) {
+	query := `SELECT ` + repostColumns + ` FROM reposts
+              WHERE status = 'scheduled' AND schedule_time <= $1 ORDER BY schedule_time, id`
+	return queryReposts(ctx, s.db, query, before)
 }
 
-func (s *PostgresStorage) GetStatistics(days int) (*models.Statistics, error) {
+func (s *PostgresStorage) ClaimRepost(ctx context.Context, id int) (bool, error) {
+	err := s.execAffectingOne(ctx, `UPDATE reposts SET status = 'sending' WHERE id = $1 AND status = 'scheduled'`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *PostgresStorage) UpdateRepostStatus(ctx context.Context, id int, status string) error {
+	err := s.execAffectingOne(ctx, `UPDATE reposts SET status = $1 WHERE id = $2`, status, id)
+	return notFoundIfNoRows(err, "repost", id)
+}
+
+// variantStatisticsQuery сравнивает варианты поста; единственный
+// аргумент — ID поста.
+const variantStatisticsQuery = `SELECT variant, COUNT(DISTINCT channel_id),
+        SUM(CASE WHEN status = 'sent' THEN 1 ELSE 0 END),
+        SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END)
+      FROM post_channels WHERE post_id = $1 GROUP BY variant ORDER BY variant`
+
+func queryVariantStatistics(ctx context.Context, db *sql.DB, postID int) ([]models.VariantStatistics, error) {
+	rows, err := db.QueryContext(ctx, variantStatisticsQuery, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.VariantStatistics
+	for rows.Next() {
+		var st models.VariantStatistics
+		if err := rows.Scan(&st.Variant, &st.Channels, &st.Successful, &st.Failed); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+
+	return stats, rows.Err()
+}
+
+func (s *PostgresStorage) GetVariantStatistics(ctx context.Context, postID int) ([]models.VariantStatistics, error) {
+	return queryVariantStatistics(ctx, s.db, postID)
+}
+
+func (s *PostgresStorage) GetStatistics(ctx context.Context, days int) (*models.Statistics, error) {
 	stats := &models.Statistics{}
 
+	// Доставки учитываются за последние days дней
+	since := time.Time{}
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
 	// Общее количество постов
-	err := s.db.QueryRow("SELECT COUNT(*) FROM posts").Scan(&stats.TotalPosts)
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL").Scan(&stats.TotalPosts)
 	if err != nil {
 		return nil, err
 	}
 
 	// Успешные отправки
-	err = s.db.QueryRow("SELECT COUNT(*) FROM post_channels WHERE status = 'sent'").Scan(&stats.Successful)
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM post_channels WHERE status = 'sent' AND sent_at >= $1", since).Scan(&stats.Successful)
 	if err != nil {
 		return nil, err
 	}
 
 	// Неудачные отправки
-	err = s.db.QueryRow("SELECT COUNT(*) FROM post_channels WHERE status = 'error'").Scan(&stats.Failed)
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM post_channels WHERE status = 'error' AND sent_at >= $1", since).Scan(&stats.Failed)
 	if err != nil {
 		return nil, err
 	}
 
 	// Запланированные посты
-	err = s.db.QueryRow("SELECT COUNT(*) FROM posts WHERE status = 'scheduled'").Scan(&stats.Scheduled)
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM posts WHERE status = 'scheduled' AND deleted_at IS NULL").Scan(&stats.Scheduled)
 	if err != nil {
 		return nil, err
 	}
 
 	// Каналы
-	err = s.db.QueryRow("SELECT COUNT(*) FROM channels").Scan(&stats.TotalChannels)
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM channels WHERE deleted_at IS NULL").Scan(&stats.TotalChannels)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.db.QueryRow("SELECT COUNT(*) FROM channels WHERE is_active = true").Scan(&stats.ActiveChannels)
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM channels WHERE is_active = true AND deleted_at IS NULL").Scan(&stats.ActiveChannels)
 	if err != nil {
 		return nil, err
 	}
 
 	return stats, nil
 }
+
+func (s *PostgresStorage) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	query := `INSERT INTO audit_log (actor, action, target_type, target_id, before, after, ip, created_at)
+              VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0), $5, $6, NULLIF($7, ''), $8) RETURNING id, created_at`
+	return s.db.QueryRowContext(ctx, query,
+		entry.Actor,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		nullJSON(entry.Before),
+		nullJSON(entry.After),
+		entry.IP,
+		time.Now(),
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// nullJSON передаёт пустой снимок как NULL, а не как пустую строку,
+// которую JSONB не примет.
+func nullJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return []byte(raw)
+}
+
+const auditColumns = `id, actor, action, COALESCE(target_type, ''), COALESCE(target_id, 0), before, after, COALESCE(ip, ''), created_at`
+
+func scanAuditEntry(row rowScanner) (models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var before, after []byte
+	err := row.Scan(
+		&entry.ID,
+		&entry.Actor,
+		&entry.Action,
+		&entry.TargetType,
+		&entry.TargetID,
+		&before,
+		&after,
+		&entry.IP,
+		&entry.CreatedAt,
+	)
+	entry.Before = before
+	entry.After = after
+	return entry, err
+}
+
+// auditConditions строит WHERE для фильтра журнала; placeholder
+// возвращает плейсхолдер n-го аргумента в синтаксисе драйвера.
+func auditConditions(filter models.AuditFilter, placeholder func(n int) string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+placeholder(len(args)))
+	}
+
+	if filter.Actor != "" {
+		add("actor = ", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = ", filter.Action)
+	}
+	if filter.TargetType != "" {
+		add("target_type = ", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		add("target_id = ", filter.TargetID)
+	}
+	if filter.From != nil {
+		add("created_at >= ", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < ", *filter.To)
+	}
+	if filter.After != nil {
+		// Плейсхолдеры SQLite позиционные, поэтому время передаётся дважды
+		args = append(args, filter.After.Time, filter.After.Time, filter.After.ID)
+		n := len(args)
+		conditions = append(conditions, fmt.Sprintf("(created_at < %s OR (created_at = %s AND id < %s))",
+			placeholder(n-2), placeholder(n-1), placeholder(n)))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// auditOffset возвращает смещение выдачи журнала: при курсоре оно не
+// используется.
+func auditOffset(filter models.AuditFilter) int {
+	if filter.After != nil {
+		return 0
+	}
+	return filter.Offset
+}
+
+func (s *PostgresStorage) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	where, args := auditConditions(filter, func(n int) string { return fmt.Sprintf("$%d", n) })
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log` + where + " ORDER BY created_at DESC, id DESC"
+	args = append(args, filter.Limit, auditOffset(filter))
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *PostgresStorage) CreateAPIToken(ctx context.Context, token *models.APIToken) error {
+	query := `INSERT INTO api_tokens (name, token_hash, scopes, created_by, created_at)
+              VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	return s.db.QueryRowContext(ctx, query,
+		token.Name,
+		token.TokenHash,
+		pq.Array(token.Scopes),
+		token.CreatedBy,
+		time.Now(),
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+const apiTokenColumns = `id, name, token_hash, scopes, COALESCE(created_by, ''), created_at, last_used_at, revoked_at`
+
+func scanAPIToken(row rowScanner) (models.APIToken, error) {
+	var token models.APIToken
+	err := row.Scan(
+		&token.ID,
+		&token.Name,
+		&token.TokenHash,
+		pq.Array(&token.Scopes),
+		&token.CreatedBy,
+		&token.CreatedAt,
+		&token.LastUsedAt,
+		&token.RevokedAt,
+	)
+	return token, err
+}
+
+func (s *PostgresStorage) GetAPITokenByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE token_hash = $1`
+	token, err := scanAPIToken(s.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "api token", "(hash)")
+	}
+	return &token, nil
+}
+
+func (s *PostgresStorage) GetAPITokens(ctx context.Context) ([]models.APIToken, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (s *PostgresStorage) TouchAPIToken(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE api_tokens SET last_used_at = $1 WHERE id = $2`, time.Now(), id)
+	return notFoundIfNoRows(err, "api token", id)
+}
+
+func (s *PostgresStorage) RevokeAPIToken(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE api_tokens SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`, time.Now(), id)
+	return notFoundIfNoRows(err, "api token", id)
+}
+
+// execAffectingOne выполняет запрос и возвращает sql.ErrNoRows, если он не
+// затронул ни одной строки.
+func (s *PostgresStorage) execAffectingOne(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// notFoundIfNoRows заменяет sql.ErrNoRows на *NotFoundError.
+func notFoundIfNoRows(err error, entity string, key interface{}) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound(entity, key)
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/maksekak/channelBot/cmd/internal/migrate"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+	"github.com/maksekak/channelBot/cmd/internal/storage/storagetest"
+)
+
+// Тесты PostgresStorage запускаются только при заданной TEST_DATABASE_URL.
+// Миграции применяются автоматически, таблицы очищаются перед каждым подтестом.
+func TestPostgresStorage(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	m, err := migrate.Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := storage.NewPostgresStorage(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+
+		_, err = s.DB().Exec(`TRUNCATE audit_log, media, post_templates, post_tags, tags, post_revisions, reposts, post_channels, posts, channels, api_tokens, forum_topics RESTART IDENTITY CASCADE`)
+		if err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+
+		return s
+	})
+}
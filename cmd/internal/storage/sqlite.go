@@ -0,0 +1,1033 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteStorage — реализация Storage поверх SQLite для небольших
+// однонодовых установок. Схема — migrations/sqlite.
+//
+// Все времена сохраняются в UTC: SQLite хранит их строками, и сравнение
+// в запросах корректно только при одинаковом смещении.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+var _ Storage = (*SQLiteStorage)(nil)
+
+// NewSQLiteStorage открывает базу по пути к файлу (или ":memory:").
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	dsn := path + "?_fk=1&_busy_timeout=5000"
+	if path != ":memory:" {
+		dsn += "&_journal_mode=WAL"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite допускает одного писателя; одно соединение также нужно,
+	// чтобы :memory: не создавал отдельную базу на каждое соединение.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	return &SQLiteStorage{db: db}, nil
+}
+
+func (s *SQLiteStorage) DB() *sql.DB {
+	return s.db
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func (s *SQLiteStorage) queryChannels(ctx context.Context, query string, args ...interface{}) ([]models.Channel, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []models.Channel
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+
+	return channels, rows.Err()
+}
+
+func (s *SQLiteStorage) GetChannels(ctx context.Context) ([]models.Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE deleted_at IS NULL ORDER BY created_at DESC, id DESC`
+	return s.queryChannels(ctx, query)
+}
+
+func (s *SQLiteStorage) GetActiveChannels(ctx context.Context) ([]models.Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE is_active = 1 AND deleted_at IS NULL ORDER BY created_at DESC, id DESC`
+	return s.queryChannels(ctx, query)
+}
+
+func (s *SQLiteStorage) GetChannel(ctx context.Context, id int) (*models.Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE id = ? AND deleted_at IS NULL`
+	channel, err := scanChannel(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "channel", id)
+	}
+	return &channel, nil
+}
+
+func (s *SQLiteStorage) CreateChannel(ctx context.Context, channel *models.Channel) error {
+	now := utc(time.Now())
+	query := `INSERT INTO channels (telegram_id, username, title, is_active, timezone, created_at, watermark_path, watermark_position,
+                  footer, buttons, disable_notification, disable_link_preview, protect_content, link_preview_above, link_preview_size,
+                  message_thread_id, discussion_comment)
+              VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''))`
+	id, err := s.insert(ctx, query,
+		channel.TelegramID,
+		channel.Username,
+		channel.Title,
+		channel.IsActive,
+		channel.Timezone,
+		now,
+		channel.WatermarkPath,
+		channel.WatermarkPosition,
+		channel.Footer,
+		jsonText(buttonsJSON(channel.Buttons)),
+		channel.DisableNotification,
+		channel.DisableLinkPreview,
+		channel.ProtectContent,
+		channel.LinkPreviewAbove,
+		channel.LinkPreviewSize,
+		channel.MessageThreadID,
+		channel.DiscussionComment,
+	)
+	if err != nil {
+		return err
+	}
+
+	channel.ID = id
+	channel.CreatedAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) UpdateChannel(ctx context.Context, channel *models.Channel) error {
+	query := `UPDATE channels SET telegram_id = ?, username = ?, title = ?, is_active = ?, timezone = ?,
+              watermark_path = NULLIF(?, ''), watermark_position = NULLIF(?, ''), footer = NULLIF(?, ''), buttons = ?,
+              disable_notification = ?, disable_link_preview = ?, protect_content = ?, link_preview_above = ?,
+              link_preview_size = NULLIF(?, ''), message_thread_id = ?, discussion_comment = NULLIF(?, '')
+              WHERE id = ? AND deleted_at IS NULL`
+	err := s.execAffectingOne(ctx, query,
+		channel.TelegramID,
+		channel.Username,
+		channel.Title,
+		channel.IsActive,
+		channel.Timezone,
+		channel.WatermarkPath,
+		channel.WatermarkPosition,
+		channel.Footer,
+		jsonText(buttonsJSON(channel.Buttons)),
+		channel.DisableNotification,
+		channel.DisableLinkPreview,
+		channel.ProtectContent,
+		channel.LinkPreviewAbove,
+		channel.LinkPreviewSize,
+		channel.MessageThreadID,
+		channel.DiscussionComment,
+		channel.ID,
+	)
+	return notFoundIfNoRows(err, "channel", channel.ID)
+}
+
+func (s *SQLiteStorage) DeleteChannel(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE channels SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, utc(time.Now()), id)
+	return notFoundIfNoRows(err, "channel", id)
+}
+
+func (s *SQLiteStorage) queryPosts(ctx context.Context, query string, args ...interface{}) ([]models.Post, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
+// jsonText сохраняет JSON текстом, а не BLOB, как это сделал бы драйвер
+// для []byte.
+func jsonText(raw json.RawMessage) interface{} {
+	if raw == nil {
+		return nil
+	}
+	return string(raw)
+}
+
+func (s *SQLiteStorage) CreatePost(ctx context.Context, post *models.Post) error {
+	now := utc(time.Now())
+	query := `INSERT INTO posts (content, media_type, media_path, buttons, schedule_time, status, created_by, created_at, idempotency_key, variables, variants,
+                  send_options)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?)`
+	id, err := s.insert(ctx, query,
+		post.Content,
+		post.MediaType,
+		post.MediaPath,
+		jsonText(post.Buttons),
+		utcPtr(post.ScheduleTime),
+		post.Status,
+		post.CreatedBy,
+		now,
+		post.IdempotencyKey,
+		jsonText(variablesJSON(post.Variables)),
+		jsonText(variantsJSON(post.Variants)),
+		jsonText(sendOptionsJSON(post.Options)),
+	)
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.Contains(sqliteErr.Error(), "idempotency_key") {
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		return err
+	}
+
+	post.ID = id
+	post.CreatedAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) GetPost(ctx context.Context, id int) (*models.Post, error) {
+	post, err := scanPost(s.db.QueryRowContext(ctx, `SELECT `+postColumns+` FROM posts WHERE id = ? AND deleted_at IS NULL`, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "post", id)
+	}
+	return &post, nil
+}
+
+func (s *SQLiteStorage) GetPostByIdempotencyKey(ctx context.Context, createdBy, key string) (*models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE created_by = ? AND idempotency_key = ?`
+	post, err := scanPost(s.db.QueryRowContext(ctx, query, createdBy, key))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "post with idempotency key", key)
+	}
+	return &post, nil
+}
+
+func (s *SQLiteStorage) GetPosts(ctx context.Context, limit, offset int) ([]models.Post, error) {
+	return s.ListPosts(ctx, models.PostFilter{Limit: limit, Offset: offset})
+}
+
+func (s *SQLiteStorage) ListPosts(ctx context.Context, filter models.PostFilter) ([]models.Post, error) {
+	filter.From = utcPtr(filter.From)
+	filter.To = utcPtr(filter.To)
+	if filter.After != nil {
+		after := models.PostCursor{Time: utc(filter.After.Time), ID: filter.After.ID}
+		filter.After = &after
+	}
+
+	// Полнотекстового поиска нет: каждое слово ищется подстрокой
+	search := func(query string, arg func(interface{}) string) string {
+		var conditions []string
+		for _, word := range strings.Fields(query) {
+			conditions = append(conditions, `content LIKE `+arg("%"+escapeLike(word)+"%")+` ESCAPE '\'`)
+		}
+		return "(" + strings.Join(conditions, " AND ") + ")"
+	}
+
+	query, args := postListQuery(filter, func(int) string { return "?" }, search)
+	return s.queryPosts(ctx, query, args...)
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (s *SQLiteStorage) GetScheduledPosts(ctx context.Context, before time.Time) ([]models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts
+              WHERE schedule_time <= ? AND status = 'scheduled' AND deleted_at IS NULL ORDER BY schedule_time, id`
+	return s.queryPosts(ctx, query, utc(before))
+}
+
+func (s *SQLiteStorage) UpdatePost(ctx context.Context, post *models.Post) error {
+	return notFoundIfNoRows(s.updatePost(ctx, post, ""), "post", post.ID)
+}
+
+func (s *SQLiteStorage) UpdatePostIfStatus(ctx context.Context, post *models.Post, status string) (bool, error) {
+	err := s.updatePost(ctx, post, " AND status = ?", status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// updatePost записывает поля поста; condition с аргументами extra
+// дополняет условие WHERE.
+func (s *SQLiteStorage) updatePost(ctx context.Context, post *models.Post, condition string, extra ...interface{}) error {
+	query := `UPDATE posts SET content = ?, media_type = ?, media_path = ?, buttons = ?,
+              schedule_time = ?, status = ?, sent_at = ?, variables = ?, variants = ?,
+              send_options = ? WHERE id = ? AND deleted_at IS NULL` + condition
+	args := []interface{}{
+		post.Content,
+		post.MediaType,
+		post.MediaPath,
+		jsonText(post.Buttons),
+		utcPtr(post.ScheduleTime),
+		post.Status,
+		utcPtr(post.SentAt),
+		jsonText(variablesJSON(post.Variables)),
+		jsonText(variantsJSON(post.Variants)),
+		jsonText(sendOptionsJSON(post.Options)),
+		post.ID,
+	}
+	return s.execAffectingOne(ctx, query, append(args, extra...)...)
+}
+
+func (s *SQLiteStorage) ClaimPostForSending(ctx context.Context, id int, idempotencyKey string) (bool, error) {
+	query := `UPDATE posts SET status = 'sending', send_idempotency_key = NULLIF(?, '')
+              WHERE id = ? AND status IN ('draft', 'scheduled') AND deleted_at IS NULL`
+	err := s.execAffectingOne(ctx, query, idempotencyKey, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *SQLiteStorage) DeletePost(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE posts SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, utc(time.Now()), id)
+	return notFoundIfNoRows(err, "post", id)
+}
+
+func (s *SQLiteStorage) queryTags(ctx context.Context, query string, args ...interface{}) ([]models.Tag, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (s *SQLiteStorage) GetTags(ctx context.Context) ([]models.Tag, error) {
+	return s.queryTags(ctx, `SELECT `+tagColumns+` FROM tags ORDER BY name`)
+}
+
+func (s *SQLiteStorage) GetTag(ctx context.Context, id int) (*models.Tag, error) {
+	tag, err := scanTag(s.db.QueryRowContext(ctx, `SELECT `+tagColumns+` FROM tags WHERE id = ?`, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "tag", id)
+	}
+	return &tag, nil
+}
+
+func (s *SQLiteStorage) CreateTag(ctx context.Context, tag *models.Tag) error {
+	now := utc(time.Now())
+	id, err := s.insert(ctx, `INSERT INTO tags (name, hashtag, channel_ids, created_at) VALUES (?, ?, ?, ?)`,
+		tag.Name, tag.Hashtag, jsonText(tagChannelIDs(tag.ChannelIDs)), now)
+	if err != nil {
+		return sqliteDuplicateTagName(err)
+	}
+
+	tag.ID = id
+	tag.CreatedAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	query := `UPDATE tags SET name = ?, hashtag = ?, channel_ids = ? WHERE id = ?`
+	err := s.execAffectingOne(ctx, query, tag.Name, tag.Hashtag, jsonText(tagChannelIDs(tag.ChannelIDs)), tag.ID)
+	return notFoundIfNoRows(sqliteDuplicateTagName(err), "tag", tag.ID)
+}
+
+func (s *SQLiteStorage) GetPostTemplates(ctx context.Context) ([]models.PostTemplate, error) {
+	return queryPostTemplates(ctx, s.db, `SELECT `+postTemplateColumns+` FROM post_templates ORDER BY name`)
+}
+
+func (s *SQLiteStorage) GetPostTemplate(ctx context.Context, id int) (*models.PostTemplate, error) {
+	query := `SELECT ` + postTemplateColumns + ` FROM post_templates WHERE id = ?`
+	tpl, err := scanPostTemplate(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "post template", id)
+	}
+	return &tpl, nil
+}
+
+func (s *SQLiteStorage) CreatePostTemplate(ctx context.Context, tpl *models.PostTemplate) error {
+	now := utc(time.Now())
+	query := `INSERT INTO post_templates (name, content, media_type, media_path, buttons, variables, created_by, created_at, updated_at)
+              VALUES (?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?)`
+	id, err := s.insert(ctx, query,
+		tpl.Name,
+		tpl.Content,
+		tpl.MediaType,
+		tpl.MediaPath,
+		jsonText(tpl.Buttons),
+		jsonText(variablesJSON(tpl.Variables)),
+		tpl.CreatedBy,
+		now,
+		now,
+	)
+	if err != nil {
+		return sqliteDuplicatePostTemplateName(err)
+	}
+
+	tpl.ID = id
+	tpl.CreatedAt = now
+	tpl.UpdatedAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) UpdatePostTemplate(ctx context.Context, tpl *models.PostTemplate) error {
+	query := `UPDATE post_templates SET name = ?, content = ?, media_type = ?, media_path = NULLIF(?, ''),
+              buttons = ?, variables = ?, updated_at = ? WHERE id = ?`
+	now := utc(time.Now())
+	err := s.execAffectingOne(ctx, query,
+		tpl.Name,
+		tpl.Content,
+		tpl.MediaType,
+		tpl.MediaPath,
+		jsonText(tpl.Buttons),
+		jsonText(variablesJSON(tpl.Variables)),
+		now,
+		tpl.ID,
+	)
+	if err == nil {
+		tpl.UpdatedAt = now
+	}
+	return notFoundIfNoRows(sqliteDuplicatePostTemplateName(err), "post template", tpl.ID)
+}
+
+func sqliteDuplicatePostTemplateName(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.Contains(sqliteErr.Error(), "post_templates.name") {
+		return ErrDuplicateName
+	}
+	return err
+}
+
+func (s *SQLiteStorage) DeletePostTemplate(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM post_templates WHERE id = ?`, id)
+	return notFoundIfNoRows(err, "post template", id)
+}
+
+func sqliteDuplicateTagName(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.Contains(sqliteErr.Error(), "tags.name") {
+		return ErrDuplicateName
+	}
+	return err
+}
+
+func (s *SQLiteStorage) DeleteTag(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM tags WHERE id = ?`, id)
+	return notFoundIfNoRows(err, "tag", id)
+}
+
+func (s *SQLiteStorage) SetPostTags(ctx context.Context, postID int, tagIDs []int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_tags WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+	for _, tagID := range tagIDs {
+		_, err := tx.ExecContext(ctx, `INSERT INTO post_tags (post_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING`, postID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) GetPostTags(ctx context.Context, postID int) ([]models.Tag, error) {
+	query := `SELECT ` + tagColumns + ` FROM tags
+              WHERE id IN (SELECT tag_id FROM post_tags WHERE post_id = ?) ORDER BY name`
+	return s.queryTags(ctx, query, postID)
+}
+
+func (s *SQLiteStorage) GetTagStatistics(ctx context.Context, days int) ([]models.TagStatistics, error) {
+	since := time.Time{}
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+	// $1 в SQLite — именованный параметр, повтор ссылается на тот же аргумент
+	return queryTagStatistics(ctx, s.db, tagStatisticsQuery, utc(since))
+}
+
+func (s *SQLiteStorage) CreateMedia(ctx context.Context, media *models.Media) error {
+	now := utc(time.Now())
+	query := `INSERT INTO media (hash, path, filename, mime_type, size, width, height, duration, uploaded_by, created_at)
+              VALUES (?, ?, ?, ?, ?, NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, ''), ?)`
+	id, err := s.insert(ctx, query, media.Hash, media.Path, media.Filename, media.MimeType, media.Size,
+		media.Width, media.Height, media.Duration, media.UploadedBy, now)
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.Contains(sqliteErr.Error(), "media.hash") {
+		return ErrDuplicateKey
+	}
+	if err != nil {
+		return err
+	}
+
+	media.ID = id
+	media.CreatedAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) GetMedia(ctx context.Context, id int) (*models.Media, error) {
+	media, err := scanMedia(s.db.QueryRowContext(ctx, `SELECT `+mediaColumns+` FROM media WHERE id = ?`, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "media", id)
+	}
+	return &media, nil
+}
+
+func (s *SQLiteStorage) GetMediaByHash(ctx context.Context, hash string) (*models.Media, error) {
+	media, err := scanMedia(s.db.QueryRowContext(ctx, `SELECT `+mediaColumns+` FROM media WHERE hash = ?`, hash))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "media", hash)
+	}
+	return &media, nil
+}
+
+func (s *SQLiteStorage) ListMedia(ctx context.Context, filter models.MediaFilter) ([]models.Media, error) {
+	// LIKE в SQLite не учитывает регистр латиницы
+	query, args := mediaListQuery(filter, func(int) string { return "?" }, "LIKE")
+	return queryMedia(ctx, s.db, query, args...)
+}
+
+func (s *SQLiteStorage) GetUnreferencedMedia(ctx context.Context, before time.Time) ([]models.Media, error) {
+	return queryMedia(ctx, s.db, unreferencedMediaQuery, utc(before))
+}
+
+func (s *SQLiteStorage) DeleteMedia(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM media WHERE id = ?`, id)
+	return notFoundIfNoRows(err, "media", id)
+}
+
+func (s *SQLiteStorage) GetDeletedChannels(ctx context.Context) ([]models.Channel, error) {
+	query := `SELECT ` + channelColumns + ` FROM channels WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`
+	return s.queryChannels(ctx, query)
+}
+
+func (s *SQLiteStorage) GetDeletedPosts(ctx context.Context) ([]models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`
+	return s.queryPosts(ctx, query)
+}
+
+func (s *SQLiteStorage) RestoreChannel(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE channels SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	return notFoundIfNoRows(err, "deleted channel", id)
+}
+
+func (s *SQLiteStorage) RestorePost(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE posts SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	return notFoundIfNoRows(err, "deleted post", id)
+}
+
+func (s *SQLiteStorage) PurgeChannel(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM channels WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	return notFoundIfNoRows(err, "deleted channel", id)
+}
+
+func (s *SQLiteStorage) PurgePost(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `DELETE FROM posts WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	return notFoundIfNoRows(err, "deleted post", id)
+}
+
+func (s *SQLiteStorage) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	total := 0
+	for _, query := range []string{
+		`DELETE FROM posts WHERE deleted_at < ?`,
+		`DELETE FROM channels WHERE deleted_at < ?`,
+	} {
+		result, err := tx.ExecContext(ctx, query, utc(before))
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += int(n)
+	}
+
+	return total, tx.Commit()
+}
+
+func (s *SQLiteStorage) CreatePostRevision(ctx context.Context, rev *models.PostRevision) error {
+	now := utc(time.Now())
+	query := `INSERT INTO post_revisions (post_id, number, content, media_type, media_path, buttons, schedule_time, author, reason, created_at)
+              SELECT ?1, COALESCE(MAX(number), 0) + 1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9 FROM post_revisions WHERE post_id = ?1
+              RETURNING id, number`
+	err := s.db.QueryRowContext(ctx, query,
+		rev.PostID,
+		rev.Content,
+		rev.MediaType,
+		rev.MediaPath,
+		jsonText(rev.Buttons),
+		utcPtr(rev.ScheduleTime),
+		rev.Author,
+		rev.Reason,
+		now,
+	).Scan(&rev.ID, &rev.Number)
+	if err != nil {
+		return err
+	}
+
+	rev.CreatedAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) GetPostRevision(ctx context.Context, id int) (*models.PostRevision, error) {
+	query := `SELECT ` + postRevisionColumns + ` FROM post_revisions WHERE id = ?`
+	rev, err := scanPostRevision(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "post revision", id)
+	}
+	return &rev, nil
+}
+
+func (s *SQLiteStorage) GetPostRevisions(ctx context.Context, postID int) ([]models.PostRevision, error) {
+	query := `SELECT ` + postRevisionColumns + ` FROM post_revisions WHERE post_id = ? ORDER BY number DESC`
+	rows, err := s.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revs []models.PostRevision
+	for rows.Next() {
+		rev, err := scanPostRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+
+	return revs, rows.Err()
+}
+
+func (s *SQLiteStorage) CreatePostChannel(ctx context.Context, pc *models.PostChannel) error {
+	now := utc(time.Now())
+	query := `INSERT INTO post_channels (post_id, channel_id, message_id, status, error, sent_at, variant,
+                  message_thread_id, comment_status, comment_message_id, comment_error, repost_of, repost_mode)
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, 0), ?)`
+	id, err := s.insert(ctx, query,
+		pc.PostID,
+		pc.ChannelID,
+		pc.MessageID,
+		pc.Status,
+		pc.Error,
+		now,
+		pc.Variant,
+		pc.MessageThreadID,
+		pc.CommentStatus,
+		pc.CommentMessageID,
+		pc.CommentError,
+		pc.RepostOf,
+		pc.RepostMode,
+	)
+	if err != nil {
+		return err
+	}
+
+	pc.ID = id
+	pc.SentAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) GetPostChannel(ctx context.Context, id int) (*models.PostChannel, error) {
+	query := `SELECT ` + postChannelColumns + ` FROM post_channels WHERE id = ?`
+	pc, err := scanPostChannel(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "delivery", id)
+	}
+	return &pc, nil
+}
+
+func (s *SQLiteStorage) GetPostChannels(ctx context.Context, filter models.DeliveryFilter) ([]models.PostChannel, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.PostID != 0 {
+		conditions = append(conditions, "post_id = ?")
+		args = append(args, filter.PostID)
+	}
+	if filter.ChannelID != 0 {
+		conditions = append(conditions, "channel_id = ?")
+		args = append(args, filter.ChannelID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	query := `SELECT ` + postChannelColumns + ` FROM post_channels`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += " ORDER BY sent_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.PostChannel
+	for rows.Next() {
+		pc, err := scanPostChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, pc)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (s *SQLiteStorage) GetPendingComment(ctx context.Context, chatID int64, messageID int) (*models.PostChannel, error) {
+	query := `SELECT ` + postChannelColumns + ` FROM post_channels
+              WHERE message_id = ? AND comment_status = 'pending'
+                AND channel_id IN (SELECT id FROM channels WHERE telegram_id = ?)
+              ORDER BY id DESC LIMIT 1`
+	pc, err := scanPostChannel(s.db.QueryRowContext(ctx, query, messageID, chatID))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "delivery", messageID)
+	}
+	return &pc, nil
+}
+
+func (s *SQLiteStorage) UpdatePostChannelComment(ctx context.Context, pc *models.PostChannel) error {
+	query := `UPDATE post_channels SET comment_status = ?, comment_message_id = NULLIF(?, 0), comment_error = NULLIF(?, '')
+              WHERE id = ?`
+	err := s.execAffectingOne(ctx, query, pc.CommentStatus, pc.CommentMessageID, pc.CommentError, pc.ID)
+	return notFoundIfNoRows(err, "delivery", pc.ID)
+}
+
+func (s *SQLiteStorage) SaveForumTopic(ctx context.Context, topic *models.ForumTopic) error {
+	now := utc(time.Now())
+	query := `INSERT INTO forum_topics (chat_id, thread_id, name, updated_at) VALUES (?, ?, ?, ?)
+              ON CONFLICT (chat_id, thread_id) DO UPDATE SET name = excluded.name, updated_at = excluded.updated_at`
+	if _, err := s.db.ExecContext(ctx, query, topic.ChatID, topic.ThreadID, topic.Name, now); err != nil {
+		return err
+	}
+	topic.UpdatedAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) GetForumTopics(ctx context.Context, chatID int64) ([]models.ForumTopic, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT chat_id, thread_id, name, updated_at FROM forum_topics WHERE chat_id = ? ORDER BY thread_id`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanForumTopics(rows)
+}
+
+func (s *SQLiteStorage) CreateRepost(ctx context.Context, repost *models.Repost) error {
+	now := utc(time.Now())
+	query := `INSERT INTO reposts (post_channel_id, channel_id, mode, schedule_time, status, created_by, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+	id, err := s.insert(ctx, query,
+		repost.PostChannelID,
+		repost.ChannelID,
+		repost.Mode,
+		utcPtr(repost.ScheduleTime),
+		repost.Status,
+		repost.CreatedBy,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	repost.ID = id
+	repost.CreatedAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) GetPostReposts(ctx context.Context, postID int) ([]models.Repost, error) {
+	query := `SELECT ` + repostColumns + ` FROM reposts
+              WHERE post_channel_id IN (SELECT id FROM post_channels WHERE post_id = ?)
+              ORDER BY created_at DESC, id DESC`
+	return queryReposts(ctx, s.db, query, postID)
+}
+
+func (s *SQLiteStorage) GetDueReposts(ctx context.Context, before time.Time) ([]models.Repost, error) {
+	query := `SELECT ` + repostColumns + ` FROM reposts
+              WHERE status = 'scheduled' AND schedule_time <= ? ORDER BY schedule_time, id`
+	return queryReposts(ctx, s.db, query, utc(before))
+}
+
+func (s *SQLiteStorage) ClaimRepost(ctx context.Context, id int) (bool, error) {
+	err := s.execAffectingOne(ctx, `UPDATE reposts SET status = 'sending' WHERE id = ? AND status = 'scheduled'`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *SQLiteStorage) UpdateRepostStatus(ctx context.Context, id int, status string) error {
+	err := s.execAffectingOne(ctx, `UPDATE reposts SET status = ? WHERE id = ?`, status, id)
+	return notFoundIfNoRows(err, "repost", id)
+}
+
+func (s *SQLiteStorage) GetVariantStatistics(ctx context.Context, postID int) ([]models.VariantStatistics, error) {
+	return queryVariantStatistics(ctx, s.db, postID)
+}
+
+func (s *SQLiteStorage) GetStatistics(ctx context.Context, days int) (*models.Statistics, error) {
+	stats := &models.Statistics{}
+
+	// Доставки учитываются за последние days дней
+	since := time.Time{}
+	if days > 0 {
+		since = time.Now().AddDate(0, 0, -days)
+	}
+
+	query := `SELECT
+        (SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL),
+        (SELECT COUNT(*) FROM post_channels WHERE status = 'sent' AND sent_at >= ?),
+        (SELECT COUNT(*) FROM post_channels WHERE status = 'error' AND sent_at >= ?),
+        (SELECT COUNT(*) FROM posts WHERE status = 'scheduled' AND deleted_at IS NULL),
+        (SELECT COUNT(*) FROM channels WHERE deleted_at IS NULL),
+        (SELECT COUNT(*) FROM channels WHERE is_active = 1 AND deleted_at IS NULL)`
+
+	err := s.db.QueryRowContext(ctx, query, utc(since), utc(since)).Scan(
+		&stats.TotalPosts,
+		&stats.Successful,
+		&stats.Failed,
+		&stats.Scheduled,
+		&stats.TotalChannels,
+		&stats.ActiveChannels,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (s *SQLiteStorage) CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	now := utc(time.Now())
+	query := `INSERT INTO audit_log (actor, action, target_type, target_id, before, after, ip, created_at)
+              VALUES (?, ?, NULLIF(?, ''), NULLIF(?, 0), ?, ?, NULLIF(?, ''), ?)`
+	id, err := s.insert(ctx, query,
+		entry.Actor,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		jsonText(entry.Before),
+		jsonText(entry.After),
+		entry.IP,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.ID = id
+	entry.CreatedAt = now
+	return nil
+}
+
+func (s *SQLiteStorage) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	filter.From = utcPtr(filter.From)
+	filter.To = utcPtr(filter.To)
+	if filter.After != nil {
+		after := models.PostCursor{Time: utc(filter.After.Time), ID: filter.After.ID}
+		filter.After = &after
+	}
+	where, args := auditConditions(filter, func(int) string { return "?" })
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log` + where + " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, auditOffset(filter))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *SQLiteStorage) CreateAPIToken(ctx context.Context, token *models.APIToken) error {
+	scopes, err := json.Marshal(token.Scopes)
+	if err != nil {
+		return err
+	}
+
+	now := utc(time.Now())
+	query := `INSERT INTO api_tokens (name, token_hash, scopes, created_by, created_at) VALUES (?, ?, ?, ?, ?)`
+	id, err := s.insert(ctx, query, token.Name, token.TokenHash, string(scopes), token.CreatedBy, now)
+	if err != nil {
+		return err
+	}
+
+	token.ID = id
+	token.CreatedAt = now
+	return nil
+}
+
+const sqliteAPITokenColumns = `id, name, token_hash, scopes, COALESCE(created_by, ''), created_at, last_used_at, revoked_at`
+
+func scanSQLiteAPIToken(row rowScanner) (models.APIToken, error) {
+	var token models.APIToken
+	var scopes string
+	err := row.Scan(
+		&token.ID,
+		&token.Name,
+		&token.TokenHash,
+		&scopes,
+		&token.CreatedBy,
+		&token.CreatedAt,
+		&token.LastUsedAt,
+		&token.RevokedAt,
+	)
+	if err != nil {
+		return token, err
+	}
+	return token, json.Unmarshal([]byte(scopes), &token.Scopes)
+}
+
+func (s *SQLiteStorage) GetAPITokenByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	query := `SELECT ` + sqliteAPITokenColumns + ` FROM api_tokens WHERE token_hash = ?`
+	token, err := scanSQLiteAPIToken(s.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		return nil, notFoundIfNoRows(err, "api token", "(hash)")
+	}
+	return &token, nil
+}
+
+func (s *SQLiteStorage) GetAPITokens(ctx context.Context) ([]models.APIToken, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sqliteAPITokenColumns+` FROM api_tokens ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.APIToken
+	for rows.Next() {
+		token, err := scanSQLiteAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (s *SQLiteStorage) TouchAPIToken(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, utc(time.Now()), id)
+	return notFoundIfNoRows(err, "api token", id)
+}
+
+func (s *SQLiteStorage) RevokeAPIToken(ctx context.Context, id int) error {
+	err := s.execAffectingOne(ctx, `UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, utc(time.Now()), id)
+	return notFoundIfNoRows(err, "api token", id)
+}
+
+// insert выполняет INSERT и возвращает ID новой строки.
+func (s *SQLiteStorage) insert(ctx context.Context, query string, args ...interface{}) (int, error) {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// execAffectingOne выполняет запрос и возвращает sql.ErrNoRows, если он не
+// затронул ни одной строки.
+func (s *SQLiteStorage) execAffectingOne(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+// Storage — хранилище каналов, постов, доставок и статистики.
+//
+// Методы получения одной записи возвращают *NotFoundError (errors.Is(err,
+// ErrNotFound) == true), если запись не найдена. Обновление и удаление
+// несуществующей записи возвращают ту же ошибку.
+//
+// Удаление каналов и постов мягкое: запись попадает в корзину и больше не
+// видна обычным методам, а её доставки остаются в статистике.
+type Storage interface {
+	// Каналы. Списки отсортированы от новых к старым.
+	GetChannels(ctx context.Context) ([]models.Channel, error)
+	GetActiveChannels(ctx context.Context) ([]models.Channel, error)
+	GetChannel(ctx context.Context, id int) (*models.Channel, error)
+	CreateChannel(ctx context.Context, channel *models.Channel) error
+	UpdateChannel(ctx context.Context, channel *models.Channel) error
+	DeleteChannel(ctx context.Context, id int) error
+
+	// Посты. Списки отсортированы от новых к старым.
+	//
+	// CreatePost возвращает ErrDuplicateKey, если у автора уже есть пост
+	// с тем же IdempotencyKey.
+	CreatePost(ctx context.Context, post *models.Post) error
+	GetPost(ctx context.Context, id int) (*models.Post, error)
+	GetPostByIdempotencyKey(ctx context.Context, createdBy, key string) (*models.Post, error)
+	GetPosts(ctx context.Context, limit, offset int) ([]models.Post, error)
+	ListPosts(ctx context.Context, filter models.PostFilter) ([]models.Post, error)
+	// GetScheduledPosts возвращает запланированные посты со временем
+	// публикации не позже before, в порядке этого времени.
+	GetScheduledPosts(ctx context.Context, before time.Time) ([]models.Post, error)
+	UpdatePost(ctx context.Context, post *models.Post) error
+	// UpdatePostIfStatus сохраняет пост, только если его статус в базе всё
+	// ещё status. Возвращает false, если статус успел измениться.
+	UpdatePostIfStatus(ctx context.Context, post *models.Post, status string) (bool, error)
+	// ClaimPostForSending атомарно переводит черновик или запланированный
+	// пост в статус sending. Возвращает false, если пост уже отправляется
+	// или отправлен.
+	ClaimPostForSending(ctx context.Context, id int, idempotencyKey string) (bool, error)
+	DeletePost(ctx context.Context, id int) error
+
+	// Ревизии постов. CreatePostRevision присваивает следующий номер
+	// ревизии поста; GetPostRevisions возвращает ревизии от новых к старым.
+	CreatePostRevision(ctx context.Context, rev *models.PostRevision) error
+	GetPostRevision(ctx context.Context, id int) (*models.PostRevision, error)
+	GetPostRevisions(ctx context.Context, postID int) ([]models.PostRevision, error)
+
+	// Теги. GetTags сортирует по имени; CreateTag и UpdateTag возвращают
+	// ErrDuplicateName, если тег с таким именем уже есть. Удаление тега
+	// снимает его с постов.
+	GetTags(ctx context.Context) ([]models.Tag, error)
+	GetTag(ctx context.Context, id int) (*models.Tag, error)
+	CreateTag(ctx context.Context, tag *models.Tag) error
+	UpdateTag(ctx context.Context, tag *models.Tag) error
+	DeleteTag(ctx context.Context, id int) error
+	// SetPostTags заменяет теги поста; GetPostTags сортирует их по имени.
+	SetPostTags(ctx context.Context, postID int, tagIDs []int) error
+	GetPostTags(ctx context.Context, postID int) ([]models.Tag, error)
+	// GetTagStatistics считает посты и доставки по тегам; доставки — за
+	// последние days дней, как GetStatistics. Теги поста удаляются вместе с
+	// ним, поэтому учитываются только существующие посты, в том числе в
+	// корзине: доставки окончательно удалённых постов остаются лишь в
+	// GetStatistics.
+	GetTagStatistics(ctx context.Context, days int) ([]models.TagStatistics, error)
+
+	// Шаблоны постов. GetPostTemplates сортирует по имени; создание и
+	// изменение возвращают ErrDuplicateName при занятом имени.
+	GetPostTemplates(ctx context.Context) ([]models.PostTemplate, error)
+	GetPostTemplate(ctx context.Context, id int) (*models.PostTemplate, error)
+	CreatePostTemplate(ctx context.Context, tpl *models.PostTemplate) error
+	UpdatePostTemplate(ctx context.Context, tpl *models.PostTemplate) error
+	DeletePostTemplate(ctx context.Context, id int) error
+
+	// Медиатека. CreateMedia возвращает ErrDuplicateKey, если файл с тем
+	// же хэшем уже есть; ListMedia сортирует от новых к старым.
+	CreateMedia(ctx context.Context, media *models.Media) error
+	GetMedia(ctx context.Context, id int) (*models.Media, error)
+	GetMediaByHash(ctx context.Context, hash string) (*models.Media, error)
+	ListMedia(ctx context.Context, filter models.MediaFilter) ([]models.Media, error)
+	// GetUnreferencedMedia возвращает файлы, загруженные раньше before, на
+	// которые не ссылается ни один пост (в том числе в корзине), ревизия
+	// или шаблон.
+	GetUnreferencedMedia(ctx context.Context, before time.Time) ([]models.Media, error)
+	DeleteMedia(ctx context.Context, id int) error
+
+	// Корзина. Списки отсортированы по времени удаления, от новых к старым.
+	// Restore и Purge работают только с удалёнными записями; Purge удаляет
+	// запись окончательно, сохраняя её доставки.
+	GetDeletedChannels(ctx context.Context) ([]models.Channel, error)
+	GetDeletedPosts(ctx context.Context) ([]models.Post, error)
+	RestoreChannel(ctx context.Context, id int) error
+	RestorePost(ctx context.Context, id int) error
+	PurgeChannel(ctx context.Context, id int) error
+	PurgePost(ctx context.Context, id int) error
+	// PurgeDeleted окончательно удаляет записи, удалённые раньше before,
+	// и возвращает их число.
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
+
+	// Доставки поста в каналы. Списки отсортированы от новых к старым.
+	CreatePostChannel(ctx context.Context, pc *models.PostChannel) error
+	GetPostChannel(ctx context.Context, id int) (*models.PostChannel, error)
+	GetPostChannels(ctx context.Context, filter models.DeliveryFilter) ([]models.PostChannel, error)
+	// GetPendingComment находит доставку с ожидающим комментарием по чату
+	// канала в Telegram и ID сообщения в нём.
+	GetPendingComment(ctx context.Context, chatID int64, messageID int) (*models.PostChannel, error)
+	// UpdatePostChannelComment сохраняет состояние комментария доставки.
+	UpdatePostChannelComment(ctx context.Context, pc *models.PostChannel) error
+
+	// Повторы доставленных сообщений в других каналах. GetPostReposts
+	// возвращает повторы доставок поста от новых к старым, GetDueReposts —
+	// запланированные не позже before в порядке времени. ClaimRepost
+	// переводит запланированный повтор в "sending" и сообщает, удалось ли.
+	CreateRepost(ctx context.Context, repost *models.Repost) error
+	GetPostReposts(ctx context.Context, postID int) ([]models.Repost, error)
+	GetDueReposts(ctx context.Context, before time.Time) ([]models.Repost, error)
+	ClaimRepost(ctx context.Context, id int) (bool, error)
+	UpdateRepostStatus(ctx context.Context, id int, status string) error
+
+	// Темы форумов. SaveForumTopic добавляет тему или обновляет её
+	// название; GetForumTopics возвращает темы чата в порядке ID темы.
+	SaveForumTopic(ctx context.Context, topic *models.ForumTopic) error
+	GetForumTopics(ctx context.Context, chatID int64) ([]models.ForumTopic, error)
+
+	// GetStatistics считает доставки за последние days дней (все, если
+	// days <= 0); остальные счётчики — по текущему состоянию.
+	GetStatistics(ctx context.Context, days int) (*models.Statistics, error)
+	// GetVariantStatistics сравнивает варианты поста по доставкам,
+	// в порядке имени варианта; основной текст — вариант с пустым именем.
+	GetVariantStatistics(ctx context.Context, postID int) ([]models.VariantStatistics, error)
+
+	// Журнал действий только дополняется. ListAuditEntries возвращает
+	// записи от новых к старым; From включительно, To — исключительно.
+	CreateAuditEntry(ctx context.Context, entry *models.AuditEntry) error
+	ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+
+	// Персональные API-токены.
+	CreateAPIToken(ctx context.Context, token *models.APIToken) error
+	GetAPITokenByHash(ctx context.Context, hash string) (*models.APIToken, error)
+	GetAPITokens(ctx context.Context) ([]models.APIToken, error)
+	TouchAPIToken(ctx context.Context, id int) error
+	RevokeAPIToken(ctx context.Context, id int) error
+}
+
+// Open выбирает реализацию по схеме DATABASE_URL:
+// postgres://… и postgresql://… — PostgreSQL, sqlite://путь и sqlite::memory: — SQLite.
+func Open(databaseURL string) (Storage, error) {
+	switch {
+	case strings.HasPrefix(databaseURL, "postgres://"), strings.HasPrefix(databaseURL, "postgresql://"):
+		return NewPostgresStorage(databaseURL)
+	case strings.HasPrefix(databaseURL, "sqlite:"):
+		return NewSQLiteStorage(SQLitePath(databaseURL))
+	default:
+		return nil, fmt.Errorf("storage: unsupported DATABASE_URL scheme in %q", databaseURL)
+	}
+}
+
+// SQLitePath извлекает путь к файлу из sqlite:// URL:
+// sqlite:///var/lib/bot.db → /var/lib/bot.db, sqlite://bot.db → bot.db.
+func SQLitePath(databaseURL string) string {
+	path := strings.TrimPrefix(databaseURL, "sqlite:")
+	return strings.TrimPrefix(path, "//")
+}
@@ -0,0 +1,1606 @@
+// Package storagetest содержит общий набор тестов, который обязана проходить
+// каждая реализация storage.Storage.
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// Run прогоняет набор тестов. newStorage должна возвращать пустое хранилище
+// для каждого подтеста.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"Channels", testChannels},
+		{"ChannelNotFound", testChannelNotFound},
+		{"Posts", testPosts},
+		{"PostNotFound", testPostNotFound},
+		{"ListPosts", testListPosts},
+		{"FilterPosts", testFilterPosts},
+		{"PostCursor", testPostCursor},
+		{"ScheduledPosts", testScheduledPosts},
+		{"Tags", testTags},
+		{"PostTemplates", testPostTemplates},
+		{"Media", testMedia},
+		{"IdempotencyKey", testIdempotencyKey},
+		{"ClaimPostForSending", testClaimPostForSending},
+		{"UpdatePostIfStatus", testUpdatePostIfStatus},
+		{"PostRevisions", testPostRevisions},
+		{"Deliveries", testDeliveries},
+		{"SoftDelete", testSoftDelete},
+		{"Purge", testPurge},
+		{"Statistics", testStatistics},
+		{"VariantStatistics", testVariantStatistics},
+		{"DiscussionComments", testDiscussionComments},
+		{"ForumTopics", testForumTopics},
+		{"Reposts", testReposts},
+		{"APITokens", testAPITokens},
+		{"AuditLog", testAuditLog},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+func createChannel(t *testing.T, s storage.Storage, title string, active bool) models.Channel {
+	t.Helper()
+
+	channel := models.Channel{
+		TelegramID: -100123,
+		Username:   "@" + title,
+		Title:      title,
+		IsActive:   active,
+		Timezone:   "Europe/Moscow",
+	}
+	if err := s.CreateChannel(context.Background(), &channel); err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+	if channel.ID == 0 {
+		t.Fatal("CreateChannel did not set ID")
+	}
+	return channel
+}
+
+func createPost(t *testing.T, s storage.Storage, content, status string) models.Post {
+	t.Helper()
+
+	post := models.Post{
+		Content:   content,
+		MediaType: "text",
+		Buttons:   json.RawMessage(`[{"text":"Open","url":"https://example.com"}]`),
+		Status:    status,
+		CreatedBy: "admin",
+	}
+	if err := s.CreatePost(context.Background(), &post); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if post.ID == 0 {
+		t.Fatal("CreatePost did not set ID")
+	}
+	return post
+}
+
+func createDelivery(t *testing.T, s storage.Storage, postID, channelID int, status string) models.PostChannel {
+	t.Helper()
+
+	pc := models.PostChannel{PostID: postID, ChannelID: channelID, MessageID: 42, Status: status}
+	if status == "error" {
+		pc.MessageID = 0
+		pc.Error = "chat not found"
+	}
+	if err := s.CreatePostChannel(context.Background(), &pc); err != nil {
+		t.Fatalf("CreatePostChannel: %v", err)
+	}
+	return pc
+}
+
+func channelIDs(channels []models.Channel) []int {
+	ids := make([]int, len(channels))
+	for i, c := range channels {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+func postIDs(posts []models.Post) []int {
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func assertNotFound(t *testing.T, op string, err error) {
+	t.Helper()
+
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("%s: got %v, want ErrNotFound", op, err)
+	}
+	var nf *storage.NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("%s: got %T, want *storage.NotFoundError", op, err)
+	}
+}
+
+func testChannels(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	first := createChannel(t, s, "first", true)
+	second := createChannel(t, s, "second", false)
+	third := createChannel(t, s, "third", true)
+
+	got, err := s.GetChannel(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("GetChannel: %v", err)
+	}
+	if got.Title != "second" || got.IsActive || got.TelegramID != -100123 || got.Timezone != "Europe/Moscow" {
+		t.Errorf("GetChannel = %+v", got)
+	}
+	if got.CreatedAt.IsZero() {
+		t.Error("GetChannel: CreatedAt is zero")
+	}
+
+	all, err := s.GetChannels(ctx)
+	if err != nil {
+		t.Fatalf("GetChannels: %v", err)
+	}
+	if want := []int{third.ID, second.ID, first.ID}; !equalIDs(channelIDs(all), want) {
+		t.Errorf("GetChannels order = %v, want %v", channelIDs(all), want)
+	}
+
+	active, err := s.GetActiveChannels(ctx)
+	if err != nil {
+		t.Fatalf("GetActiveChannels: %v", err)
+	}
+	if want := []int{third.ID, first.ID}; !equalIDs(channelIDs(active), want) {
+		t.Errorf("GetActiveChannels = %v, want %v", channelIDs(active), want)
+	}
+
+	second.IsActive = true
+	second.Title = "renamed"
+	second.WatermarkPath = "watermarks/logo.png"
+	second.WatermarkPosition = "top-left"
+	if err := s.UpdateChannel(ctx, &second); err != nil {
+		t.Fatalf("UpdateChannel: %v", err)
+	}
+	got, _ = s.GetChannel(ctx, second.ID)
+	if got.Title != "renamed" || !got.IsActive || got.WatermarkPath != "watermarks/logo.png" || got.WatermarkPosition != "top-left" {
+		t.Errorf("after UpdateChannel = %+v", got)
+	}
+
+	second.WatermarkPath = ""
+	second.WatermarkPosition = ""
+	second.Footer = `<a href="https://t.me/second">Subscribe</a>`
+	second.Buttons = json.RawMessage(`[{"text":"Subscribe","url":"https://t.me/second"}]`)
+	second.DisableNotification = true
+	second.DisableLinkPreview = true
+	second.ProtectContent = true
+	second.LinkPreviewAbove = true
+	second.LinkPreviewSize = models.LinkPreviewLarge
+	second.MessageThreadID = 42
+	second.DiscussionComment = "Discuss here"
+	if err := s.UpdateChannel(ctx, &second); err != nil {
+		t.Fatalf("UpdateChannel(no watermark): %v", err)
+	}
+	got, _ = s.GetChannel(ctx, second.ID)
+	if got.WatermarkPath != "" || got.WatermarkPosition != "" {
+		t.Errorf("after removing watermark = %+v", got)
+	}
+	var buttons []models.Button
+	json.Unmarshal(got.Buttons, &buttons)
+	if got.Footer != second.Footer || len(buttons) != 1 || !got.DisableNotification || !got.DisableLinkPreview ||
+		!got.ProtectContent || !got.LinkPreviewAbove || got.LinkPreviewSize != models.LinkPreviewLarge ||
+		got.MessageThreadID != 42 || got.DiscussionComment != "Discuss here" {
+		t.Errorf("channel defaults = %+v", got)
+	}
+
+	second.Footer = ""
+	second.Buttons = nil
+	second.DisableNotification = false
+	second.LinkPreviewSize = ""
+	if err := s.UpdateChannel(ctx, &second); err != nil {
+		t.Fatalf("UpdateChannel(no defaults): %v", err)
+	}
+	got, _ = s.GetChannel(ctx, second.ID)
+	if got.Footer != "" || len(got.Buttons) != 0 || got.DisableNotification || !got.DisableLinkPreview || got.LinkPreviewSize != "" {
+		t.Errorf("after clearing channel defaults = %+v", got)
+	}
+
+	if err := s.DeleteChannel(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteChannel: %v", err)
+	}
+	all, _ = s.GetChannels(ctx)
+	if want := []int{third.ID, second.ID}; !equalIDs(channelIDs(all), want) {
+		t.Errorf("after DeleteChannel = %v, want %v", channelIDs(all), want)
+	}
+}
+
+func testChannelNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.GetChannel(ctx, 999)
+	assertNotFound(t, "GetChannel", err)
+
+	err = s.UpdateChannel(ctx, &models.Channel{ID: 999, Title: "x"})
+	assertNotFound(t, "UpdateChannel", err)
+
+	err = s.DeleteChannel(ctx, 999)
+	assertNotFound(t, "DeleteChannel", err)
+}
+
+func testPosts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	post := createPost(t, s, "hello", "draft")
+
+	got, err := s.GetPost(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	if got.Content != "hello" || got.MediaType != "text" || got.Status != "draft" || got.CreatedBy != "admin" {
+		t.Errorf("GetPost = %+v", got)
+	}
+	if got.CreatedAt.IsZero() || got.SentAt != nil || got.ScheduleTime != nil {
+		t.Errorf("GetPost times = created %v, sent %v, schedule %v", got.CreatedAt, got.SentAt, got.ScheduleTime)
+	}
+
+	var buttons []models.Button
+	if err := json.Unmarshal(got.Buttons, &buttons); err != nil {
+		t.Fatalf("unmarshal buttons: %v", err)
+	}
+	if len(buttons) != 1 || buttons[0].Text != "Open" || buttons[0].URL != "https://example.com" {
+		t.Errorf("buttons = %+v", buttons)
+	}
+
+	sentAt := time.Now().Truncate(time.Second)
+	got.Content = "edited"
+	got.Status = "sent"
+	got.SentAt = &sentAt
+	got.MediaPath = "web/assets/uploads/a.jpg"
+	got.Variables = map[string]string{"price": "100"}
+	silent := true
+	got.Options = models.SendOptions{
+		DisableNotification: &silent,
+		ProtectContent:      &silent,
+		LinkPreviewURL:      "https://example.com/preview",
+		LinkPreviewSize:     models.LinkPreviewSmall,
+		NoFooter:            true,
+	}
+	got.Variants = []models.PostVariant{
+		{Name: "A", Content: "short", ChannelIDs: []int{1, 2}},
+		{Name: "B", Content: "long", Buttons: json.RawMessage(`[{"text":"Buy","url":"https://example.com/b"}]`)},
+	}
+	if err := s.UpdatePost(ctx, got); err != nil {
+		t.Fatalf("UpdatePost: %v", err)
+	}
+
+	got, _ = s.GetPost(ctx, post.ID)
+	if got.Content != "edited" || got.Status != "sent" || got.MediaPath != "web/assets/uploads/a.jpg" {
+		t.Errorf("after UpdatePost = %+v", got)
+	}
+	if len(got.Variables) != 1 || got.Variables["price"] != "100" {
+		t.Errorf("after UpdatePost Variables = %v", got.Variables)
+	}
+	if got.Options.DisableNotification == nil || !*got.Options.DisableNotification ||
+		got.Options.DisableLinkPreview != nil || !got.Options.NoFooter || got.Options.NoChannelButtons ||
+		got.Options.ProtectContent == nil || got.Options.LinkPreviewAbove != nil ||
+		got.Options.LinkPreviewURL != "https://example.com/preview" || got.Options.LinkPreviewSize != models.LinkPreviewSmall {
+		t.Errorf("after UpdatePost Options = %+v", got.Options)
+	}
+	if len(got.Variants) != 2 || got.Variants[0].Name != "A" || len(got.Variants[0].ChannelIDs) != 2 ||
+		got.Variants[1].Content != "long" || len(got.Variants[1].Buttons) == 0 {
+		t.Errorf("after UpdatePost Variants = %+v", got.Variants)
+	}
+	if got.SentAt == nil || !got.SentAt.Equal(sentAt) {
+		t.Errorf("after UpdatePost SentAt = %v, want %v", got.SentAt, sentAt)
+	}
+
+	if err := s.DeletePost(ctx, post.ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	_, err = s.GetPost(ctx, post.ID)
+	assertNotFound(t, "GetPost after DeletePost", err)
+}
+
+func testPostNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.GetPost(ctx, 999)
+	assertNotFound(t, "GetPost", err)
+
+	err = s.UpdatePost(ctx, &models.Post{ID: 999, MediaType: "text", Status: "draft"})
+	assertNotFound(t, "UpdatePost", err)
+
+	err = s.DeletePost(ctx, 999)
+	assertNotFound(t, "DeletePost", err)
+
+	_, err = s.GetPostByIdempotencyKey(ctx, "admin", "missing")
+	assertNotFound(t, "GetPostByIdempotencyKey", err)
+}
+
+func testListPosts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	p1 := createPost(t, s, "one", "draft")
+	p2 := createPost(t, s, "two", "sent")
+	p3 := createPost(t, s, "three", "draft")
+	p4 := createPost(t, s, "four", "scheduled")
+
+	posts, err := s.GetPosts(ctx, 10, 0)
+	if err != nil {
+		t.Fatalf("GetPosts: %v", err)
+	}
+	if want := []int{p4.ID, p3.ID, p2.ID, p1.ID}; !equalIDs(postIDs(posts), want) {
+		t.Errorf("GetPosts = %v, want %v", postIDs(posts), want)
+	}
+
+	posts, _ = s.GetPosts(ctx, 2, 1)
+	if want := []int{p3.ID, p2.ID}; !equalIDs(postIDs(posts), want) {
+		t.Errorf("GetPosts(2, 1) = %v, want %v", postIDs(posts), want)
+	}
+
+	posts, _ = s.GetPosts(ctx, 10, 10)
+	if len(posts) != 0 {
+		t.Errorf("GetPosts past the end = %v, want empty", postIDs(posts))
+	}
+
+	posts, err = s.ListPosts(ctx, models.PostFilter{Status: "draft", Limit: 10})
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	if want := []int{p3.ID, p1.ID}; !equalIDs(postIDs(posts), want) {
+		t.Errorf("ListPosts(draft) = %v, want %v", postIDs(posts), want)
+	}
+}
+
+func testFilterPosts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	create := func(content, author, mediaType string, scheduleTime *time.Time) models.Post {
+		post := models.Post{Content: content, MediaType: mediaType, Status: "draft", CreatedBy: author, ScheduleTime: scheduleTime}
+		if scheduleTime != nil {
+			post.Status = "scheduled"
+		}
+		if err := s.CreatePost(ctx, &post); err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		return post
+	}
+
+	soon, later := now.Add(time.Hour), now.Add(48*time.Hour)
+	news := create("Свежие новости недели", "alice", "text", &later)
+	digest := create("Weekly digest with release notes", "bob", "photo", &soon)
+	release := create("Release candidate is out", "alice", "video", nil)
+
+	channel := createChannel(t, s, "news", true)
+	createDelivery(t, s, digest.ID, channel.ID, "sent")
+
+	tests := []struct {
+		name   string
+		filter models.PostFilter
+		want   []int
+	}{
+		{"author", models.PostFilter{Author: "alice"}, []int{release.ID, news.ID}},
+		{"media type", models.PostFilter{MediaType: "photo"}, []int{digest.ID}},
+		{"channel", models.PostFilter{ChannelID: channel.ID}, []int{digest.ID}},
+		{"search", models.PostFilter{Query: "release"}, []int{release.ID, digest.ID}},
+		{"search all words", models.PostFilter{Query: "release notes"}, []int{digest.ID}},
+		{"search russian", models.PostFilter{Query: "новости"}, []int{news.ID}},
+		{"sort scheduled", models.PostFilter{Sort: models.PostSortScheduled}, []int{news.ID, digest.ID}},
+		{"sort scheduled asc", models.PostFilter{Sort: models.PostSortScheduled, Asc: true}, []int{digest.ID, news.ID}},
+		{"sort sent", models.PostFilter{Sort: models.PostSortSent}, nil},
+		{"range", models.PostFilter{Sort: models.PostSortScheduled, From: &now, To: &later}, []int{digest.ID}},
+		{"combined", models.PostFilter{Author: "alice", Query: "release", MediaType: "video"}, []int{release.ID}},
+	}
+
+	for _, tt := range tests {
+		tt.filter.Limit = 10
+		posts, err := s.ListPosts(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("ListPosts(%s): %v", tt.name, err)
+		}
+		if !equalIDs(postIDs(posts), tt.want) {
+			t.Errorf("ListPosts(%s) = %v, want %v", tt.name, postIDs(posts), tt.want)
+		}
+	}
+}
+
+func testPostCursor(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	var ids []int
+	for _, content := range []string{"p1", "p2", "p3", "p4", "p5"} {
+		ids = append(ids, createPost(t, s, content, "draft").ID)
+	}
+
+	collect := func(asc bool) []int {
+		var got []int
+		filter := models.PostFilter{Asc: asc, Limit: 2}
+		for i := 0; i < 5; i++ {
+			posts, err := s.ListPosts(ctx, filter)
+			if err != nil {
+				t.Fatalf("ListPosts: %v", err)
+			}
+			got = append(got, postIDs(posts)...)
+			if len(posts) < filter.Limit {
+				return got
+			}
+
+			cursor, err := storage.ParsePostCursor(storage.NextPostCursor(posts[len(posts)-1], filter.Sort))
+			if err != nil {
+				t.Fatalf("ParsePostCursor: %v", err)
+			}
+			filter.After = cursor
+		}
+		return got
+	}
+
+	if want := []int{ids[4], ids[3], ids[2], ids[1], ids[0]}; !equalIDs(collect(false), want) {
+		t.Errorf("cursor pages desc = %v, want %v", collect(false), want)
+	}
+	if !equalIDs(collect(true), ids) {
+		t.Errorf("cursor pages asc = %v, want %v", collect(true), ids)
+	}
+
+	if _, err := storage.ParsePostCursor("not a cursor"); err == nil {
+		t.Error("ParsePostCursor(garbage) succeeded")
+	}
+}
+
+func tagIDs(tags []models.Tag) []int {
+	ids := make([]int, len(tags))
+	for i, tag := range tags {
+		ids[i] = tag.ID
+	}
+	return ids
+}
+
+func testPostTemplates(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	media := models.Media{Hash: strings.Repeat("c", 64), Path: "cc/logo.png", Filename: "logo.png", MimeType: "image/png", Size: 1}
+	if err := s.CreateMedia(ctx, &media); err != nil {
+		t.Fatalf("CreateMedia: %v", err)
+	}
+
+	weekly := models.PostTemplate{
+		Name:      "weekly",
+		Content:   "Digest {{date}} for {{channel.title}}: {{topic}}",
+		MediaType: "photo",
+		MediaPath: media.Path,
+		Buttons:   json.RawMessage(`[{"text":"Open","url":"https://example.com"}]`),
+		Variables: map[string]string{"topic": "news"},
+		CreatedBy: "admin",
+	}
+	if err := s.CreatePostTemplate(ctx, &weekly); err != nil {
+		t.Fatalf("CreatePostTemplate: %v", err)
+	}
+	if weekly.ID == 0 || weekly.CreatedAt.IsZero() || weekly.UpdatedAt.IsZero() {
+		t.Errorf("CreatePostTemplate = %+v", weekly)
+	}
+	blank := models.PostTemplate{Name: "blank", MediaType: "text"}
+	if err := s.CreatePostTemplate(ctx, &blank); err != nil {
+		t.Fatalf("CreatePostTemplate: %v", err)
+	}
+	if err := s.CreatePostTemplate(ctx, &models.PostTemplate{Name: "weekly", MediaType: "text"}); !errors.Is(err, storage.ErrDuplicateName) {
+		t.Errorf("CreatePostTemplate(duplicate) error = %v, want ErrDuplicateName", err)
+	}
+
+	got, err := s.GetPostTemplate(ctx, weekly.ID)
+	if err != nil {
+		t.Fatalf("GetPostTemplate: %v", err)
+	}
+	if got.Name != "weekly" || got.Content != weekly.Content || got.MediaType != "photo" || got.MediaPath != media.Path ||
+		got.Variables["topic"] != "news" || got.CreatedBy != "admin" {
+		t.Errorf("GetPostTemplate = %+v", got)
+	}
+	var buttons []models.Button
+	if err := json.Unmarshal(got.Buttons, &buttons); err != nil || len(buttons) != 1 {
+		t.Errorf("GetPostTemplate buttons = %s, %v", got.Buttons, err)
+	}
+	_, err = s.GetPostTemplate(ctx, 9999)
+	assertNotFound(t, "GetPostTemplate", err)
+
+	templates, err := s.GetPostTemplates(ctx)
+	if err != nil {
+		t.Fatalf("GetPostTemplates: %v", err)
+	}
+	if len(templates) != 2 || templates[0].Name != "blank" || templates[1].Name != "weekly" {
+		t.Errorf("GetPostTemplates = %+v", templates)
+	}
+
+	// Файл шаблона не считается неиспользуемым
+	unused, err := s.GetUnreferencedMedia(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetUnreferencedMedia: %v", err)
+	}
+	if len(unused) != 0 {
+		t.Errorf("GetUnreferencedMedia = %v, want the template file kept", mediaIDs(unused))
+	}
+
+	got.Name = "blank"
+	if err := s.UpdatePostTemplate(ctx, got); !errors.Is(err, storage.ErrDuplicateName) {
+		t.Errorf("UpdatePostTemplate(duplicate) error = %v, want ErrDuplicateName", err)
+	}
+	got.Name = "digest"
+	got.MediaType = "text"
+	got.MediaPath = ""
+	got.Variables = nil
+	if err := s.UpdatePostTemplate(ctx, got); err != nil {
+		t.Fatalf("UpdatePostTemplate: %v", err)
+	}
+	got, _ = s.GetPostTemplate(ctx, weekly.ID)
+	if got.Name != "digest" || got.MediaPath != "" || len(got.Variables) != 0 || got.CreatedBy != "admin" {
+		t.Errorf("after UpdatePostTemplate = %+v", got)
+	}
+	assertNotFound(t, "UpdatePostTemplate", s.UpdatePostTemplate(ctx, &models.PostTemplate{ID: 9999, Name: "x", MediaType: "text"}))
+
+	if err := s.DeletePostTemplate(ctx, weekly.ID); err != nil {
+		t.Fatalf("DeletePostTemplate: %v", err)
+	}
+	_, err = s.GetPostTemplate(ctx, weekly.ID)
+	assertNotFound(t, "GetPostTemplate(deleted)", err)
+	assertNotFound(t, "DeletePostTemplate", s.DeletePostTemplate(ctx, weekly.ID))
+}
+
+func testTags(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	channel := createChannel(t, s, "promo", true)
+
+	promo := models.Tag{Name: "promo", Hashtag: true, ChannelIDs: []int{channel.ID}}
+	if err := s.CreateTag(ctx, &promo); err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+	news := models.Tag{Name: "news"}
+	if err := s.CreateTag(ctx, &news); err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+
+	if err := s.CreateTag(ctx, &models.Tag{Name: "news"}); !errors.Is(err, storage.ErrDuplicateName) {
+		t.Errorf("CreateTag(duplicate) error = %v, want ErrDuplicateName", err)
+	}
+
+	got, err := s.GetTag(ctx, promo.ID)
+	if err != nil {
+		t.Fatalf("GetTag: %v", err)
+	}
+	if got.Name != "promo" || !got.Hashtag || !equalIDs(got.ChannelIDs, []int{channel.ID}) {
+		t.Errorf("GetTag = %+v", got)
+	}
+	_, err = s.GetTag(ctx, 9999)
+	assertNotFound(t, "GetTag", err)
+
+	tags, err := s.GetTags(ctx)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if want := []int{news.ID, promo.ID}; !equalIDs(tagIDs(tags), want) {
+		t.Errorf("GetTags = %v, want %v", tagIDs(tags), want)
+	}
+
+	news.Name = "promo"
+	if err := s.UpdateTag(ctx, &news); !errors.Is(err, storage.ErrDuplicateName) {
+		t.Errorf("UpdateTag(duplicate) error = %v, want ErrDuplicateName", err)
+	}
+	news.Name = "digest"
+	news.ChannelIDs = []int{channel.ID}
+	if err := s.UpdateTag(ctx, &news); err != nil {
+		t.Fatalf("UpdateTag: %v", err)
+	}
+	assertNotFound(t, "UpdateTag", s.UpdateTag(ctx, &models.Tag{ID: 9999, Name: "missing"}))
+
+	p1 := createPost(t, s, "p1", "sent")
+	p2 := createPost(t, s, "p2", "draft")
+	if err := s.SetPostTags(ctx, p1.ID, []int{promo.ID, news.ID}); err != nil {
+		t.Fatalf("SetPostTags: %v", err)
+	}
+	if err := s.SetPostTags(ctx, p2.ID, []int{news.ID}); err != nil {
+		t.Fatalf("SetPostTags: %v", err)
+	}
+
+	postTags, err := s.GetPostTags(ctx, p1.ID)
+	if err != nil {
+		t.Fatalf("GetPostTags: %v", err)
+	}
+	if want := []int{news.ID, promo.ID}; !equalIDs(tagIDs(postTags), want) {
+		t.Errorf("GetPostTags = %v, want %v", tagIDs(postTags), want)
+	}
+
+	posts, err := s.ListPosts(ctx, models.PostFilter{TagID: promo.ID, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	if !equalIDs(postIDs(posts), []int{p1.ID}) {
+		t.Errorf("ListPosts(tag) = %v, want [%d]", postIDs(posts), p1.ID)
+	}
+
+	createDelivery(t, s, p1.ID, channel.ID, "sent")
+
+	stats, err := s.GetTagStatistics(ctx, 0)
+	if err != nil {
+		t.Fatalf("GetTagStatistics: %v", err)
+	}
+	want := []models.TagStatistics{
+		{TagID: news.ID, Name: "digest", Posts: 2, Successful: 1},
+		{TagID: promo.ID, Name: "promo", Posts: 1, Successful: 1},
+	}
+	if len(stats) != len(want) || stats[0] != want[0] || stats[1] != want[1] {
+		t.Errorf("GetTagStatistics = %+v, want %+v", stats, want)
+	}
+
+	// Замена набора тегов
+	if err := s.SetPostTags(ctx, p1.ID, []int{promo.ID}); err != nil {
+		t.Fatalf("SetPostTags: %v", err)
+	}
+	postTags, _ = s.GetPostTags(ctx, p1.ID)
+	if !equalIDs(tagIDs(postTags), []int{promo.ID}) {
+		t.Errorf("GetPostTags after replace = %v, want [%d]", tagIDs(postTags), promo.ID)
+	}
+
+	if err := s.DeleteTag(ctx, promo.ID); err != nil {
+		t.Fatalf("DeleteTag: %v", err)
+	}
+	assertNotFound(t, "DeleteTag", s.DeleteTag(ctx, promo.ID))
+	postTags, _ = s.GetPostTags(ctx, p1.ID)
+	if len(postTags) != 0 {
+		t.Errorf("GetPostTags after DeleteTag = %v, want none", tagIDs(postTags))
+	}
+}
+
+func mediaIDs(files []models.Media) []int {
+	ids := make([]int, len(files))
+	for i, media := range files {
+		ids[i] = media.ID
+	}
+	return ids
+}
+
+func testMedia(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	files := []models.Media{
+		{Hash: strings.Repeat("a", 64), Path: "uploads/a.jpg", Filename: "Cat.jpg", MimeType: "image/jpeg",
+			Size: 100, Width: 640, Height: 480, UploadedBy: "admin"},
+		{Hash: strings.Repeat("b", 64), Path: "uploads/b.mp4", Filename: "clip.mp4", MimeType: "video/mp4",
+			Size: 2000, Width: 1280, Height: 720, Duration: 15, UploadedBy: "editor"},
+		{Hash: strings.Repeat("c", 64), Path: "uploads/c.pdf", Filename: "report 50%.pdf", MimeType: "application/pdf", Size: 10},
+	}
+	for i := range files {
+		if err := s.CreateMedia(ctx, &files[i]); err != nil {
+			t.Fatalf("CreateMedia: %v", err)
+		}
+	}
+
+	duplicate := models.Media{Hash: files[0].Hash, Path: "uploads/other.jpg", Filename: "other.jpg", MimeType: "image/jpeg"}
+	if err := s.CreateMedia(ctx, &duplicate); !errors.Is(err, storage.ErrDuplicateKey) {
+		t.Errorf("CreateMedia(duplicate) error = %v, want ErrDuplicateKey", err)
+	}
+
+	got, err := s.GetMedia(ctx, files[1].ID)
+	if err != nil {
+		t.Fatalf("GetMedia: %v", err)
+	}
+	if got.Filename != "clip.mp4" || got.Size != 2000 || got.Width != 1280 || got.Height != 720 ||
+		got.Duration != 15 || got.UploadedBy != "editor" || got.CreatedAt.IsZero() {
+		t.Errorf("GetMedia = %+v", got)
+	}
+	_, err = s.GetMedia(ctx, 9999)
+	assertNotFound(t, "GetMedia", err)
+
+	got, err = s.GetMediaByHash(ctx, files[2].Hash)
+	if err != nil {
+		t.Fatalf("GetMediaByHash: %v", err)
+	}
+	if got.ID != files[2].ID || got.Width != 0 || got.UploadedBy != "" {
+		t.Errorf("GetMediaByHash = %+v", got)
+	}
+	_, err = s.GetMediaByHash(ctx, strings.Repeat("f", 64))
+	assertNotFound(t, "GetMediaByHash", err)
+
+	filters := []struct {
+		name   string
+		filter models.MediaFilter
+		want   []int
+	}{
+		{"all", models.MediaFilter{Limit: 10}, []int{files[2].ID, files[1].ID, files[0].ID}},
+		{"page", models.MediaFilter{Limit: 1, Offset: 1}, []int{files[1].ID}},
+		{"query ignores case", models.MediaFilter{Query: "cat", Limit: 10}, []int{files[0].ID}},
+		{"query escapes wildcards", models.MediaFilter{Query: "50%", Limit: 10}, []int{files[2].ID}},
+		{"mime prefix", models.MediaFilter{MimePrefix: "video/", Limit: 10}, []int{files[1].ID}},
+	}
+	for _, tt := range filters {
+		list, err := s.ListMedia(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("ListMedia(%s): %v", tt.name, err)
+		}
+		if !equalIDs(mediaIDs(list), tt.want) {
+			t.Errorf("ListMedia(%s) = %v, want %v", tt.name, mediaIDs(list), tt.want)
+		}
+	}
+
+	// Первый файл используется постом, второй — только старой ревизией
+	post := createPost(t, s, "photo", "draft")
+	post.MediaType = "photo"
+	post.MediaPath = files[0].Path
+	if err := s.UpdatePost(ctx, &post); err != nil {
+		t.Fatalf("UpdatePost: %v", err)
+	}
+	rev := models.PostRevision{PostID: post.ID, MediaType: "video", MediaPath: files[1].Path, Author: "admin"}
+	if err := s.CreatePostRevision(ctx, &rev); err != nil {
+		t.Fatalf("CreatePostRevision: %v", err)
+	}
+
+	unused, err := s.GetUnreferencedMedia(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetUnreferencedMedia: %v", err)
+	}
+	if want := []int{files[2].ID}; !equalIDs(mediaIDs(unused), want) {
+		t.Errorf("GetUnreferencedMedia = %v, want %v", mediaIDs(unused), want)
+	}
+
+	unused, err = s.GetUnreferencedMedia(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetUnreferencedMedia: %v", err)
+	}
+	if len(unused) != 0 {
+		t.Errorf("GetUnreferencedMedia(before upload) = %v, want none", mediaIDs(unused))
+	}
+
+	// Пост в корзине по-прежнему удерживает файл
+	if err := s.DeletePost(ctx, post.ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	unused, err = s.GetUnreferencedMedia(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetUnreferencedMedia: %v", err)
+	}
+	if want := []int{files[2].ID}; !equalIDs(mediaIDs(unused), want) {
+		t.Errorf("GetUnreferencedMedia(trashed post) = %v, want %v", mediaIDs(unused), want)
+	}
+
+	if err := s.DeleteMedia(ctx, files[2].ID); err != nil {
+		t.Fatalf("DeleteMedia: %v", err)
+	}
+	_, err = s.GetMedia(ctx, files[2].ID)
+	assertNotFound(t, "GetMedia(deleted)", err)
+	assertNotFound(t, "DeleteMedia", s.DeleteMedia(ctx, files[2].ID))
+}
+
+func testScheduledPosts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	schedule := func(content string, at time.Time, status string) models.Post {
+		post := models.Post{Content: content, MediaType: "text", Status: status, CreatedBy: "admin", ScheduleTime: &at}
+		if err := s.CreatePost(ctx, &post); err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		return post
+	}
+
+	late := schedule("late", now.Add(-time.Minute), "scheduled")
+	early := schedule("early", now.Add(-time.Hour), "scheduled")
+	schedule("future", now.Add(time.Hour), "scheduled")
+	schedule("draft", now.Add(-time.Hour), "draft")
+	exact := schedule("exact", now, "scheduled")
+
+	posts, err := s.GetScheduledPosts(ctx, now)
+	if err != nil {
+		t.Fatalf("GetScheduledPosts: %v", err)
+	}
+	if want := []int{early.ID, late.ID, exact.ID}; !equalIDs(postIDs(posts), want) {
+		t.Errorf("GetScheduledPosts = %v, want %v", postIDs(posts), want)
+	}
+	if len(posts) > 0 && (posts[0].ScheduleTime == nil || !posts[0].ScheduleTime.Equal(now.Add(-time.Hour))) {
+		t.Errorf("ScheduleTime = %v, want %v", posts[0].ScheduleTime, now.Add(-time.Hour))
+	}
+}
+
+func testIdempotencyKey(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	post := models.Post{Content: "once", MediaType: "text", Status: "sending", CreatedBy: "admin", IdempotencyKey: "key-1"}
+	if err := s.CreatePost(ctx, &post); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	retry := models.Post{Content: "once", MediaType: "text", Status: "sending", CreatedBy: "admin", IdempotencyKey: "key-1"}
+	if err := s.CreatePost(ctx, &retry); !errors.Is(err, storage.ErrDuplicateKey) {
+		t.Fatalf("CreatePost retry: got %v, want ErrDuplicateKey", err)
+	}
+
+	other := models.Post{Content: "once", MediaType: "text", Status: "draft", CreatedBy: "editor", IdempotencyKey: "key-1"}
+	if err := s.CreatePost(ctx, &other); err != nil {
+		t.Fatalf("CreatePost by another author: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		noKey := models.Post{Content: "no key", MediaType: "text", Status: "draft", CreatedBy: "admin"}
+		if err := s.CreatePost(ctx, &noKey); err != nil {
+			t.Fatalf("CreatePost without key: %v", err)
+		}
+	}
+
+	got, err := s.GetPostByIdempotencyKey(ctx, "admin", "key-1")
+	if err != nil {
+		t.Fatalf("GetPostByIdempotencyKey: %v", err)
+	}
+	if got.ID != post.ID || got.IdempotencyKey != "key-1" {
+		t.Errorf("GetPostByIdempotencyKey = %+v, want post %d", got, post.ID)
+	}
+}
+
+func testClaimPostForSending(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	draft := createPost(t, s, "draft", "draft")
+	sent := createPost(t, s, "sent", "sent")
+
+	claimed, err := s.ClaimPostForSending(ctx, draft.ID, "send-1")
+	if err != nil || !claimed {
+		t.Fatalf("ClaimPostForSending(draft) = %v, %v; want true", claimed, err)
+	}
+
+	got, _ := s.GetPost(ctx, draft.ID)
+	if got.Status != "sending" || got.SendIdempotencyKey != "send-1" {
+		t.Errorf("after claim = status %q key %q", got.Status, got.SendIdempotencyKey)
+	}
+
+	claimed, err = s.ClaimPostForSending(ctx, draft.ID, "send-2")
+	if err != nil || claimed {
+		t.Errorf("second ClaimPostForSending = %v, %v; want false", claimed, err)
+	}
+
+	claimed, err = s.ClaimPostForSending(ctx, sent.ID, "")
+	if err != nil || claimed {
+		t.Errorf("ClaimPostForSending(sent) = %v, %v; want false", claimed, err)
+	}
+
+	claimed, err = s.ClaimPostForSending(ctx, 999, "")
+	if err != nil || claimed {
+		t.Errorf("ClaimPostForSending(missing) = %v, %v; want false", claimed, err)
+	}
+}
+
+func testUpdatePostIfStatus(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	post := createPost(t, s, "draft", "draft")
+	if _, err := s.ClaimPostForSending(ctx, post.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Форма загружена с черновиком, а пост тем временем ушёл в отправку
+	edited := post
+	edited.Content = "edited"
+	updated, err := s.UpdatePostIfStatus(ctx, &edited, "draft")
+	if err != nil || updated {
+		t.Fatalf("UpdatePostIfStatus(stale status) = %v, %v; want false", updated, err)
+	}
+	if got, _ := s.GetPost(ctx, post.ID); got.Status != "sending" || got.Content != "draft" {
+		t.Errorf("after stale update = status %q content %q", got.Status, got.Content)
+	}
+
+	edited.Status = "scheduled"
+	updated, err = s.UpdatePostIfStatus(ctx, &edited, "sending")
+	if err != nil || !updated {
+		t.Fatalf("UpdatePostIfStatus(current status) = %v, %v; want true", updated, err)
+	}
+	if got, _ := s.GetPost(ctx, post.ID); got.Status != "scheduled" || got.Content != "edited" {
+		t.Errorf("after update = status %q content %q", got.Status, got.Content)
+	}
+
+	missing := models.Post{ID: 999, Status: "draft"}
+	if updated, err := s.UpdatePostIfStatus(ctx, &missing, "draft"); err != nil || updated {
+		t.Errorf("UpdatePostIfStatus(missing) = %v, %v; want false", updated, err)
+	}
+}
+
+func testPostRevisions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	p1 := createPost(t, s, "p1", "draft")
+	p2 := createPost(t, s, "p2", "draft")
+
+	scheduled := time.Now().Add(time.Hour).Truncate(time.Second)
+	revs := []models.PostRevision{
+		{PostID: p1.ID, Content: "first", MediaType: "text", Author: "admin", Reason: "created"},
+		{PostID: p2.ID, Content: "other", MediaType: "text", Author: "admin"},
+		{PostID: p1.ID, Content: "second", MediaType: "text", Buttons: json.RawMessage(`[{"text":"a","url":"https://a"}]`),
+			ScheduleTime: &scheduled, Author: "editor", Reason: "typo"},
+	}
+	for i := range revs {
+		if err := s.CreatePostRevision(ctx, &revs[i]); err != nil {
+			t.Fatalf("CreatePostRevision: %v", err)
+		}
+	}
+
+	if revs[0].Number != 1 || revs[1].Number != 1 || revs[2].Number != 2 {
+		t.Errorf("revision numbers = %d, %d, %d, want 1, 1, 2", revs[0].Number, revs[1].Number, revs[2].Number)
+	}
+
+	got, err := s.GetPostRevision(ctx, revs[2].ID)
+	if err != nil {
+		t.Fatalf("GetPostRevision: %v", err)
+	}
+	if got.Content != "second" || got.Author != "editor" || got.Reason != "typo" || got.CreatedAt.IsZero() {
+		t.Errorf("GetPostRevision = %+v", got)
+	}
+	if got.ScheduleTime == nil || !got.ScheduleTime.Equal(scheduled) {
+		t.Errorf("ScheduleTime = %v, want %v", got.ScheduleTime, scheduled)
+	}
+	var buttons []models.Button
+	if err := json.Unmarshal(got.Buttons, &buttons); err != nil || len(buttons) != 1 {
+		t.Errorf("Buttons = %s (%v)", got.Buttons, err)
+	}
+
+	_, err = s.GetPostRevision(ctx, 9999)
+	assertNotFound(t, "GetPostRevision", err)
+
+	list, err := s.GetPostRevisions(ctx, p1.ID)
+	if err != nil {
+		t.Fatalf("GetPostRevisions: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != revs[2].ID || list[1].ID != revs[0].ID {
+		t.Errorf("GetPostRevisions = %+v", list)
+	}
+
+	// Ревизии переживают удаление в корзину и исчезают вместе с постом
+	if err := s.DeletePost(ctx, p1.ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	list, err = s.GetPostRevisions(ctx, p1.ID)
+	if err != nil {
+		t.Fatalf("GetPostRevisions: %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("revisions after DeletePost = %+v, want 2", list)
+	}
+
+	if err := s.PurgePost(ctx, p1.ID); err != nil {
+		t.Fatalf("PurgePost: %v", err)
+	}
+	list, err = s.GetPostRevisions(ctx, p1.ID)
+	if err != nil {
+		t.Fatalf("GetPostRevisions: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("revisions after PurgePost = %+v", list)
+	}
+}
+
+func testDeliveries(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	c1 := createChannel(t, s, "c1", true)
+	c2 := createChannel(t, s, "c2", true)
+	p1 := createPost(t, s, "p1", "sent")
+	p2 := createPost(t, s, "p2", "sent")
+
+	d1 := createDelivery(t, s, p1.ID, c1.ID, "sent")
+	d2 := createDelivery(t, s, p1.ID, c2.ID, "error")
+	d3 := createDelivery(t, s, p2.ID, c1.ID, "sent")
+
+	got, err := s.GetPostChannel(ctx, d2.ID)
+	if err != nil {
+		t.Fatalf("GetPostChannel: %v", err)
+	}
+	if got.PostID != p1.ID || got.ChannelID != c2.ID || got.Status != "error" || got.Error != "chat not found" || got.SentAt.IsZero() {
+		t.Errorf("GetPostChannel = %+v", got)
+	}
+
+	_, err = s.GetPostChannel(ctx, 999)
+	assertNotFound(t, "GetPostChannel", err)
+
+	ids := func(filter models.DeliveryFilter) []int {
+		filter.Limit = 10
+		deliveries, err := s.GetPostChannels(ctx, filter)
+		if err != nil {
+			t.Fatalf("GetPostChannels: %v", err)
+		}
+		out := make([]int, len(deliveries))
+		for i, d := range deliveries {
+			out[i] = d.ID
+		}
+		return out
+	}
+
+	if got, want := ids(models.DeliveryFilter{}), []int{d3.ID, d2.ID, d1.ID}; !equalIDs(got, want) {
+		t.Errorf("all deliveries = %v, want %v", got, want)
+	}
+	if got, want := ids(models.DeliveryFilter{PostID: p1.ID}), []int{d2.ID, d1.ID}; !equalIDs(got, want) {
+		t.Errorf("deliveries by post = %v, want %v", got, want)
+	}
+	if got, want := ids(models.DeliveryFilter{ChannelID: c1.ID, Status: "sent"}), []int{d3.ID, d1.ID}; !equalIDs(got, want) {
+		t.Errorf("deliveries by channel and status = %v, want %v", got, want)
+	}
+}
+
+func testSoftDelete(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	c1 := createChannel(t, s, "c1", true)
+	c2 := createChannel(t, s, "c2", true)
+	p1 := createPost(t, s, "p1", "sent")
+	p2 := createPost(t, s, "p2", "scheduled")
+
+	createDelivery(t, s, p1.ID, c1.ID, "sent")
+	createDelivery(t, s, p1.ID, c2.ID, "sent")
+
+	if err := s.DeletePost(ctx, p1.ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	if err := s.DeleteChannel(ctx, c1.ID); err != nil {
+		t.Fatalf("DeleteChannel: %v", err)
+	}
+
+	// Удалённые записи не видны обычным методам
+	if _, err := s.GetPost(ctx, p1.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetPost(deleted) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetChannel(ctx, c1.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetChannel(deleted) error = %v, want ErrNotFound", err)
+	}
+	assertNotFound(t, "DeletePost(deleted)", s.DeletePost(ctx, p1.ID))
+	assertNotFound(t, "UpdatePost(deleted)", s.UpdatePost(ctx, &p1))
+
+	channels, err := s.GetChannels(ctx)
+	if err != nil {
+		t.Fatalf("GetChannels: %v", err)
+	}
+	if !equalIDs(channelIDs(channels), []int{c2.ID}) {
+		t.Errorf("GetChannels = %v, want [%d]", channelIDs(channels), c2.ID)
+	}
+
+	posts, err := s.ListPosts(ctx, models.PostFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	if !equalIDs(postIDs(posts), []int{p2.ID}) {
+		t.Errorf("ListPosts = %v, want [%d]", postIDs(posts), p2.ID)
+	}
+
+	// Доставки остаются в истории
+	deliveries, err := s.GetPostChannels(ctx, models.DeliveryFilter{PostID: p1.ID, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetPostChannels: %v", err)
+	}
+	if len(deliveries) != 2 {
+		t.Errorf("deliveries of deleted post = %d, want 2", len(deliveries))
+	}
+
+	stats, err := s.GetStatistics(ctx, 0)
+	if err != nil {
+		t.Fatalf("GetStatistics: %v", err)
+	}
+	if stats.TotalPosts != 1 || stats.TotalChannels != 1 || stats.Successful != 2 {
+		t.Errorf("statistics = %+v, want 1 post, 1 channel, 2 successful", stats)
+	}
+
+	// Корзина
+	deletedPosts, err := s.GetDeletedPosts(ctx)
+	if err != nil {
+		t.Fatalf("GetDeletedPosts: %v", err)
+	}
+	if !equalIDs(postIDs(deletedPosts), []int{p1.ID}) || deletedPosts[0].DeletedAt == nil {
+		t.Errorf("GetDeletedPosts = %+v, want [%d] with deleted_at", deletedPosts, p1.ID)
+	}
+	deletedChannels, err := s.GetDeletedChannels(ctx)
+	if err != nil {
+		t.Fatalf("GetDeletedChannels: %v", err)
+	}
+	if !equalIDs(channelIDs(deletedChannels), []int{c1.ID}) {
+		t.Errorf("GetDeletedChannels = %v, want [%d]", channelIDs(deletedChannels), c1.ID)
+	}
+
+	// Восстановление
+	if err := s.RestorePost(ctx, p1.ID); err != nil {
+		t.Fatalf("RestorePost: %v", err)
+	}
+	if err := s.RestoreChannel(ctx, c1.ID); err != nil {
+		t.Fatalf("RestoreChannel: %v", err)
+	}
+	assertNotFound(t, "RestorePost(live)", s.RestorePost(ctx, p1.ID))
+	assertNotFound(t, "RestoreChannel(live)", s.RestoreChannel(ctx, c1.ID))
+
+	restored, err := s.GetPost(ctx, p1.ID)
+	if err != nil {
+		t.Fatalf("GetPost(restored): %v", err)
+	}
+	if restored.DeletedAt != nil {
+		t.Errorf("restored post DeletedAt = %v, want nil", restored.DeletedAt)
+	}
+	if _, err := s.GetChannel(ctx, c1.ID); err != nil {
+		t.Errorf("GetChannel(restored): %v", err)
+	}
+}
+
+func assertTagStatistics(t *testing.T, s storage.Storage, want models.TagStatistics) {
+	t.Helper()
+
+	stats, err := s.GetTagStatistics(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("GetTagStatistics: %v", err)
+	}
+	if len(stats) != 1 || stats[0] != want {
+		t.Errorf("GetTagStatistics = %+v, want [%+v]", stats, want)
+	}
+}
+
+func testPurge(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	c1 := createChannel(t, s, "c1", true)
+	c2 := createChannel(t, s, "c2", true)
+	p1 := createPost(t, s, "p1", "sent")
+	p2 := createPost(t, s, "p2", "sent")
+
+	createDelivery(t, s, p1.ID, c1.ID, "sent")
+	createDelivery(t, s, p2.ID, c1.ID, "error")
+	createDelivery(t, s, p2.ID, c2.ID, "sent")
+
+	tag := models.Tag{Name: "purged"}
+	if err := s.CreateTag(ctx, &tag); err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+	if err := s.SetPostTags(ctx, p1.ID, []int{tag.ID}); err != nil {
+		t.Fatalf("SetPostTags: %v", err)
+	}
+
+	// Окончательно удалить можно только запись из корзины
+	assertNotFound(t, "PurgePost(live)", s.PurgePost(ctx, p1.ID))
+	assertNotFound(t, "PurgeChannel(live)", s.PurgeChannel(ctx, c1.ID))
+
+	if err := s.DeletePost(ctx, p1.ID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	if err := s.DeleteChannel(ctx, c1.ID); err != nil {
+		t.Fatalf("DeleteChannel: %v", err)
+	}
+
+	// Пост в корзине ещё учитывается в статистике тегов
+	assertTagStatistics(t, s, models.TagStatistics{TagID: tag.ID, Name: "purged", Successful: 1})
+
+	if err := s.PurgePost(ctx, p1.ID); err != nil {
+		t.Fatalf("PurgePost: %v", err)
+	}
+	// Вместе с постом удаляются его теги, и доставки выпадают из статистики
+	// тегов, оставаясь в общей
+	assertTagStatistics(t, s, models.TagStatistics{TagID: tag.ID, Name: "purged"})
+	assertNotFound(t, "RestorePost(purged)", s.RestorePost(ctx, p1.ID))
+
+	// Удалённые позже before не трогаются
+	n, err := s.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeDeleted: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("PurgeDeleted(an hour ago) = %d, want 0", n)
+	}
+
+	n, err = s.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeDeleted: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("PurgeDeleted = %d, want 1", n)
+	}
+	assertNotFound(t, "RestoreChannel(purged)", s.RestoreChannel(ctx, c1.ID))
+
+	deleted, err := s.GetDeletedChannels(ctx)
+	if err != nil {
+		t.Fatalf("GetDeletedChannels: %v", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("GetDeletedChannels after purge = %v, want none", channelIDs(deleted))
+	}
+
+	// Доставки удалённых окончательно каналов и постов остаются в статистике
+	deliveries, err := s.GetPostChannels(ctx, models.DeliveryFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetPostChannels: %v", err)
+	}
+	if len(deliveries) != 3 {
+		t.Errorf("deliveries after purge = %d, want 3", len(deliveries))
+	}
+
+	stats, err := s.GetStatistics(ctx, 0)
+	if err != nil {
+		t.Fatalf("GetStatistics: %v", err)
+	}
+	if stats.Successful != 2 || stats.Failed != 1 || stats.TotalChannels != 1 || stats.TotalPosts != 1 {
+		t.Errorf("statistics = %+v, want 2 successful, 1 failed, 1 channel, 1 post", stats)
+	}
+}
+
+func testStatistics(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	c1 := createChannel(t, s, "c1", true)
+	createChannel(t, s, "c2", false)
+	p1 := createPost(t, s, "p1", "sent")
+	createPost(t, s, "p2", "scheduled")
+	createPost(t, s, "p3", "draft")
+
+	createDelivery(t, s, p1.ID, c1.ID, "sent")
+	createDelivery(t, s, p1.ID, c1.ID, "sent")
+	createDelivery(t, s, p1.ID, c1.ID, "error")
+
+	stats, err := s.GetStatistics(ctx, 7)
+	if err != nil {
+		t.Fatalf("GetStatistics: %v", err)
+	}
+
+	want := models.Statistics{
+		TotalPosts:     3,
+		Successful:     2,
+		Failed:         1,
+		Scheduled:      1,
+		TotalChannels:  2,
+		ActiveChannels: 1,
+	}
+	if *stats != want {
+		t.Errorf("GetStatistics = %+v, want %+v", *stats, want)
+	}
+}
+
+func testVariantStatistics(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	c1 := createChannel(t, s, "c1", true)
+	c2 := createChannel(t, s, "c2", true)
+	c3 := createChannel(t, s, "c3", true)
+	p1 := createPost(t, s, "p1", "sent")
+	p2 := createPost(t, s, "p2", "sent")
+
+	deliveries := []models.PostChannel{
+		{PostID: p1.ID, ChannelID: c1.ID, Status: "sent", Variant: "B"},
+		{PostID: p1.ID, ChannelID: c2.ID, Status: "error", Variant: "A"},
+		{PostID: p1.ID, ChannelID: c2.ID, Status: "sent", Variant: "A"},
+		{PostID: p1.ID, ChannelID: c3.ID, Status: "sent", Variant: "A"},
+		{PostID: p2.ID, ChannelID: c1.ID, Status: "sent"},
+	}
+	for i := range deliveries {
+		if err := s.CreatePostChannel(ctx, &deliveries[i]); err != nil {
+			t.Fatalf("CreatePostChannel: %v", err)
+		}
+	}
+
+	got, err := s.GetPostChannel(ctx, deliveries[0].ID)
+	if err != nil {
+		t.Fatalf("GetPostChannel: %v", err)
+	}
+	if got.Variant != "B" {
+		t.Errorf("GetPostChannel Variant = %q, want B", got.Variant)
+	}
+
+	stats, err := s.GetVariantStatistics(ctx, p1.ID)
+	if err != nil {
+		t.Fatalf("GetVariantStatistics: %v", err)
+	}
+	want := []models.VariantStatistics{
+		{Variant: "A", Channels: 2, Successful: 2, Failed: 1},
+		{Variant: "B", Channels: 1, Successful: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("GetVariantStatistics = %+v, want %+v", stats, want)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("GetVariantStatistics[%d] = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+
+	stats, err = s.GetVariantStatistics(ctx, p2.ID)
+	if err != nil {
+		t.Fatalf("GetVariantStatistics: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Variant != "" || stats[0].Successful != 1 {
+		t.Errorf("GetVariantStatistics without variants = %+v", stats)
+	}
+}
+
+func testDiscussionComments(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	channel := models.Channel{TelegramID: -100777, Title: "forum", IsActive: true, Timezone: "UTC"}
+	if err := s.CreateChannel(ctx, &channel); err != nil {
+		t.Fatalf("CreateChannel: %v", err)
+	}
+	other := createChannel(t, s, "other", true)
+	post := createPost(t, s, "p", "sent")
+
+	pending := models.PostChannel{PostID: post.ID, ChannelID: channel.ID, MessageID: 10, Status: "sent",
+		MessageThreadID: 5, CommentStatus: models.CommentPending}
+	plain := models.PostChannel{PostID: post.ID, ChannelID: other.ID, MessageID: 10, Status: "sent"}
+	for _, pc := range []*models.PostChannel{&pending, &plain} {
+		if err := s.CreatePostChannel(ctx, pc); err != nil {
+			t.Fatalf("CreatePostChannel: %v", err)
+		}
+	}
+
+	got, err := s.GetPendingComment(ctx, channel.TelegramID, 10)
+	if err != nil {
+		t.Fatalf("GetPendingComment: %v", err)
+	}
+	if got.ID != pending.ID || got.MessageThreadID != 5 || got.CommentStatus != models.CommentPending {
+		t.Errorf("GetPendingComment = %+v", got)
+	}
+	_, err = s.GetPendingComment(ctx, other.TelegramID, 10)
+	assertNotFound(t, "GetPendingComment(no comment)", err)
+	_, err = s.GetPendingComment(ctx, channel.TelegramID, 11)
+	assertNotFound(t, "GetPendingComment(other message)", err)
+
+	got.CommentStatus = "sent"
+	got.CommentMessageID = 99
+	if err := s.UpdatePostChannelComment(ctx, got); err != nil {
+		t.Fatalf("UpdatePostChannelComment: %v", err)
+	}
+	got, _ = s.GetPostChannel(ctx, pending.ID)
+	if got.CommentStatus != "sent" || got.CommentMessageID != 99 || got.CommentError != "" {
+		t.Errorf("after UpdatePostChannelComment = %+v", got)
+	}
+	_, err = s.GetPendingComment(ctx, channel.TelegramID, 10)
+	assertNotFound(t, "GetPendingComment(sent)", err)
+
+	err = s.UpdatePostChannelComment(ctx, &models.PostChannel{ID: 999})
+	assertNotFound(t, "UpdatePostChannelComment", err)
+}
+
+func testForumTopics(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	for _, topic := range []models.ForumTopic{
+		{ChatID: -100777, ThreadID: 7, Name: "News"},
+		{ChatID: -100777, ThreadID: 3, Name: "Chat"},
+		{ChatID: -100888, ThreadID: 3, Name: "Elsewhere"},
+		{ChatID: -100777, ThreadID: 7, Name: "Announcements"},
+	} {
+		if err := s.SaveForumTopic(ctx, &topic); err != nil {
+			t.Fatalf("SaveForumTopic: %v", err)
+		}
+	}
+
+	topics, err := s.GetForumTopics(ctx, -100777)
+	if err != nil {
+		t.Fatalf("GetForumTopics: %v", err)
+	}
+	if len(topics) != 2 || topics[0].ThreadID != 3 || topics[1].ThreadID != 7 || topics[1].Name != "Announcements" {
+		t.Errorf("GetForumTopics = %+v", topics)
+	}
+
+	topics, _ = s.GetForumTopics(ctx, -100999)
+	if len(topics) != 0 {
+		t.Errorf("GetForumTopics(unknown chat) = %+v", topics)
+	}
+}
+
+func testReposts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	c1 := createChannel(t, s, "c1", true)
+	c2 := createChannel(t, s, "c2", true)
+	post := createPost(t, s, "p", "sent")
+	other := createPost(t, s, "other", "sent")
+	source := createDelivery(t, s, post.ID, c1.ID, "sent")
+	otherSource := createDelivery(t, s, other.ID, c1.ID, "sent")
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	future := time.Now().Add(time.Hour).Truncate(time.Second)
+	due := models.Repost{PostChannelID: source.ID, ChannelID: c2.ID, Mode: models.RepostCopy, ScheduleTime: &past, Status: "scheduled", CreatedBy: "admin"}
+	later := models.Repost{PostChannelID: source.ID, ChannelID: c2.ID, Mode: models.RepostForward, ScheduleTime: &future, Status: "scheduled"}
+	now := models.Repost{PostChannelID: otherSource.ID, ChannelID: c2.ID, Mode: models.RepostCopy, Status: "sending"}
+	for _, repost := range []*models.Repost{&due, &later, &now} {
+		if err := s.CreateRepost(ctx, repost); err != nil {
+			t.Fatalf("CreateRepost: %v", err)
+		}
+		if repost.ID == 0 || repost.CreatedAt.IsZero() {
+			t.Errorf("CreateRepost did not set ID and CreatedAt: %+v", repost)
+		}
+	}
+
+	list, err := s.GetDueReposts(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("GetDueReposts: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != due.ID || list[0].Mode != models.RepostCopy || list[0].CreatedBy != "admin" ||
+		list[0].ScheduleTime == nil || !list[0].ScheduleTime.Equal(past) {
+		t.Errorf("GetDueReposts = %+v, want only the due repost", list)
+	}
+
+	claimed, err := s.ClaimRepost(ctx, due.ID)
+	if err != nil || !claimed {
+		t.Fatalf("ClaimRepost = %v, %v, want claimed", claimed, err)
+	}
+	if claimed, _ := s.ClaimRepost(ctx, due.ID); claimed {
+		t.Error("ClaimRepost claimed the same repost twice")
+	}
+	if err := s.UpdateRepostStatus(ctx, due.ID, "sent"); err != nil {
+		t.Fatalf("UpdateRepostStatus: %v", err)
+	}
+	err = s.UpdateRepostStatus(ctx, 999, "sent")
+	assertNotFound(t, "UpdateRepostStatus", err)
+
+	list, err = s.GetPostReposts(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("GetPostReposts: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != later.ID || list[1].ID != due.ID || list[1].Status != "sent" {
+		t.Errorf("GetPostReposts = %+v, want later and due reposts, newest first", list)
+	}
+
+	copied := models.PostChannel{PostID: post.ID, ChannelID: c2.ID, MessageID: 8, Status: "sent", RepostOf: source.ID, RepostMode: models.RepostCopy}
+	if err := s.CreatePostChannel(ctx, &copied); err != nil {
+		t.Fatalf("CreatePostChannel: %v", err)
+	}
+	got, _ := s.GetPostChannel(ctx, copied.ID)
+	if got.RepostOf != source.ID || got.RepostMode != models.RepostCopy {
+		t.Errorf("repost delivery = %+v", got)
+	}
+	got, _ = s.GetPostChannel(ctx, source.ID)
+	if got.RepostOf != 0 || got.RepostMode != "" {
+		t.Errorf("source delivery = %+v, want no repost fields", got)
+	}
+}
+
+func testAPITokens(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	first := models.APIToken{Name: "ci", TokenHash: "hash-1", Scopes: []string{"posts:read", "posts:write"}, CreatedBy: "admin"}
+	if err := s.CreateAPIToken(ctx, &first); err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	second := models.APIToken{Name: "bi", TokenHash: "hash-2", Scopes: []string{"statistics:read"}, CreatedBy: "admin"}
+	if err := s.CreateAPIToken(ctx, &second); err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+
+	got, err := s.GetAPITokenByHash(ctx, "hash-1")
+	if err != nil {
+		t.Fatalf("GetAPITokenByHash: %v", err)
+	}
+	if got.ID != first.ID || got.Name != "ci" || !got.HasScope("posts:write") || got.HasScope("statistics:read") {
+		t.Errorf("GetAPITokenByHash = %+v", got)
+	}
+	if got.LastUsedAt != nil || got.RevokedAt != nil {
+		t.Errorf("new token has LastUsedAt %v RevokedAt %v", got.LastUsedAt, got.RevokedAt)
+	}
+
+	_, err = s.GetAPITokenByHash(ctx, "missing")
+	assertNotFound(t, "GetAPITokenByHash", err)
+
+	tokens, err := s.GetAPITokens(ctx)
+	if err != nil {
+		t.Fatalf("GetAPITokens: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].ID != second.ID || tokens[1].ID != first.ID {
+		t.Errorf("GetAPITokens = %+v", tokens)
+	}
+
+	if err := s.TouchAPIToken(ctx, first.ID); err != nil {
+		t.Fatalf("TouchAPIToken: %v", err)
+	}
+	if err := s.RevokeAPIToken(ctx, first.ID); err != nil {
+		t.Fatalf("RevokeAPIToken: %v", err)
+	}
+
+	got, _ = s.GetAPITokenByHash(ctx, "hash-1")
+	if got.LastUsedAt == nil || got.RevokedAt == nil {
+		t.Errorf("after touch and revoke = %+v", got)
+	}
+
+	assertNotFound(t, "RevokeAPIToken twice", s.RevokeAPIToken(ctx, first.ID))
+}
+
+func testAuditLog(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	start := time.Now().Add(-time.Minute)
+	entries := []models.AuditEntry{
+		{Actor: "admin", Action: "channel.create", TargetType: "channel", TargetID: 1, After: json.RawMessage(`{"title":"c1"}`), IP: "10.0.0.1"},
+		{Actor: "scheduler", Action: "post.sent", TargetType: "post", TargetID: 7},
+		{Actor: "admin", Action: "post.update", TargetType: "post", TargetID: 7,
+			Before: json.RawMessage(`{"content":"a"}`), After: json.RawMessage(`{"content":"b"}`)},
+		{Actor: "admin", Action: "auth.logout"},
+	}
+	for i := range entries {
+		if err := s.CreateAuditEntry(ctx, &entries[i]); err != nil {
+			t.Fatalf("CreateAuditEntry: %v", err)
+		}
+		if entries[i].ID == 0 || entries[i].CreatedAt.IsZero() {
+			t.Fatalf("CreateAuditEntry did not set ID/CreatedAt: %+v", entries[i])
+		}
+	}
+
+	all, err := s.ListAuditEntries(ctx, models.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(all) != 4 || all[0].ID != entries[3].ID || all[3].ID != entries[0].ID {
+		t.Fatalf("ListAuditEntries = %+v", all)
+	}
+	if all[3].IP != "10.0.0.1" || all[3].TargetID != 1 || string(all[3].After) == "" || len(all[3].Before) != 0 {
+		t.Errorf("channel.create entry = %+v", all[3])
+	}
+	if all[0].TargetType != "" || all[0].TargetID != 0 {
+		t.Errorf("entry without target = %+v", all[0])
+	}
+
+	var diff struct{ Content string }
+	if err := json.Unmarshal(all[1].After, &diff); err != nil || diff.Content != "b" {
+		t.Errorf("After = %s (%v)", all[1].After, err)
+	}
+
+	byTarget, err := s.ListAuditEntries(ctx, models.AuditFilter{TargetType: "post", TargetID: 7, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(byTarget) != 2 || byTarget[0].ID != entries[2].ID || byTarget[1].ID != entries[1].ID {
+		t.Errorf("entries for post 7 = %+v", byTarget)
+	}
+
+	byActor, err := s.ListAuditEntries(ctx, models.AuditFilter{Actor: "admin", Action: "post.update", Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(byActor) != 1 || byActor[0].ID != entries[2].ID {
+		t.Errorf("entries by admin/post.update = %+v", byActor)
+	}
+
+	end := time.Now().Add(time.Minute)
+	inRange, err := s.ListAuditEntries(ctx, models.AuditFilter{From: &start, To: &end, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(inRange) != 2 || inRange[0].ID != entries[2].ID {
+		t.Errorf("page of entries in range = %+v", inRange)
+	}
+
+	// Курсор продолжает выдачу после записи, а новые записи её не сдвигают
+	cursor := models.PostCursor{Time: all[1].CreatedAt, ID: all[1].ID}
+	if err := s.CreateAuditEntry(ctx, &models.AuditEntry{Actor: "admin", Action: "auth.login"}); err != nil {
+		t.Fatalf("CreateAuditEntry: %v", err)
+	}
+	afterCursor, err := s.ListAuditEntries(ctx, models.AuditFilter{After: &cursor, Limit: 10, Offset: 5})
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(afterCursor) != 2 || afterCursor[0].ID != entries[1].ID || afterCursor[1].ID != entries[0].ID {
+		t.Errorf("entries after cursor = %+v", afterCursor)
+	}
+
+	future, err := s.ListAuditEntries(ctx, models.AuditFilter{From: &end, Limit: 10})
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(future) != 0 {
+		t.Errorf("entries after now = %+v", future)
+	}
+}
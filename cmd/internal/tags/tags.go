@@ -0,0 +1,87 @@
+// Package tags применяет правила тегов при отправке поста: набор каналов
+// по умолчанию и строку хэштегов в конце текста.
+package tags
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+)
+
+// Hashtag превращает имя тега в хэштег: всё, кроме букв и цифр,
+// заменяется подчёркиванием («release notes» → #release_notes).
+func Hashtag(name string) string {
+	return "#" + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(name))
+}
+
+// Footer возвращает хэштеги тегов с включённым Hashtag через пробел.
+func Footer(tags []models.Tag) string {
+	var hashtags []string
+	for _, tag := range tags {
+		if tag.Hashtag {
+			hashtags = append(hashtags, Hashtag(tag.Name))
+		}
+	}
+	return strings.Join(hashtags, " ")
+}
+
+// Decorate возвращает пост с хэштегами тегов в конце текста.
+func Decorate(post models.Post, tags []models.Tag) models.Post {
+	footer := Footer(tags)
+	if footer == "" {
+		return post
+	}
+
+	if post.Content == "" {
+		post.Content = footer
+	} else {
+		post.Content += "\n\n" + footer
+	}
+	return post
+}
+
+// Channels отбирает из active каналы по умолчанию тегов поста. Если ни у
+// одного тега набор не задан, пост уходит во все активные каналы.
+func Channels(active []models.Channel, tags []models.Tag) []models.Channel {
+	allowed := make(map[int]bool)
+	for _, tag := range tags {
+		for _, id := range tag.ChannelIDs {
+			allowed[id] = true
+		}
+	}
+	if len(allowed) == 0 {
+		return active
+	}
+
+	var channels []models.Channel
+	for _, channel := range active {
+		if allowed[channel.ID] {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// Prepare готовит пост к отправке: возвращает теги поста для Decorate и
+// активные каналы с учётом правил тегов.
+func Prepare(ctx context.Context, s storage.Storage, post models.Post) ([]models.Tag, []models.Channel, error) {
+	active, err := s.GetActiveChannels(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	postTags, err := s.GetPostTags(ctx, post.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return postTags, Channels(active, postTags), nil
+}
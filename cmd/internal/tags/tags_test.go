@@ -0,0 +1,57 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+func TestHashtag(t *testing.T) {
+	tests := map[string]string{
+		"news":          "#news",
+		"release notes": "#release_notes",
+		" Новости ":     "#Новости",
+		"a-b<c>":        "#a_b_c_",
+	}
+	for name, want := range tests {
+		if got := Hashtag(name); got != want {
+			t.Errorf("Hashtag(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDecorate(t *testing.T) {
+	tags := []models.Tag{
+		{Name: "news", Hashtag: true},
+		{Name: "internal"},
+		{Name: "promo", Hashtag: true},
+	}
+
+	post := Decorate(models.Post{Content: "Hello"}, tags)
+	if want := "Hello\n\n#news #promo"; post.Content != want {
+		t.Errorf("Decorate = %q, want %q", post.Content, want)
+	}
+
+	post = Decorate(models.Post{Content: "Hello"}, tags[1:2])
+	if post.Content != "Hello" {
+		t.Errorf("Decorate without hashtags = %q, want unchanged", post.Content)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	active := []models.Channel{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	got := Channels(active, []models.Tag{{Name: "news"}})
+	if len(got) != 3 {
+		t.Errorf("Channels without sets = %v, want all active", got)
+	}
+
+	// Наборы объединяются; неактивные и удалённые каналы не добавляются
+	got = Channels(active, []models.Tag{
+		{Name: "news", ChannelIDs: []int{1}},
+		{Name: "promo", ChannelIDs: []int{3, 42}},
+	})
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 3 {
+		t.Errorf("Channels = %v, want [1 3]", got)
+	}
+}
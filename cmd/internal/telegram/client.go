@@ -2,21 +2,25 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
 	"strconv"
+	"strings"
 
+	"github.com/maksekak/channelBot/cmd/internal/blob"
 	"github.com/maksekak/channelBot/cmd/internal/models"
 )
 
 type Client struct {
 	Token  string
 	APIURL string
+	// Files — хранилище, из которого отправляются файлы постов
+	Files blob.Store
 }
 
 func NewClient(token string) *Client {
@@ -47,6 +51,18 @@ func (c *Client) sendTextMessage(channelID int64, post models.Post) (int, error)
 		"text":       post.Content,
 		"parse_mode": "HTML",
 	}
+	if isSet(post.Options.DisableNotification) {
+		payload["disable_notification"] = true
+	}
+	if isSet(post.Options.ProtectContent) {
+		payload["protect_content"] = true
+	}
+	if post.Options.MessageThreadID != 0 {
+		payload["message_thread_id"] = post.Options.MessageThreadID
+	}
+	if options := linkPreviewOptions(post.Options); options != nil {
+		payload["link_preview_options"] = options
+	}
 
 	if len(post.Buttons) > 0 && string(post.Buttons) != "null" {
 		var buttons []models.Button
@@ -91,43 +107,63 @@ func (c *Client) sendDocumentMessage(channelID int64, post models.Post) (int, er
 }
 
 func (c *Client) sendMediaMessage(channelID int64, post models.Post, fieldName, method string) (int, error) {
-	file, err := os.Open(post.MediaPath)
+	if c.Files == nil {
+		return 0, fmt.Errorf("media storage is not configured")
+	}
+	file, err := c.Files.Open(context.Background(), post.MediaPath)
 	if err != nil {
 		return 0, err
 	}
 	defer file.Close()
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+	return c.uploadMedia(channelID, post, fieldName, method, file)
+}
 
-	// Добавляем файл
-	part, err := writer.CreateFormFile(fieldName, filepath.Base(post.MediaPath))
-	if err != nil {
-		return 0, err
-	}
-	io.Copy(part, file)
+// SendPhoto отправляет фото поста, читая его из photo вместо хранилища:
+// так уходит фото, обработанное для конкретного канала.
+func (c *Client) SendPhoto(channelID int64, post models.Post, photo io.Reader) (int, error) {
+	return c.uploadMedia(channelID, post, "photo", "sendPhoto", photo)
+}
 
-	// Добавляем остальные поля
-	writer.WriteField("chat_id", strconv.FormatInt(channelID, 10))
-	writer.WriteField("caption", post.Content)
-	writer.WriteField("parse_mode", "HTML")
+// uploadMedia отправляет файл поста методом method в поле fieldName.
+func (c *Client) uploadMedia(channelID int64, post models.Post, fieldName, method string, file io.Reader) (int, error) {
+	fields := map[string]string{
+		"chat_id":    strconv.FormatInt(channelID, 10),
+		"caption":    post.Content,
+		"parse_mode": "HTML",
+	}
+	if isSet(post.Options.DisableNotification) {
+		fields["disable_notification"] = "true"
+	}
+	if isSet(post.Options.ProtectContent) {
+		fields["protect_content"] = "true"
+	}
+	if post.Options.MessageThreadID != 0 {
+		fields["message_thread_id"] = strconv.Itoa(post.Options.MessageThreadID)
+	}
 
 	if len(post.Buttons) > 0 && string(post.Buttons) != "null" {
 		var buttons []models.Button
 		if err := json.Unmarshal(post.Buttons, &buttons); err == nil {
 			keyboard := c.createInlineKeyboard(buttons)
 			keyboardJSON, _ := json.Marshal(keyboard)
-			writer.WriteField("reply_markup", string(keyboardJSON))
+			fields["reply_markup"] = string(keyboardJSON)
 		}
 	}
 
-	writer.Close()
+	// Файл передаётся потоком из хранилища, не загружаясь в память целиком
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(writeMediaForm(form, fields, fieldName, path.Base(post.MediaPath), file))
+	}()
+	defer body.Close()
 
 	req, err := http.NewRequest("POST", c.APIURL+"/"+method, body)
 	if err != nil {
 		return 0, err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Content-Type", form.FormDataContentType())
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -155,8 +191,158 @@ func (c *Client) sendMediaMessage(channelID int64, post models.Post, fieldName,
 	return result.Result.MessageID, nil
 }
 
+// writeMediaForm пишет поля и файл в multipart-тело запроса.
+func writeMediaForm(form *multipart.Writer, fields map[string]string, fieldName, filename string, file io.Reader) error {
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+
+	part, err := form.CreateFormFile(fieldName, filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+
+	return form.Close()
+}
+
+// EditMessage заменяет текст (у медиа — подпись) и кнопки опубликованного
+// сообщения. Сам файл медиа не меняется.
+func (c *Client) EditMessage(channelID int64, messageID int, post models.Post) error {
+	payload := map[string]interface{}{
+		"chat_id":    strconv.FormatInt(channelID, 10),
+		"message_id": messageID,
+		"parse_mode": "HTML",
+	}
+
+	method := "editMessageText"
+	if post.MediaType == "text" {
+		payload["text"] = post.Content
+		if options := linkPreviewOptions(post.Options); options != nil {
+			payload["link_preview_options"] = options
+		}
+	} else {
+		method = "editMessageCaption"
+		payload["caption"] = post.Content
+	}
+
+	// Пустая клавиатура убирает кнопки, если их удалили из поста
+	var buttons []models.Button
+	if len(post.Buttons) > 0 && string(post.Buttons) != "null" {
+		json.Unmarshal(post.Buttons, &buttons)
+	}
+	payload["reply_markup"] = c.createInlineKeyboard(buttons)
+
+	resp, err := c.makeRequest(method, payload)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return err
+	}
+
+	// Telegram отвечает ошибкой, если содержимое не изменилось
+	if !result.OK && !strings.Contains(result.Description, "message is not modified") {
+		return fmt.Errorf("telegram API error: %s", result.Description)
+	}
+
+	return nil
+}
+
+// CopyMessage копирует сообщение messageID из чата fromChatID в чат chatID
+// без ссылки на источник и возвращает ID копии. Кнопки копируются вместе
+// с сообщением.
+func (c *Client) CopyMessage(chatID, fromChatID int64, messageID int, options models.SendOptions) (int, error) {
+	return c.resendMessage("copyMessage", chatID, fromChatID, messageID, options)
+}
+
+// ForwardMessage пересылает сообщение messageID из чата fromChatID в чат
+// chatID с указанием источника и возвращает ID пересланного сообщения.
+func (c *Client) ForwardMessage(chatID, fromChatID int64, messageID int, options models.SendOptions) (int, error) {
+	return c.resendMessage("forwardMessage", chatID, fromChatID, messageID, options)
+}
+
+// resendMessage вызывает copyMessage или forwardMessage. Из options
+// учитываются только тихая отправка, защита содержимого и тема форума.
+func (c *Client) resendMessage(method string, chatID, fromChatID int64, messageID int, options models.SendOptions) (int, error) {
+	payload := map[string]interface{}{
+		"chat_id":      strconv.FormatInt(chatID, 10),
+		"from_chat_id": strconv.FormatInt(fromChatID, 10),
+		"message_id":   messageID,
+	}
+	if isSet(options.DisableNotification) {
+		payload["disable_notification"] = true
+	}
+	if isSet(options.ProtectContent) {
+		payload["protect_content"] = true
+	}
+	if options.MessageThreadID != 0 {
+		payload["message_thread_id"] = options.MessageThreadID
+	}
+
+	resp, err := c.makeRequest(method, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      struct {
+			MessageID int `json:"message_id"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return 0, err
+	}
+	if !result.OK {
+		return 0, fmt.Errorf("telegram API error: %s", result.Description)
+	}
+	return result.Result.MessageID, nil
+}
+
+// isSet сообщает, включена ли необязательная настройка отправки.
+func isSet(option *bool) bool {
+	return option != nil && *option
+}
+
+// linkPreviewOptions возвращает link_preview_options для текстового
+// сообщения или nil, если превью остаётся на усмотрение Telegram.
+func linkPreviewOptions(options models.SendOptions) map[string]interface{} {
+	if isSet(options.DisableLinkPreview) {
+		return map[string]interface{}{"is_disabled": true}
+	}
+
+	preview := map[string]interface{}{}
+	if options.LinkPreviewURL != "" {
+		preview["url"] = options.LinkPreviewURL
+	}
+	switch options.LinkPreviewSize {
+	case models.LinkPreviewSmall:
+		preview["prefer_small_media"] = true
+	case models.LinkPreviewLarge:
+		preview["prefer_large_media"] = true
+	}
+	if isSet(options.LinkPreviewAbove) {
+		preview["show_above_text"] = true
+	}
+	if len(preview) == 0 {
+		return nil
+	}
+	return preview
+}
+
 func (c *Client) createInlineKeyboard(buttons []models.Button) map[string]interface{} {
-	var keyboard [][]map[string]string
+	keyboard := [][]map[string]string{}
 
 	for _, button := range buttons {
 		row := []map[string]string{
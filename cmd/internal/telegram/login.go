@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrLoginNoToken — токен бота не задан: ключ подписи SHA256("")
+	// известен всем, и подпись ничего не подтверждает
+	ErrLoginNoToken     = errors.New("telegram login: bot token is not configured")
+	ErrLoginInvalidHash = errors.New("telegram login: invalid hash")
+	ErrLoginExpired     = errors.New("telegram login: auth data expired")
+)
+
+// LoginUser — пользователь, подтверждённый через Telegram Login Widget.
+type LoginUser struct {
+	ID        int64
+	FirstName string
+	LastName  string
+	Username  string
+	PhotoURL  string
+	AuthDate  time.Time
+}
+
+// VerifyLogin проверяет данные Telegram Login Widget: подпись HMAC-SHA256 с
+// ключом SHA256(токен бота) и давность auth_date не больше maxAge.
+// См. https://core.telegram.org/widgets/login#checking-authorization
+func VerifyLogin(botToken string, data url.Values, maxAge time.Duration) (*LoginUser, error) {
+	if botToken == "" {
+		return nil, ErrLoginNoToken
+	}
+
+	hash := data.Get("hash")
+	if hash == "" {
+		return nil, ErrLoginInvalidHash
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		if key != "hash" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, key+"="+data.Get(key))
+	}
+
+	secret := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(hash))) {
+		return nil, ErrLoginInvalidHash
+	}
+
+	authDate, err := strconv.ParseInt(data.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, ErrLoginInvalidHash
+	}
+	if time.Since(time.Unix(authDate, 0)) > maxAge {
+		return nil, ErrLoginExpired
+	}
+
+	id, err := strconv.ParseInt(data.Get("id"), 10, 64)
+	if err != nil {
+		return nil, ErrLoginInvalidHash
+	}
+
+	return &LoginUser{
+		ID:        id,
+		FirstName: data.Get("first_name"),
+		LastName:  data.Get("last_name"),
+		Username:  data.Get("username"),
+		PhotoURL:  data.Get("photo_url"),
+		AuthDate:  time.Unix(authDate, 0),
+	}, nil
+}
@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Данные виджета, подписанные токеном testBotToken вне этого пакета, по
+// алгоритму из документации Telegram.
+const (
+	testBotToken = "123456:ABC-DEF1234ghIkl-zyx57W2v1u123ew11"
+	testHash     = "39263f6d369382ca31d507e7881503c29ff2f7cd591cb58804690e1ccef650de"
+	testAuthDate = 1700000000
+)
+
+// testMaxAge покрывает время с testAuthDate до запуска теста.
+const testMaxAge = 100 * 365 * 24 * time.Hour
+
+func loginData() url.Values {
+	return url.Values{
+		"id":         {"42"},
+		"first_name": {"Ann"},
+		"username":   {"ann"},
+		"auth_date":  {"1700000000"},
+		"hash":       {testHash},
+	}
+}
+
+func TestVerifyLogin(t *testing.T) {
+	user, err := VerifyLogin(testBotToken, loginData(), testMaxAge)
+	if err != nil {
+		t.Fatalf("valid signature: %v", err)
+	}
+	if user.ID != 42 || user.FirstName != "Ann" || user.Username != "ann" || user.AuthDate.Unix() != testAuthDate {
+		t.Errorf("user = %+v", user)
+	}
+
+	upper := loginData()
+	upper.Set("hash", strings.ToUpper(testHash))
+	if _, err := VerifyLogin(testBotToken, upper, testMaxAge); err != nil {
+		t.Errorf("upper-case hash: %v", err)
+	}
+
+	tampered := loginData()
+	tampered.Set("id", "43")
+	added := loginData()
+	added.Set("last_name", "Smith")
+	noHash := loginData()
+	noHash.Del("hash")
+
+	for _, tc := range []struct {
+		name   string
+		token  string
+		data   url.Values
+		maxAge time.Duration
+		want   error
+	}{
+		{"tampered field", testBotToken, tampered, testMaxAge, ErrLoginInvalidHash},
+		{"added field", testBotToken, added, testMaxAge, ErrLoginInvalidHash},
+		{"missing hash", testBotToken, noHash, testMaxAge, ErrLoginInvalidHash},
+		{"other bot token", "654321:other", loginData(), testMaxAge, ErrLoginInvalidHash},
+		{"empty bot token", "", loginData(), testMaxAge, ErrLoginNoToken},
+		{"expired auth_date", testBotToken, loginData(), time.Hour, ErrLoginExpired},
+	} {
+		if _, err := VerifyLogin(tc.token, tc.data, tc.maxAge); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Chat — чат Telegram. IsForum и LinkedChatID заполняются только в
+// ответе getChat: LinkedChatID у канала — его группа обсуждения, у группы
+// — канал, к которому она привязана.
+type Chat struct {
+	ID           int64  `json:"id"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	Username     string `json:"username"`
+	IsForum      bool   `json:"is_forum"`
+	LinkedChatID int64  `json:"linked_chat_id"`
+}
+
+// Update — обновление Bot API. Разбираются только сообщения групп.
+type Update struct {
+	UpdateID int      `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+// Message — поля сообщения, нужные для тем форумов и комментариев.
+type Message struct {
+	MessageID       int    `json:"message_id"`
+	MessageThreadID int    `json:"message_thread_id"`
+	Chat            Chat   `json:"chat"`
+	Text            string `json:"text"`
+	IsTopicMessage  bool   `json:"is_topic_message"`
+	// IsAutomaticForward — пост канала, автоматически пересланный в его
+	// группу обсуждения; ответы на него становятся комментариями к посту
+	IsAutomaticForward bool           `json:"is_automatic_forward"`
+	ForwardOrigin      *MessageOrigin `json:"forward_origin"`
+	ReplyToMessage     *Message       `json:"reply_to_message"`
+	ForumTopicCreated  *ForumTopic    `json:"forum_topic_created"`
+	ForumTopicEdited   *ForumTopic    `json:"forum_topic_edited"`
+}
+
+// MessageOrigin — источник пересланного сообщения. Chat и MessageID
+// заданы для постов каналов (Type == "channel").
+type MessageOrigin struct {
+	Type      string `json:"type"`
+	Chat      *Chat  `json:"chat"`
+	MessageID int    `json:"message_id"`
+}
+
+// ForumTopic — данные служебного сообщения о создании или изменении темы.
+// У forum_topic_edited Name пустое, если меняли только значок.
+type ForumTopic struct {
+	Name string `json:"name"`
+}
+
+// GetChat возвращает сведения о чате: является ли супергруппа форумом и
+// какая группа обсуждения привязана к каналу.
+func (c *Client) GetChat(chatID int64) (*Chat, error) {
+	resp, err := c.makeRequest("getChat", map[string]interface{}{
+		"chat_id": strconv.FormatInt(chatID, 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      Chat   `json:"result"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+	if !result.OK {
+		return nil, fmt.Errorf("telegram API error: %s", result.Description)
+	}
+	return &result.Result, nil
+}
+
+// GetUpdates ждёт обновления не дольше timeout и возвращает их, начиная с
+// offset. Обновления с меньшим ID Telegram считает обработанными.
+func (c *Client) GetUpdates(offset int, timeout time.Duration) ([]Update, error) {
+	resp, err := c.makeRequest("getUpdates", map[string]interface{}{
+		"offset":  offset,
+		"timeout": int(timeout.Seconds()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		OK          bool     `json:"ok"`
+		Description string   `json:"description"`
+		Result      []Update `json:"result"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+	if !result.OK {
+		return nil, fmt.Errorf("telegram API error: %s", result.Description)
+	}
+	return result.Result, nil
+}
+
+// Reply отправляет HTML-текст ответом на сообщение messageID и возвращает
+// ID ответа.
+func (c *Client) Reply(chatID int64, messageID int, text string) (int, error) {
+	resp, err := c.makeRequest("sendMessage", map[string]interface{}{
+		"chat_id":          strconv.FormatInt(chatID, 10),
+		"text":             text,
+		"parse_mode":       "HTML",
+		"reply_parameters": map[string]interface{}{"message_id": messageID},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      struct {
+			MessageID int `json:"message_id"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return 0, err
+	}
+	if !result.OK {
+		return 0, fmt.Errorf("telegram API error: %s", result.Description)
+	}
+	return result.Result.MessageID, nil
+}
@@ -0,0 +1,102 @@
+// Package templates подставляет переменные в текст и кнопки поста при
+// отправке в канал: {{date}}, {{channel.title}}, {{channel.username}} и
+// пользовательские поля поста.
+package templates
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+// Встроенные переменные.
+const (
+	VarDate            = "date"
+	VarChannelTitle    = "channel.title"
+	VarChannelUsername = "channel.username"
+)
+
+// DateLayout — формат {{date}}.
+const DateLayout = "02.01.2006"
+
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.]*)\s*\}\}`)
+
+// Builtin сообщает, что переменная подставляется автоматически.
+func Builtin(name string) bool {
+	switch name {
+	case VarDate, VarChannelTitle, VarChannelUsername:
+		return true
+	}
+	return false
+}
+
+// Variables возвращает имена пользовательских переменных из текстов в
+// порядке первого появления.
+func Variables(texts ...string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, text := range texts {
+		for _, match := range variablePattern.FindAllStringSubmatch(text, -1) {
+			name := match[1]
+			if !Builtin(name) && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Render возвращает пост с подставленными для канала переменными. Дата
+// берётся в часовом поясе канала; username — без @. Значения канала в
+// тексте экранируются, так как он размечен HTML, а пользовательские поля
+// подставляются как есть: их пишут так же, как сам текст. Неизвестные
+// переменные остаются без изменений.
+func Render(post models.Post, channel models.Channel, at time.Time) models.Post {
+	if loc, err := time.LoadLocation(channel.Timezone); err == nil && channel.Timezone != "" {
+		at = at.In(loc)
+	}
+	values := map[string]string{
+		VarDate:            at.Format(DateLayout),
+		VarChannelTitle:    channel.Title,
+		VarChannelUsername: strings.TrimPrefix(channel.Username, "@"),
+	}
+
+	post.Content = replace(post.Content, values, post.Variables, html.EscapeString)
+
+	var buttons []models.Button
+	if len(post.Buttons) > 0 && json.Unmarshal(post.Buttons, &buttons) == nil && len(buttons) > 0 {
+		for i := range buttons {
+			buttons[i].Text = replace(buttons[i].Text, values, post.Variables, nil)
+			buttons[i].URL = replace(buttons[i].URL, values, post.Variables, nil)
+		}
+		post.Buttons, _ = json.Marshal(buttons)
+	}
+
+	return post
+}
+
+// replace подставляет встроенные значения builtin, экранируя их escape,
+// и пользовательские custom.
+func replace(text string, builtin, custom map[string]string, escape func(string) string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	return variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		if value, ok := builtin[name]; ok {
+			if escape != nil {
+				return escape(value)
+			}
+			return value
+		}
+		if value, ok := custom[name]; ok {
+			return value
+		}
+		return match
+	})
+}
@@ -0,0 +1,45 @@
+package templates
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+func TestVariables(t *testing.T) {
+	got := Variables("{{date}} {{ topic }} {{channel.title}}", "{{price}} {{topic}} {{}}")
+	if want := []string{"topic", "price"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables = %v, want %v", got, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	channel := models.Channel{Title: "Tom & Jerry", Username: "@tomjerry", Timezone: "Asia/Tokyo"}
+	post := models.Post{
+		Content:   "<b>{{date}}</b> {{channel.title}} @{{channel.username}}: {{lead}} {{topic}} {{unknown}}",
+		Buttons:   json.RawMessage(`[{"text":"{{channel.title}}","url":"https://t.me/{{channel.username}}?q={{topic}}"}]`),
+		Variables: map[string]string{"topic": "news", "lead": "<i>hot</i>"},
+	}
+	// В Токио уже следующий день
+	at := time.Date(2026, 3, 9, 20, 0, 0, 0, time.UTC)
+
+	got := Render(post, channel, at)
+	want := "<b>10.03.2026</b> Tom &amp; Jerry @tomjerry: <i>hot</i> news {{unknown}}"
+	if got.Content != want {
+		t.Errorf("Content = %q, want %q", got.Content, want)
+	}
+
+	var buttons []models.Button
+	json.Unmarshal(got.Buttons, &buttons)
+	if len(buttons) != 1 || buttons[0].Text != "Tom & Jerry" || buttons[0].URL != "https://t.me/tomjerry?q=news" {
+		t.Errorf("Buttons = %+v", buttons)
+	}
+
+	// Исходный пост не меняется
+	if post.Content == got.Content || string(post.Buttons) == string(got.Buttons) {
+		t.Error("Render modified the original post")
+	}
+}
@@ -0,0 +1,178 @@
+// Package updates читает обновления бота через getUpdates: запоминает темы
+// форумов, в которые можно отправлять посты, и оставляет комментарии к
+// постам в группах обсуждения каналов.
+package updates
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+	"github.com/maksekak/channelBot/cmd/internal/telegram"
+	"github.com/maksekak/channelBot/cmd/internal/templates"
+)
+
+const (
+	// pollTimeout — сколько Telegram держит запрос getUpdates без обновлений
+	pollTimeout = 30 * time.Second
+	// retryDelay — пауза после ошибки, например пока у бота задан webhook
+	retryDelay = 10 * time.Second
+)
+
+type Poller struct {
+	storage  storage.Storage
+	telegram *telegram.Client
+	audit    *audit.Logger
+	offset   int
+}
+
+func New(storage storage.Storage, telegram *telegram.Client) *Poller {
+	return &Poller{storage: storage, telegram: telegram, audit: audit.New(storage)}
+}
+
+// Run читает обновления, пока не отменён ctx. Прочитанные обновления
+// подтверждаются и другим получателям уже не достанутся.
+func (p *Poller) Run(ctx context.Context) {
+	log.Println("Polling Telegram updates")
+	for ctx.Err() == nil {
+		updates, err := p.telegram.GetUpdates(p.offset, pollTimeout)
+		if err != nil {
+			log.Printf("Error getting updates: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			p.offset = update.UpdateID + 1
+			p.Handle(ctx, update)
+		}
+	}
+}
+
+// Handle обрабатывает одно обновление.
+func (p *Poller) Handle(ctx context.Context, update telegram.Update) {
+	message := update.Message
+	if message == nil {
+		return
+	}
+
+	if topic, ok := forumTopic(message); ok {
+		p.saveForumTopic(ctx, topic)
+	}
+	if message.IsAutomaticForward {
+		p.comment(ctx, message)
+	}
+}
+
+// saveForumTopic запоминает тему форума. В журнал попадают новые темы и
+// переименования, а не каждое сообщение в известной теме.
+func (p *Poller) saveForumTopic(ctx context.Context, topic models.ForumTopic) {
+	topics, err := p.storage.GetForumTopics(ctx, topic.ChatID)
+	if err != nil {
+		log.Printf("Error loading forum topics of chat %d: %v", topic.ChatID, err)
+		return
+	}
+	var before interface{}
+	for _, known := range topics {
+		if known.ThreadID != topic.ThreadID {
+			continue
+		}
+		if known.Name == topic.Name {
+			return
+		}
+		before = known
+	}
+
+	if err := p.storage.SaveForumTopic(ctx, &topic); err != nil {
+		log.Printf("Error saving forum topic %d in chat %d: %v", topic.ThreadID, topic.ChatID, err)
+		return
+	}
+	p.audit.Record(ctx, audit.Event{
+		Actor:      audit.ActorBot,
+		Action:     audit.ActionForumTopicSave,
+		TargetType: audit.TargetForumTopic,
+		TargetID:   topic.ThreadID,
+		Before:     before,
+		After:      topic,
+	})
+}
+
+// forumTopic извлекает тему форума из служебного сообщения о её создании
+// или переименовании либо из сообщения в теме: оно отвечает на сообщение
+// о создании темы.
+func forumTopic(message *telegram.Message) (models.ForumTopic, bool) {
+	topic := models.ForumTopic{ChatID: message.Chat.ID, ThreadID: message.MessageThreadID}
+	switch {
+	case message.ForumTopicCreated != nil:
+		topic.Name = message.ForumTopicCreated.Name
+		if topic.ThreadID == 0 {
+			topic.ThreadID = message.MessageID
+		}
+	case message.ForumTopicEdited != nil:
+		topic.Name = message.ForumTopicEdited.Name
+	case message.IsTopicMessage && message.ReplyToMessage != nil && message.ReplyToMessage.ForumTopicCreated != nil:
+		topic.Name = message.ReplyToMessage.ForumTopicCreated.Name
+	}
+	return topic, topic.ThreadID != 0 && topic.Name != ""
+}
+
+// comment отвечает комментарием канала на пост, который Telegram переслал
+// в группу обсуждения, если доставка этого поста ждёт комментария.
+func (p *Poller) comment(ctx context.Context, message *telegram.Message) {
+	origin := message.ForwardOrigin
+	if origin == nil || origin.Type != "channel" || origin.Chat == nil {
+		return
+	}
+
+	pc, err := p.storage.GetPendingComment(ctx, origin.Chat.ID, origin.MessageID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding delivery of message %d in chat %d: %v", origin.MessageID, origin.Chat.ID, err)
+		return
+	}
+
+	channel, err := p.storage.GetChannel(ctx, pc.ChannelID)
+	switch {
+	case err != nil:
+		pc.CommentStatus = "error"
+		pc.CommentError = err.Error()
+	case channel.DiscussionComment == "":
+		// Комментарий убрали из настроек канала после отправки
+		pc.CommentStatus = ""
+	default:
+		// В комментарии доступны переменные канала и поста
+		comment := models.Post{Content: channel.DiscussionComment, MediaType: "text"}
+		if post, err := p.storage.GetPost(ctx, pc.PostID); err == nil {
+			comment.Variables = post.Variables
+		}
+		text := templates.Render(comment, *channel, pc.SentAt).Content
+		pc.CommentMessageID, err = p.telegram.Reply(message.Chat.ID, message.MessageID, text)
+		pc.CommentStatus = "sent"
+		if err != nil {
+			pc.CommentStatus = "error"
+			pc.CommentError = err.Error()
+			log.Printf("Error commenting post in chat %d: %v", message.Chat.ID, err)
+		}
+	}
+
+	if err := p.storage.UpdatePostChannelComment(ctx, pc); err != nil {
+		log.Printf("Error saving comment of delivery %d: %v", pc.ID, err)
+		return
+	}
+	p.audit.Record(ctx, audit.Event{
+		Actor:      audit.ActorBot,
+		Action:     audit.ActionPostCommented,
+		TargetType: audit.TargetPost,
+		TargetID:   pc.PostID,
+		After:      pc,
+	})
+}
@@ -0,0 +1,151 @@
+package updates
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/maksekak/channelBot/cmd/internal/audit"
+	"github.com/maksekak/channelBot/cmd/internal/models"
+	"github.com/maksekak/channelBot/cmd/internal/storage"
+	"github.com/maksekak/channelBot/cmd/internal/telegram"
+)
+
+// fakeTelegram отвечает на sendMessage как Bot API и запоминает запросы.
+type fakeTelegram struct {
+	mu       sync.Mutex
+	payloads []map[string]interface{}
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&payload)
+
+	f.mu.Lock()
+	f.payloads = append(f.payloads, payload)
+	f.mu.Unlock()
+
+	w.Write([]byte(`{"ok":true,"result":{"message_id":55}}`))
+}
+
+func newTestPoller(t *testing.T, fake *fakeTelegram) (*Poller, *storage.MemoryStorage) {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := telegram.NewClient("test")
+	client.APIURL = server.URL
+
+	store := storage.NewMemoryStorage()
+	return New(store, client), store
+}
+
+func TestHandleForumTopics(t *testing.T) {
+	p, store := newTestPoller(t, &fakeTelegram{})
+	ctx := context.Background()
+	forum := telegram.Chat{ID: -100500, Type: "supergroup"}
+
+	for _, message := range []telegram.Message{
+		{MessageID: 3, MessageThreadID: 3, Chat: forum, ForumTopicCreated: &telegram.ForumTopic{Name: "News"}},
+		{MessageID: 9, MessageThreadID: 7, Chat: forum, IsTopicMessage: true, Text: "hi",
+			ReplyToMessage: &telegram.Message{MessageID: 7, Chat: forum, ForumTopicCreated: &telegram.ForumTopic{Name: "Chat"}}},
+		{MessageID: 10, MessageThreadID: 3, Chat: forum, ForumTopicEdited: &telegram.ForumTopic{Name: "Announcements"}},
+		{MessageID: 11, MessageThreadID: 7, Chat: forum, ForumTopicEdited: &telegram.ForumTopic{}},
+		{MessageID: 12, Chat: forum, Text: "general chat"},
+	} {
+		message := message
+		p.Handle(ctx, telegram.Update{Message: &message})
+	}
+
+	topics, _ := store.GetForumTopics(ctx, forum.ID)
+	if len(topics) != 2 || topics[0].ThreadID != 3 || topics[0].Name != "Announcements" ||
+		topics[1].ThreadID != 7 || topics[1].Name != "Chat" {
+		t.Errorf("topics = %+v", topics)
+	}
+}
+
+func TestHandleDiscussionComment(t *testing.T) {
+	fake := &fakeTelegram{}
+	p, store := newTestPoller(t, fake)
+	ctx := context.Background()
+
+	channel := models.Channel{TelegramID: -100600, Title: "News", Username: "news", IsActive: true,
+		DiscussionComment: "Discuss {{price}} in @{{channel.username}}"}
+	store.CreateChannel(ctx, &channel)
+	post := models.Post{Content: "post", MediaType: "text", Status: "sent", Variables: map[string]string{"price": "100"}}
+	store.CreatePost(ctx, &post)
+	pc := models.PostChannel{PostID: post.ID, ChannelID: channel.ID, MessageID: 21, Status: "sent", CommentStatus: models.CommentPending}
+	store.CreatePostChannel(ctx, &pc)
+
+	group := telegram.Chat{ID: -100601, Type: "supergroup"}
+	forward := func(messageID int) telegram.Update {
+		return telegram.Update{Message: &telegram.Message{
+			MessageID:          messageID,
+			Chat:               group,
+			IsAutomaticForward: true,
+			ForwardOrigin:      &telegram.MessageOrigin{Type: "channel", Chat: &telegram.Chat{ID: channel.TelegramID}, MessageID: 21},
+		}}
+	}
+
+	p.Handle(ctx, forward(300))
+	// Повторная пересылка того же поста второй комментарий не оставляет
+	p.Handle(ctx, forward(301))
+
+	if len(fake.payloads) != 1 {
+		t.Fatalf("telegram calls = %d, want 1", len(fake.payloads))
+	}
+	payload := fake.payloads[0]
+	if payload["chat_id"] != "-100601" || payload["text"] != "Discuss 100 in @news" {
+		t.Errorf("comment = %v", payload)
+	}
+	if reply, _ := payload["reply_parameters"].(map[string]interface{}); reply["message_id"] != float64(300) {
+		t.Errorf("reply_parameters = %v, want reply to the forwarded post", payload["reply_parameters"])
+	}
+
+	got, _ := store.GetPostChannel(ctx, pc.ID)
+	if got.CommentStatus != "sent" || got.CommentMessageID != 55 {
+		t.Errorf("delivery = %+v, want comment sent", got)
+	}
+}
+
+func TestHandleRecordsAudit(t *testing.T) {
+	fake := &fakeTelegram{}
+	p, store := newTestPoller(t, fake)
+	ctx := context.Background()
+	forum := telegram.Chat{ID: -100500, Type: "supergroup"}
+
+	for _, message := range []telegram.Message{
+		{MessageID: 3, MessageThreadID: 3, Chat: forum, ForumTopicCreated: &telegram.ForumTopic{Name: "News"}},
+		{MessageID: 4, MessageThreadID: 3, Chat: forum, IsTopicMessage: true, Text: "hi",
+			ReplyToMessage: &telegram.Message{MessageID: 3, Chat: forum, ForumTopicCreated: &telegram.ForumTopic{Name: "News"}}},
+		{MessageID: 5, MessageThreadID: 3, Chat: forum, ForumTopicEdited: &telegram.ForumTopic{Name: "Announcements"}},
+	} {
+		message := message
+		p.Handle(ctx, telegram.Update{Message: &message})
+	}
+
+	topicEntries, _ := store.ListAuditEntries(ctx, models.AuditFilter{TargetType: audit.TargetForumTopic, Limit: 10})
+	if len(topicEntries) != 2 || topicEntries[0].Actor != audit.ActorBot || topicEntries[0].TargetID != 3 ||
+		len(topicEntries[0].Before) == 0 || len(topicEntries[1].Before) != 0 {
+		t.Errorf("forum topic entries = %+v, want the creation and the rename", topicEntries)
+	}
+
+	channel := models.Channel{TelegramID: -100600, Title: "News", IsActive: true, DiscussionComment: "Discuss"}
+	store.CreateChannel(ctx, &channel)
+	pc := models.PostChannel{PostID: 8, ChannelID: channel.ID, MessageID: 21, Status: "sent", CommentStatus: models.CommentPending}
+	store.CreatePostChannel(ctx, &pc)
+
+	p.Handle(ctx, telegram.Update{Message: &telegram.Message{
+		MessageID: 70, Chat: telegram.Chat{ID: -100700}, IsAutomaticForward: true,
+		ForwardOrigin: &telegram.MessageOrigin{Type: "channel", Chat: &telegram.Chat{ID: -100600}, MessageID: 21},
+	}})
+
+	comments, _ := store.ListAuditEntries(ctx, models.AuditFilter{Action: audit.ActionPostCommented, Limit: 10})
+	if len(comments) != 1 || comments[0].Actor != audit.ActorBot || comments[0].TargetID != 8 {
+		t.Errorf("comment entries = %+v", comments)
+	}
+}
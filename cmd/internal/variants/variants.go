@@ -0,0 +1,70 @@
+// Package variants распределяет варианты текста поста по каналам: часть
+// вариантов закреплена за каналами, остальные случайно делят оставшиеся
+// каналы для A/B-теста.
+package variants
+
+import (
+	"math/rand"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+// Assign возвращает имя варианта для каждого канала (ID канала → имя,
+// пустое имя — основной текст поста). Каналы, закреплённые за вариантом,
+// получают его. Остальные каналы перемешиваются и по очереди раздаются
+// основному тексту и вариантам без каналов, чтобы группы A/B-теста были
+// равными.
+func Assign(variants []models.PostVariant, channels []models.Channel) map[int]string {
+	assigned := make(map[int]string, len(channels))
+
+	pinned := make(map[int]string)
+	split := []string{""}
+	for _, v := range variants {
+		if len(v.ChannelIDs) == 0 {
+			split = append(split, v.Name)
+			continue
+		}
+		for _, id := range v.ChannelIDs {
+			if _, ok := pinned[id]; !ok {
+				pinned[id] = v.Name
+			}
+		}
+	}
+
+	var rest []int
+	for _, channel := range channels {
+		if name, ok := pinned[channel.ID]; ok {
+			assigned[channel.ID] = name
+		} else {
+			rest = append(rest, channel.ID)
+		}
+	}
+
+	rand.Shuffle(len(rest), func(i, j int) { rest[i], rest[j] = rest[j], rest[i] })
+	// Первым получает канал случайный вариант, иначе при нечётном числе
+	// каналов лишний всегда доставался бы первому варианту
+	offset := rand.Intn(len(split))
+	for i, id := range rest {
+		assigned[id] = split[(i+offset)%len(split)]
+	}
+	return assigned
+}
+
+// Apply возвращает пост с текстом и кнопками варианта name. Пустое или
+// неизвестное имя оставляет основной текст.
+func Apply(post models.Post, name string) models.Post {
+	if name == "" {
+		return post
+	}
+	for _, v := range post.Variants {
+		if v.Name != name {
+			continue
+		}
+		post.Content = v.Content
+		if len(v.Buttons) > 0 {
+			post.Buttons = v.Buttons
+		}
+		break
+	}
+	return post
+}
@@ -0,0 +1,77 @@
+package variants
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/maksekak/channelBot/cmd/internal/models"
+)
+
+func channels(ids ...int) []models.Channel {
+	list := make([]models.Channel, len(ids))
+	for i, id := range ids {
+		list[i] = models.Channel{ID: id}
+	}
+	return list
+}
+
+func TestAssignPinned(t *testing.T) {
+	variants := []models.PostVariant{
+		{Name: "ru", ChannelIDs: []int{1, 2}},
+		{Name: "en", ChannelIDs: []int{3}},
+	}
+
+	got := Assign(variants, channels(1, 2, 3, 4))
+	want := map[int]string{1: "ru", 2: "ru", 3: "en", 4: ""}
+	for id, name := range want {
+		if got[id] != name {
+			t.Errorf("channel %d = %q, want %q", id, got[id], name)
+		}
+	}
+}
+
+func TestAssignSplit(t *testing.T) {
+	variants := []models.PostVariant{
+		{Name: "B"},
+		{Name: "pinned", ChannelIDs: []int{1}},
+	}
+
+	for i := 0; i < 20; i++ {
+		got := Assign(variants, channels(1, 2, 3, 4, 5))
+		if got[1] != "pinned" {
+			t.Fatalf("pinned channel = %q", got[1])
+		}
+
+		counts := make(map[string]int)
+		for id := 2; id <= 5; id++ {
+			counts[got[id]]++
+		}
+		if counts[""] != 2 || counts["B"] != 2 {
+			t.Errorf("split = %v, want 2 and 2", counts)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	post := models.Post{
+		Content: "main",
+		Buttons: json.RawMessage(`[{"text":"Main","url":"https://example.com"}]`),
+		Variants: []models.PostVariant{
+			{Name: "A", Content: "variant A"},
+			{Name: "B", Content: "variant B", Buttons: json.RawMessage(`[{"text":"B","url":"https://example.com/b"}]`)},
+		},
+	}
+
+	if got := Apply(post, ""); got.Content != "main" {
+		t.Errorf("Apply(\"\") = %q", got.Content)
+	}
+	if got := Apply(post, "A"); got.Content != "variant A" || string(got.Buttons) != string(post.Buttons) {
+		t.Errorf("Apply(A) = %q %s", got.Content, got.Buttons)
+	}
+	if got := Apply(post, "B"); got.Content != "variant B" || string(got.Buttons) == string(post.Buttons) {
+		t.Errorf("Apply(B) = %q %s", got.Content, got.Buttons)
+	}
+	if got := Apply(post, "missing"); got.Content != "main" {
+		t.Errorf("Apply(missing) = %q", got.Content)
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS post_channels;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS channels;
@@ -0,0 +1,14 @@
+CREATE TABLE post_revisions (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL,
+    content TEXT,
+    media_type VARCHAR(50),
+    media_path VARCHAR(500),
+    buttons JSONB,
+    schedule_time TIMESTAMP,
+    author VARCHAR(255),
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (post_id, number)
+);
@@ -0,0 +1,17 @@
+DELETE FROM post_channels WHERE post_id IS NULL OR channel_id IS NULL;
+
+ALTER TABLE post_channels
+    DROP CONSTRAINT post_channels_post_id_fkey,
+    ADD CONSTRAINT post_channels_post_id_fkey FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE;
+ALTER TABLE post_channels
+    DROP CONSTRAINT post_channels_channel_id_fkey,
+    ADD CONSTRAINT post_channels_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE CASCADE;
+
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+DELETE FROM channels WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+DROP INDEX IF EXISTS idx_channels_deleted_at;
+
+ALTER TABLE posts DROP COLUMN deleted_at;
+ALTER TABLE channels DROP COLUMN deleted_at;
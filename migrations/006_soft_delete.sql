@@ -0,0 +1,13 @@
+ALTER TABLE channels ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_channels_deleted_at ON channels(deleted_at);
+CREATE INDEX idx_posts_deleted_at ON posts(deleted_at);
+
+-- История доставок переживает окончательное удаление поста или канала
+ALTER TABLE post_channels
+    DROP CONSTRAINT post_channels_post_id_fkey,
+    ADD CONSTRAINT post_channels_post_id_fkey FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE SET NULL;
+ALTER TABLE post_channels
+    DROP CONSTRAINT post_channels_channel_id_fkey,
+    ADD CONSTRAINT post_channels_channel_id_fkey FOREIGN KEY (channel_id) REFERENCES channels(id) ON DELETE SET NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_created_by;
+DROP INDEX IF EXISTS idx_posts_sent_at;
+DROP INDEX IF EXISTS idx_posts_created_at;
+DROP INDEX IF EXISTS idx_posts_content_en;
+DROP INDEX IF EXISTS idx_posts_content_ru;
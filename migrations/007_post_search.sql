@@ -0,0 +1,7 @@
+-- Полнотекстовый поиск по содержимому постов на русском и английском
+CREATE INDEX idx_posts_content_ru ON posts USING GIN (to_tsvector('russian', COALESCE(content, '')));
+CREATE INDEX idx_posts_content_en ON posts USING GIN (to_tsvector('english', COALESCE(content, '')));
+
+CREATE INDEX idx_posts_created_at ON posts(created_at, id);
+CREATE INDEX idx_posts_sent_at ON posts(sent_at, id);
+CREATE INDEX idx_posts_created_by ON posts(created_by);
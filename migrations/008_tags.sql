@@ -0,0 +1,18 @@
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    -- Добавлять #name в конец текста при отправке
+    hashtag BOOLEAN NOT NULL DEFAULT false,
+    -- Каналы по умолчанию для постов с тегом; NULL — все активные
+    channel_ids JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT tags_name_key UNIQUE (name)
+);
+
+CREATE TABLE post_tags (
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE INDEX idx_post_tags_tag_id ON post_tags(tag_id);
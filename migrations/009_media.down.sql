@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_post_revisions_media_path;
+DROP INDEX IF EXISTS idx_posts_media_path;
+DROP TABLE IF EXISTS media;
@@ -0,0 +1,20 @@
+CREATE TABLE media (
+    id SERIAL PRIMARY KEY,
+    -- SHA-256 содержимого, по нему повторные загрузки находят готовый файл
+    hash CHAR(64) NOT NULL,
+    path VARCHAR(500) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    -- Длительность видео в секундах
+    duration INTEGER,
+    uploaded_by VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT media_hash_key UNIQUE (hash)
+);
+
+CREATE INDEX idx_media_created_at ON media(created_at, id);
+CREATE INDEX idx_posts_media_path ON posts(media_path);
+CREATE INDEX idx_post_revisions_media_path ON post_revisions(media_path);
@@ -0,0 +1,4 @@
+-- Пути файлов становятся ключами хранилища медиа относительно MEDIA_DIR
+UPDATE posts SET media_path = substr(media_path, 20) WHERE media_path LIKE 'web/assets/uploads/%';
+UPDATE post_revisions SET media_path = substr(media_path, 20) WHERE media_path LIKE 'web/assets/uploads/%';
+UPDATE media SET path = substr(path, 20) WHERE path LIKE 'web/assets/uploads/%';
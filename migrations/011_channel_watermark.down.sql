@@ -0,0 +1,2 @@
+ALTER TABLE channels DROP COLUMN watermark_position;
+ALTER TABLE channels DROP COLUMN watermark_path;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS post_templates;
+ALTER TABLE posts DROP COLUMN variables;
@@ -0,0 +1,19 @@
+-- Значения пользовательских переменных поста, подставляемые при отправке
+ALTER TABLE posts ADD COLUMN variables JSONB;
+
+CREATE TABLE post_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    content TEXT,
+    media_type VARCHAR(50) NOT NULL DEFAULT 'text',
+    media_path VARCHAR(500),
+    buttons JSONB,
+    -- Пользовательские переменные со значениями по умолчанию
+    variables JSONB,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT post_templates_name_key UNIQUE (name)
+);
+
+CREATE INDEX idx_post_templates_media_path ON post_templates(media_path);
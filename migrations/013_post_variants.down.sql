@@ -0,0 +1,2 @@
+ALTER TABLE post_channels DROP COLUMN IF EXISTS variant;
+ALTER TABLE posts DROP COLUMN IF EXISTS variants;
@@ -0,0 +1,5 @@
+-- Варианты текста поста для разных каналов и A/B-тестов
+ALTER TABLE posts ADD COLUMN variants JSONB;
+
+-- Вариант, отправленный в канал; пустой — основной текст поста
+ALTER TABLE post_channels ADD COLUMN variant VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS send_options;
+
+ALTER TABLE channels DROP COLUMN IF EXISTS disable_link_preview;
+ALTER TABLE channels DROP COLUMN IF EXISTS disable_notification;
+ALTER TABLE channels DROP COLUMN IF EXISTS buttons;
+ALTER TABLE channels DROP COLUMN IF EXISTS footer;
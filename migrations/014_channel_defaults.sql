@@ -0,0 +1,8 @@
+-- Подпись, кнопки и настройки отправки канала по умолчанию
+ALTER TABLE channels ADD COLUMN footer TEXT;
+ALTER TABLE channels ADD COLUMN buttons JSONB;
+ALTER TABLE channels ADD COLUMN disable_notification BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE channels ADD COLUMN disable_link_preview BOOLEAN NOT NULL DEFAULT false;
+
+-- Переопределения настроек канала для поста
+ALTER TABLE posts ADD COLUMN send_options JSONB;
@@ -0,0 +1,3 @@
+ALTER TABLE channels DROP COLUMN IF EXISTS link_preview_size;
+ALTER TABLE channels DROP COLUMN IF EXISTS link_preview_above;
+ALTER TABLE channels DROP COLUMN IF EXISTS protect_content;
@@ -0,0 +1,4 @@
+-- Защита от пересылки и вид превью ссылок в канале по умолчанию
+ALTER TABLE channels ADD COLUMN protect_content BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE channels ADD COLUMN link_preview_above BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE channels ADD COLUMN link_preview_size VARCHAR(10);
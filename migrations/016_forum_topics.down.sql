@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS forum_topics;
+
+DROP INDEX IF EXISTS idx_post_channels_pending_comments;
+ALTER TABLE post_channels DROP COLUMN IF EXISTS comment_error;
+ALTER TABLE post_channels DROP COLUMN IF EXISTS comment_message_id;
+ALTER TABLE post_channels DROP COLUMN IF EXISTS comment_status;
+ALTER TABLE post_channels DROP COLUMN IF EXISTS message_thread_id;
+
+ALTER TABLE channels DROP COLUMN IF EXISTS discussion_comment;
+ALTER TABLE channels DROP COLUMN IF EXISTS message_thread_id;
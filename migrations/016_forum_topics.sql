@@ -0,0 +1,21 @@
+-- Тема форума, в которую отправляются посты, и комментарий к посту в
+-- группе обсуждения канала
+ALTER TABLE channels ADD COLUMN message_thread_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE channels ADD COLUMN discussion_comment TEXT;
+
+-- Тема доставки и состояние комментария в группе обсуждения
+ALTER TABLE post_channels ADD COLUMN message_thread_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE post_channels ADD COLUMN comment_status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE post_channels ADD COLUMN comment_message_id INTEGER;
+ALTER TABLE post_channels ADD COLUMN comment_error TEXT;
+
+CREATE INDEX idx_post_channels_pending_comments ON post_channels(message_id) WHERE comment_status = 'pending';
+
+-- Темы форумов, замеченные в обновлениях Bot API
+CREATE TABLE forum_topics (
+    chat_id BIGINT NOT NULL,
+    thread_id INTEGER NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, thread_id)
+);
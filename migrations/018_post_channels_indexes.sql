@@ -0,0 +1,4 @@
+-- Индексы доставок по посту и каналу для выборок доставок поста и
+-- статистики канала
+CREATE INDEX idx_post_channels_post_id ON post_channels(post_id);
+CREATE INDEX idx_post_channels_channel_id ON post_channels(channel_id);
@@ -0,0 +1,13 @@
+// Package migrations встраивает SQL-миграции в бинарник.
+//
+// Файлы NNN_name.sql применяются при обновлении схемы, NNN_name.down.sql
+// откатывают их. Миграции PostgreSQL лежат в корне каталога, SQLite — в sqlite/.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var Postgres embed.FS
+
+//go:embed sqlite/*.sql
+var SQLite embed.FS
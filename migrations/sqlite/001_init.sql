@@ -0,0 +1,36 @@
+CREATE TABLE channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    telegram_id INTEGER NOT NULL,
+    username TEXT,
+    title TEXT NOT NULL,
+    is_active BOOLEAN DEFAULT 1,
+    timezone TEXT DEFAULT 'Europe/Moscow',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE posts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    content TEXT,
+    media_type TEXT DEFAULT 'text',
+    media_path TEXT,
+    buttons TEXT,
+    schedule_time TIMESTAMP,
+    status TEXT DEFAULT 'draft',
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+CREATE TABLE post_channels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+    channel_id INTEGER REFERENCES channels(id) ON DELETE CASCADE,
+    message_id INTEGER,
+    status TEXT DEFAULT 'sent',
+    error TEXT,
+    sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_posts_status ON posts(status);
+CREATE INDEX idx_posts_schedule_time ON posts(schedule_time);
+CREATE INDEX idx_post_channels_sent_at ON post_channels(sent_at);
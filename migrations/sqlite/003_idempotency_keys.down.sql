@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_idempotency_key;
+ALTER TABLE posts DROP COLUMN send_idempotency_key;
+ALTER TABLE posts DROP COLUMN idempotency_key;
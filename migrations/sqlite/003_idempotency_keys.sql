@@ -0,0 +1,4 @@
+ALTER TABLE posts ADD COLUMN idempotency_key TEXT;
+ALTER TABLE posts ADD COLUMN send_idempotency_key TEXT;
+
+CREATE UNIQUE INDEX idx_posts_idempotency_key ON posts(created_by, idempotency_key) WHERE idempotency_key IS NOT NULL;
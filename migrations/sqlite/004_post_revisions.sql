@@ -0,0 +1,14 @@
+CREATE TABLE post_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL,
+    content TEXT,
+    media_type TEXT,
+    media_path TEXT,
+    buttons TEXT,
+    schedule_time TIMESTAMP,
+    author TEXT,
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (post_id, number)
+);
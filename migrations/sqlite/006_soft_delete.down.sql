@@ -0,0 +1,27 @@
+CREATE TABLE post_channels_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+    channel_id INTEGER REFERENCES channels(id) ON DELETE CASCADE,
+    message_id INTEGER,
+    status TEXT DEFAULT 'sent',
+    error TEXT,
+    sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO post_channels_old (id, post_id, channel_id, message_id, status, error, sent_at)
+SELECT id, post_id, channel_id, message_id, status, error, sent_at FROM post_channels
+WHERE post_id IS NOT NULL AND channel_id IS NOT NULL;
+
+DROP TABLE post_channels;
+ALTER TABLE post_channels_old RENAME TO post_channels;
+
+CREATE INDEX idx_post_channels_sent_at ON post_channels(sent_at);
+
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+DELETE FROM channels WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+DROP INDEX IF EXISTS idx_channels_deleted_at;
+
+ALTER TABLE posts DROP COLUMN deleted_at;
+ALTER TABLE channels DROP COLUMN deleted_at;
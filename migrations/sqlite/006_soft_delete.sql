@@ -0,0 +1,25 @@
+ALTER TABLE channels ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_channels_deleted_at ON channels(deleted_at);
+CREATE INDEX idx_posts_deleted_at ON posts(deleted_at);
+
+-- История доставок переживает окончательное удаление поста или канала.
+-- SQLite не меняет внешние ключи, поэтому таблица пересоздаётся.
+CREATE TABLE post_channels_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER REFERENCES posts(id) ON DELETE SET NULL,
+    channel_id INTEGER REFERENCES channels(id) ON DELETE SET NULL,
+    message_id INTEGER,
+    status TEXT DEFAULT 'sent',
+    error TEXT,
+    sent_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO post_channels_new (id, post_id, channel_id, message_id, status, error, sent_at)
+SELECT id, post_id, channel_id, message_id, status, error, sent_at FROM post_channels;
+
+DROP TABLE post_channels;
+ALTER TABLE post_channels_new RENAME TO post_channels;
+
+CREATE INDEX idx_post_channels_sent_at ON post_channels(sent_at);
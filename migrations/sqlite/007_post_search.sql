@@ -0,0 +1,3 @@
+CREATE INDEX idx_posts_created_at ON posts(created_at, id);
+CREATE INDEX idx_posts_sent_at ON posts(sent_at, id);
+CREATE INDEX idx_posts_created_by ON posts(created_by);
@@ -0,0 +1,19 @@
+CREATE TABLE media (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    -- SHA-256 содержимого, по нему повторные загрузки находят готовый файл
+    hash TEXT NOT NULL UNIQUE,
+    path TEXT NOT NULL,
+    filename TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    -- Длительность видео в секундах
+    duration INTEGER,
+    uploaded_by TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_media_created_at ON media(created_at, id);
+CREATE INDEX idx_posts_media_path ON posts(media_path);
+CREATE INDEX idx_post_revisions_media_path ON post_revisions(media_path);
@@ -0,0 +1,3 @@
+UPDATE posts SET media_path = 'web/assets/uploads/' || media_path WHERE media_path <> '';
+UPDATE post_revisions SET media_path = 'web/assets/uploads/' || media_path WHERE media_path <> '';
+UPDATE media SET path = 'web/assets/uploads/' || path;
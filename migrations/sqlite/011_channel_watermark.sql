@@ -0,0 +1,3 @@
+-- Водяной знак канала: ключ логотипа в хранилище медиа и его положение
+ALTER TABLE channels ADD COLUMN watermark_path TEXT;
+ALTER TABLE channels ADD COLUMN watermark_position TEXT;
@@ -0,0 +1,18 @@
+-- Значения пользовательских переменных поста, подставляемые при отправке
+ALTER TABLE posts ADD COLUMN variables TEXT;
+
+CREATE TABLE post_templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    content TEXT,
+    media_type TEXT NOT NULL DEFAULT 'text',
+    media_path TEXT,
+    buttons TEXT,
+    -- Пользовательские переменные со значениями по умолчанию
+    variables TEXT,
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_post_templates_media_path ON post_templates(media_path);
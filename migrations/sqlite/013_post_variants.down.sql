@@ -0,0 +1,2 @@
+ALTER TABLE post_channels DROP COLUMN variant;
+ALTER TABLE posts DROP COLUMN variants;
@@ -0,0 +1,5 @@
+-- Варианты текста поста для разных каналов и A/B-тестов
+ALTER TABLE posts ADD COLUMN variants TEXT;
+
+-- Вариант, отправленный в канал; пустой — основной текст поста
+ALTER TABLE post_channels ADD COLUMN variant TEXT NOT NULL DEFAULT '';
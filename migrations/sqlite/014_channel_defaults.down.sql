@@ -0,0 +1,6 @@
+ALTER TABLE posts DROP COLUMN send_options;
+
+ALTER TABLE channels DROP COLUMN disable_link_preview;
+ALTER TABLE channels DROP COLUMN disable_notification;
+ALTER TABLE channels DROP COLUMN buttons;
+ALTER TABLE channels DROP COLUMN footer;
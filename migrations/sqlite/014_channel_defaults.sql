@@ -0,0 +1,8 @@
+-- Подпись, кнопки и настройки отправки канала по умолчанию
+ALTER TABLE channels ADD COLUMN footer TEXT;
+ALTER TABLE channels ADD COLUMN buttons TEXT;
+ALTER TABLE channels ADD COLUMN disable_notification BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE channels ADD COLUMN disable_link_preview BOOLEAN NOT NULL DEFAULT 0;
+
+-- Переопределения настроек канала для поста
+ALTER TABLE posts ADD COLUMN send_options TEXT;
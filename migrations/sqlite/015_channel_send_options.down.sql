@@ -0,0 +1,3 @@
+ALTER TABLE channels DROP COLUMN link_preview_size;
+ALTER TABLE channels DROP COLUMN link_preview_above;
+ALTER TABLE channels DROP COLUMN protect_content;
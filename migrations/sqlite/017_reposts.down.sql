@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reposts;
+
+ALTER TABLE post_channels DROP COLUMN repost_mode;
+ALTER TABLE post_channels DROP COLUMN repost_of;
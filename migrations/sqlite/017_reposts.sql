@@ -0,0 +1,19 @@
+-- Повтор доставленного сообщения в другом канале: исходная доставка и
+-- способ (copy — copyMessage, forward — forwardMessage)
+ALTER TABLE post_channels ADD COLUMN repost_of INTEGER REFERENCES post_channels(id) ON DELETE SET NULL;
+ALTER TABLE post_channels ADD COLUMN repost_mode TEXT NOT NULL DEFAULT '';
+
+-- Очередь повторов; новые сообщения записываются доставками
+CREATE TABLE reposts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_channel_id INTEGER NOT NULL REFERENCES post_channels(id) ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    mode TEXT NOT NULL,
+    schedule_time TIMESTAMP,
+    status TEXT NOT NULL DEFAULT 'scheduled',
+    created_by TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_reposts_post_channel_id ON reposts(post_channel_id);
+CREATE INDEX idx_reposts_schedule_time ON reposts(schedule_time) WHERE status = 'scheduled';
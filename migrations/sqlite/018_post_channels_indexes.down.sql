@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_post_channels_channel_id;
+DROP INDEX IF EXISTS idx_post_channels_post_id;